@@ -80,7 +80,9 @@ func (pm pM) run(config core.RunConfig) string {
 		args = append(args, "--env", k+"="+v)
 	}
 	if config.Watch.Valid {
-		args = append(args, "--watch", config.Watch.Value.String())
+		for _, pattern := range config.Watch.Value.Include {
+			args = append(args, "--watch", strings.TrimPrefix(pattern, "re:"))
+		}
 	}
 	if config.StdoutFile.Valid {
 		args = append(args, "--stdout", config.StdoutFile.Value)
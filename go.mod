@@ -3,6 +3,7 @@ module github.com/rprtr258/pm
 go 1.25.0
 
 require (
+	github.com/BurntSushi/toml v1.6.0
 	github.com/acarl005/stripansi v0.0.0-20180116102854-5a71ef0e047d
 	github.com/adhocore/gronx v1.19.6
 	github.com/adrg/xdg v0.5.3
@@ -27,7 +28,10 @@ require (
 	github.com/shoenig/test v1.12.2
 	github.com/spf13/afero v1.15.0
 	github.com/spf13/cobra v1.10.1
+	go.yaml.in/yaml/v3 v3.0.3
+	golang.org/x/sys v0.38.0
 	golang.org/x/term v0.37.0
+	sigs.k8s.io/yaml v1.6.0
 )
 
 require (
@@ -68,9 +72,7 @@ require (
 	github.com/yusufpapurcu/wmi v1.2.4 // indirect
 	go.yaml.in/yaml/v2 v2.4.3 // indirect
 	golang.org/x/crypto v0.45.0 // indirect
-	golang.org/x/sys v0.38.0 // indirect
 	golang.org/x/text v0.31.0 // indirect
 	golang.org/x/tools v0.38.0 // indirect
 	gopkg.in/tomb.v1 v1.0.0-20141024135613-dd632973f1e7 // indirect
-	sigs.k8s.io/yaml v1.6.0 // indirect
 )
@@ -38,15 +38,26 @@ var _app = func() *cobra.Command {
 		_cmdList,
 		_cmdLogs,
 		_cmdInspect,
+		_cmdEvents,
+		_cmdJobs,
+		_cmdSnapshots,
+		_cmdConfig,
 	)
 	addGroup(cmd, "Management",
 		_cmdRun,
 		_cmdStart,
 		_cmdRestart,
+		_cmdReload,
+		_cmdScale,
 		_cmdStop,
 		_cmdDelete,
 		_cmdSignal,
 		_cmdAttach,
+		_cmdProxy,
+		_cmdSave,
+		_cmdResurrect,
+		_cmdImport,
+		_cmdExport,
 	)
 	return cmd
 }()
@@ -2,19 +2,28 @@ package cli
 
 import (
 	"fmt"
+	"os"
 	"slices"
 	"strings"
+	"testing"
 
 	"github.com/charmbracelet/huh"
 	"github.com/rs/zerolog/log"
+	"github.com/spf13/afero"
 	"github.com/spf13/cobra"
 
 	"github.com/rprtr258/pm/internal/config"
 	"github.com/rprtr258/pm/internal/core"
 	"github.com/rprtr258/pm/internal/db"
+	"github.com/rprtr258/pm/internal/errors"
 )
 
 var dbb, cfg = func() (db.Handle, core.Config) {
+	// NOTE: tests must not touch real pm directories, nor prune logs there
+	if testing.Testing() {
+		return db.New(afero.NewMemMapFs()), core.DefaultConfig
+	}
+
 	db, config, errNewApp := config.New()
 	if errNewApp != nil {
 		log.Panic().Err(errNewApp).Msg("new app")
@@ -29,8 +38,75 @@ func printProcs(procs ...core.ProcStat) {
 	}
 }
 
-func addFlagConfig(cmd *cobra.Command, config *string) {
-	cmd.Flags().StringVarP(config, "config", "f", "", "config file to use")
+// extVarsValue is repeated flag of jsonnet ext vars, given as name=value or
+// as name to take value from environment variable of the same name
+type extVarsValue map[string]string
+
+func (v extVarsValue) String() string {
+	vars := make([]string, 0, len(v))
+	for name, value := range v {
+		vars = append(vars, name+"="+value)
+	}
+	slices.Sort(vars)
+	return strings.Join(vars, ",")
+}
+
+func (v extVarsValue) Set(extVar string) error {
+	name, value, ok := strings.Cut(extVar, "=")
+	if !ok {
+		value, ok = os.LookupEnv(name)
+		if !ok {
+			return errors.Newf("environment variable %q is not set", name)
+		}
+	}
+
+	v[name] = value
+	return nil
+}
+
+func (extVarsValue) Type() string {
+	return "name=value"
+}
+
+// jsonnetFlags are flags setting ext vars and import dirs of jsonnet configs
+type jsonnetFlags struct {
+	extStrs  extVarsValue
+	extCodes extVarsValue
+	jpath    []string
+}
+
+// options of configs loading set by flags
+func (f jsonnetFlags) options() []core.LoadOption {
+	opts := []core.LoadOption{core.WithJPath(f.jpath...)}
+	for name, value := range f.extStrs {
+		opts = append(opts, core.WithExtStr(name, value))
+	}
+	for name, code := range f.extCodes {
+		opts = append(opts, core.WithExtCode(name, code))
+	}
+	return opts
+}
+
+func addFlagConfig(cmd *cobra.Command, config *string, jsonnet *jsonnetFlags) {
+	*jsonnet = jsonnetFlags{
+		extStrs:  extVarsValue{},
+		extCodes: extVarsValue{},
+		jpath:    nil,
+	}
+	cmd.Flags().StringVarP(config, "config", "f", "", "config file or dir with config files to use")
+	cmd.Flags().Var(jsonnet.extStrs, "ext-str", "set jsonnet ext var to string, as name=value or name to take value from environment")
+	cmd.Flags().Var(jsonnet.extCodes, "ext-code", "set jsonnet ext var to jsonnet code, as name=code or name to take code from environment")
+	cmd.Flags().StringArrayVarP(&jsonnet.jpath, "jpath", "J", nil, "add dir to look up jsonnet imports in, later dirs take precedence")
+}
+
+// loadConfigs from file or dir. Ports are not allocated by lookups in
+// configs, configs to run must provide allocating lookup in opts.
+func loadConfigs(filename string, opts ...core.LoadOption) ([]core.RunConfig, error) {
+	return core.LoadConfigs(filename, append([]core.LoadOption{core.WithPortLookup(_ports.peek)}, opts...)...)
+}
+
+func addFlagProfile(cmd *cobra.Command, profile *string) {
+	cmd.Flags().StringVar(profile, "profile", "", `config profile to apply, also passed to jsonnet as std.extVar("profile")`)
 }
 
 func addFlagStrings(
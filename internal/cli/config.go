@@ -0,0 +1,71 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/rprtr258/pm/internal/core"
+	"github.com/rprtr258/pm/internal/errors"
+)
+
+var _cmdConfigValidate = func() *cobra.Command {
+	var config, profile string
+	var jsonnet jsonnetFlags
+	cmd := &cobra.Command{
+		Use:   "validate",
+		Short: "check config file, printing all problems found in it",
+		Args:  cobra.NoArgs,
+		RunE: func(*cobra.Command, []string) error {
+			if config == "" {
+				return errors.New("config file is not specified")
+			}
+
+			problems, err := core.ValidateConfigs(config, append(jsonnet.options(),
+				core.WithPortLookup(_ports.peek),
+				core.WithProcNames(procNames()...),
+				core.WithProfile(profile),
+			)...)
+			if err != nil {
+				return err
+			}
+
+			for _, problem := range problems {
+				fmt.Println(problem.Error())
+			}
+
+			if len(problems) > 0 {
+				return errors.Newf("found %d problem(s) in %s", len(problems), config)
+			}
+
+			fmt.Printf("%s: ok\n", config)
+			return nil
+		},
+	}
+	addFlagConfig(cmd, &config, &jsonnet)
+	addFlagProfile(cmd, &profile)
+	return cmd
+}()
+
+var _cmdConfigSchema = func() *cobra.Command {
+	return &cobra.Command{
+		Use:   "schema",
+		Short: "print JSON Schema of config file",
+		Args:  cobra.NoArgs,
+		RunE: func(*cobra.Command, []string) error {
+			_, err := os.Stdout.Write(core.ConfigSchema)
+			return err
+		},
+	}
+}()
+
+var _cmdConfig = func() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "validate config files",
+	}
+	cmd.AddCommand(_cmdConfigValidate)
+	cmd.AddCommand(_cmdConfigSchema)
+	return cmd
+}()
@@ -15,6 +15,7 @@ import (
 	"github.com/rprtr258/pm/internal/core"
 	"github.com/rprtr258/pm/internal/db"
 	"github.com/rprtr258/pm/internal/errors"
+	"github.com/rprtr258/pm/internal/eventlog"
 )
 
 func removeFile(name string) error {
@@ -58,6 +59,7 @@ func implDelete(db db.Handle, dirLogs string, ids ...core.PMID) error {
 			}
 
 			fmt.Println(proc.Name)
+			eventlog.Emit(core.NewEvent(core.EventDeleted, proc.ID, proc.Name))
 
 			// remove log files
 			return errors.Combine(
@@ -73,6 +75,7 @@ var _cmdDelete = func() *cobra.Command {
 	const filter = filterAll
 	var names, ids, tags []string
 	var config string
+	var jsonnet jsonnetFlags
 	var interactive bool
 	cmd := &cobra.Command{
 		Use:               "delete [name|tag|id]...",
@@ -84,14 +87,14 @@ var _cmdDelete = func() *cobra.Command {
 
 			var filterFunc func(core.Proc) bool
 			if config != nil {
-				configs, errLoadConfigs := core.LoadConfigs(*config)
+				configs, errLoadConfigs := loadConfigs(*config, jsonnet.options()...)
 				if errLoadConfigs != nil {
 					return errors.Wrapf(errLoadConfigs, "load configs: %s", *config)
 				}
 
 				procNames := fun.Map[string](func(cfg core.RunConfig) string {
 					return cfg.Name
-				}, configs...)
+				}, core.Replicas(configs...)...)
 
 				ff := core.FilterFunc(
 					core.WithGeneric(args...),
@@ -136,6 +139,6 @@ var _cmdDelete = func() *cobra.Command {
 	}
 	addFlagInteractive(cmd, &interactive)
 	addFlagGenerics(cmd, filter, &names, &tags, &ids)
-	addFlagConfig(cmd, &config)
+	addFlagConfig(cmd, &config, &jsonnet)
 	return cmd
 }()
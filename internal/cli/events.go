@@ -0,0 +1,166 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rprtr258/fun"
+	"github.com/rprtr258/scuf"
+	"github.com/spf13/cobra"
+
+	"github.com/rprtr258/pm/internal/core"
+	"github.com/rprtr258/pm/internal/errors"
+	"github.com/rprtr258/pm/internal/eventlog"
+)
+
+const (
+	_formatEventsText = "text"
+	_formatEventsJSON = "json"
+)
+
+func mapEventType(typ core.EventType) string {
+	color := fun.Switch(typ, scuf.FgHiWhite).
+		Case(scuf.FgHiGreen, core.EventStarted).
+		Case(scuf.FgHiYellow, core.EventRestarted, core.EventReloaded).
+		Case(scuf.FgRed, core.EventExited, core.EventStopped).
+		Case(scuf.Combine(scuf.FgRed, scuf.ModBold), core.EventOOM, core.EventBuildFailed).
+		Case(scuf.FgHiBlue, core.EventCreated, core.EventDeleted).
+		End()
+	return scuf.String(fmt.Sprintf("%-12s", typ), color)
+}
+
+func printEventText(event core.Event) {
+	details := []string{}
+	if pid, ok := event.PID.Unpack(); ok {
+		details = append(details, fmt.Sprintf("pid=%d", pid))
+	}
+	if code, ok := event.ExitCode.Unpack(); ok {
+		details = append(details, fmt.Sprintf("exit_code=%d", code))
+	}
+	if event.Signal != "" {
+		details = append(details, fmt.Sprintf("signal=%q", event.Signal))
+	}
+	if event.Reason != "" {
+		details = append(details, "reason="+event.Reason)
+	}
+
+	fmt.Println(
+		scuf.String(event.Time.Format(time.DateTime), scuf.ModFaint),
+		mapEventType(event.Type),
+		scuf.String(event.ProcName, colorByID(event.ProcID)),
+		strings.Join(details, " "),
+	)
+}
+
+func printEventJSON(event core.Event) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return errors.Wrapf(err, "marshal event")
+	}
+
+	fmt.Println(string(line))
+	return nil
+}
+
+var _cmdEvents = func() *cobra.Command {
+	const filter = filterAll
+	var names, ids, tags, types []string
+	var format string
+	var follow bool
+	cmd := &cobra.Command{
+		Use:               "events [name|tag|id]...",
+		Short:             "show processes lifecycle events",
+		ValidArgsFunction: completeArgGenericSelector(filter),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var printEvent func(core.Event) error
+			switch format {
+			case _formatEventsText:
+				printEvent = func(event core.Event) error {
+					printEventText(event)
+					return nil
+				}
+			case _formatEventsJSON:
+				printEvent = printEventJSON
+			default:
+				return errors.Newf("unknown format: %q", format)
+			}
+
+			for _, typ := range types {
+				if !fun.Contains(core.EventType(typ), core.EventTypes...) {
+					return errors.Newf("unknown event type: %q", typ)
+				}
+			}
+
+			filterFunc := core.FilterFunc(
+				core.WithAllIfNoFilters,
+				core.WithGeneric(args...),
+				core.WithIDs(ids...),
+				core.WithNames(names...),
+				core.WithTags(tags...),
+			)
+			matches := func(event core.Event) bool {
+				if len(types) > 0 && !fun.Contains(string(event.Type), types...) {
+					return false
+				}
+
+				proc, ok := dbb.GetProc(event.ProcID)
+				if !ok {
+					// proc is deleted already, match it by id and name only
+					proc = core.Proc{ID: event.ProcID, Name: event.ProcName} //nolint:exhaustruct // deleted proc
+				}
+				return filterFunc(proc)
+			}
+
+			if !follow {
+				events, err := eventlog.Read(core.FileEvents)
+				if err != nil {
+					return errors.Wrapf(err, "read events")
+				}
+
+				for _, event := range events {
+					if !matches(event) {
+						continue
+					}
+
+					if err := printEvent(event); err != nil {
+						return err
+					}
+				}
+
+				return nil
+			}
+
+			ctx := cmd.Context()
+			eventsCh, err := eventlog.Follow(ctx, core.FileEvents)
+			if err != nil {
+				return errors.Wrapf(err, "follow events")
+			}
+
+			for event := range eventsCh {
+				if !matches(event) {
+					continue
+				}
+
+				if err := printEvent(event); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&follow, "follow", false, "wait for new events instead of printing past ones")
+	cmd.Flags().StringVar(&format, "format", _formatEventsText, "output format: text or json")
+	registerFlagCompletionFunc(cmd, "format", func(string) ([]string, cobra.ShellCompDirective) {
+		return []string{_formatEventsText, _formatEventsJSON}, cobra.ShellCompDirectiveNoFileComp
+	})
+	addFlagStrings(cmd, &types, "type", "event type(s) to show", func(string) ([]string, cobra.ShellCompDirective) {
+		return fun.Map[string](func(typ core.EventType) string {
+			return string(typ)
+		}, core.EventTypes...), cobra.ShellCompDirectiveNoFileComp
+	})
+	addFlagGenerics(cmd, filter, &names, &tags, &ids)
+	return cmd
+}()
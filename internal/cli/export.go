@@ -0,0 +1,122 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/rprtr258/fun"
+	"github.com/spf13/cobra"
+
+	"github.com/rprtr258/pm/internal/core"
+	"github.com/rprtr258/pm/internal/errors"
+)
+
+func completeFlagFormat(string) ([]string, cobra.ShellCompDirective) {
+	return fun.Map[string](func(format core.ConfigFormat) string {
+		return string(format)
+	}, core.ConfigFormats...), cobra.ShellCompDirectiveNoFileComp
+}
+
+var _cmdExport = func() *cobra.Command {
+	const filter = filterAll
+	var names, ids, tags []string
+	var format, output string
+	cmd := &cobra.Command{
+		Use:               "export [name|tag|id]...",
+		Short:             "print config of process(es)",
+		ValidArgsFunction: completeArgGenericSelector(filter),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			filterFunc := core.FilterFunc(
+				core.WithAllIfNoFilters,
+				core.WithGeneric(args...),
+				core.WithIDs(ids...),
+				core.WithNames(names...),
+				core.WithTags(tags...),
+			)
+			procs := listProcs(dbb).
+				Filter(func(ps core.ProcStat) bool { return filterFunc(ps.Proc) }).
+				Slice()
+
+			configFormat := core.ConfigFormat(format)
+			if !cmd.Flags().Lookup("format").Changed {
+				configFormat = core.DetectConfigFormat(output).OrDefault(core.ConfigFormatJsonnet)
+			}
+
+			// paths in config are relative to config file
+			dir, err := os.Getwd()
+			if err != nil {
+				return errors.Wrapf(err, "get cwd")
+			}
+			if output != "" {
+				dir, err = filepath.Abs(filepath.Dir(output))
+				if err != nil {
+					return errors.Wrapf(err, "get absolute path of %q", output)
+				}
+			}
+
+			b, err := core.ExportConfigs(
+				fun.Map[core.Proc](func(ps core.ProcStat) core.Proc { return ps.Proc }, procs...),
+				dir,
+				configFormat,
+			)
+			if err != nil {
+				return err
+			}
+
+			if output == "" {
+				_, err := os.Stdout.Write(b)
+				return err
+			}
+
+			return errors.Wrapf(os.WriteFile(output, b, 0o644), "write %s", output)
+		},
+	}
+	cmd.Flags().StringVar(&format, "format", string(core.ConfigFormatJsonnet), "config format, detected by output file name if not set")
+	registerFlagCompletionFunc(cmd, "format", completeFlagFormat)
+	cmd.Flags().StringVarP(&output, "output", "o", "", "file to write config to, stdout if not set")
+	addFlagGenerics(cmd, filter, &names, &tags, &ids)
+	return cmd
+}()
+
+var _cmdImport = func() *cobra.Command {
+	var format string
+	cmd := &cobra.Command{
+		Use:   "import file [name]...",
+		Short: "create and run processes from Procfile or docker compose file",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			filename, names := args[0], args[1:]
+
+			configFormat := core.ConfigFormat(format)
+			if !cmd.Flags().Lookup("format").Changed {
+				detected, ok := core.DetectConfigFormat(filename).Unpack()
+				if !ok {
+					return errors.Newf("unknown format of %q, set it with --format", filename)
+				}
+				configFormat = detected
+			}
+
+			configs, err := core.ImportConfigs(filename, configFormat)
+			if err != nil {
+				return errors.Wrapf(err, "import %s", filename)
+			}
+
+			if len(names) > 0 {
+				for _, name := range names {
+					if !fun.Any(func(config core.RunConfig) bool { return config.Name == name }, configs...) {
+						return errors.Newf("unknown proc name: %q", name)
+					}
+				}
+
+				configs = fun.Filter(func(config core.RunConfig) bool {
+					return fun.Contains(config.Name, names...)
+				}, configs...)
+			}
+
+			return runProcs(dbb, core.DirLogs, configs...)
+		},
+	}
+	cmd.Flags().StringVar(&format, "format", "", "config format, detected by file name if not set")
+	registerFlagCompletionFunc(cmd, "format", completeFlagFormat)
+	return cmd
+}()
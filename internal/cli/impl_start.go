@@ -7,6 +7,7 @@ import (
 	"os"
 	"os/exec"
 	"slices"
+	"strconv"
 	"strings"
 	"syscall"
 
@@ -52,6 +53,16 @@ func startShimImpl(db db.Handle, id core.PMID) error {
 		proc.Env = map[string]string{}
 	}
 	proc.Env[core.EnvPMID] = string(proc.ID)
+	for i, port := range proc.Ports {
+		if i == 0 {
+			proc.Env["PORT"] = strconv.Itoa(port.Port)
+		}
+		proc.Env[core.PortEnv(port.Name)] = strconv.Itoa(port.Port)
+	}
+	if proc.Group != "" {
+		proc.Env[core.EnvInstance] = strconv.Itoa(proc.Instance)
+		proc.Env[core.EnvNodeAppInstance] = strconv.Itoa(proc.Instance)
+	}
 	for _, kv := range os.Environ() {
 		kvs := strings.SplitN(kv, "=", 2)
 		k, v := kvs[0], kvs[1]
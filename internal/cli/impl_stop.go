@@ -15,6 +15,26 @@ import (
 	"github.com/rprtr258/pm/internal/linuxprocess"
 )
 
+// _stopGracePeriod is time given to shim to finish its job after stopping child
+const _stopGracePeriod = 5 * time.Second
+
+// killShim and its whole family, used when shim fails to stop child in time
+func killShim(shimPID int) {
+	pids := []int{shimPID}
+	for _, child := range linuxprocess.Children(linuxprocess.List(), shimPID) {
+		pids = append(pids, child.Handle.Pid)
+	}
+
+	for _, pid := range pids {
+		if errKill := syscall.Kill(pid, syscall.SIGKILL); errKill != nil && !stdErrors.Is(errKill, syscall.ESRCH) {
+			log.Error().
+				Int("pid", pid).
+				Err(errKill).
+				Msg("failed to send SIGKILL to process")
+		}
+	}
+}
+
 func implStop(db db.Handle, ids ...core.PMID) error {
 	procs, err := db.List(core.WithIDs(ids...))
 	if err != nil {
@@ -24,7 +44,8 @@ func implStop(db db.Handle, ids ...core.PMID) error {
 	list := linuxprocess.List()
 	return errors.Combine(fun.Map[error](func(id core.PMID) error {
 		return errors.Wrapf(func() error {
-			if _, ok := procs[id]; !ok {
+			procData, ok := procs[id]
+			if !ok {
 				return errors.Newf("not found proc to stop")
 			}
 
@@ -53,9 +74,24 @@ func implStop(db db.Handle, ids ...core.PMID) error {
 				}
 			}
 
-			// wait for process to stop
+			// wait for process to stop, shim performs stop sequence, so give it
+			// time to do it, but not more
+			var deadline <-chan time.Time
+			if stopTimeout := procData.StopTimeout(); stopTimeout > 0 {
+				deadline = time.After(stopTimeout + _stopGracePeriod)
+			}
 			for {
-				time.Sleep(100 * time.Millisecond)
+				select {
+				case <-deadline:
+					log.Warn().
+						Int("shim_pid", proc.ShimPID).
+						Str("id", id.String()).
+						Msg("shim did not stop process in time, killing it")
+					killShim(proc.ShimPID)
+					deadline = nil
+				case <-time.After(100 * time.Millisecond):
+				}
+
 				if _, ok := linuxprocess.StatPMID(db.ListRunning(), id); !ok {
 					break
 				}
@@ -16,19 +16,36 @@ var _procInspectTemplate = template.Must(template.New("proc").
 		"formatTime": func(t time.Time) string {
 			return t.Format(time.DateTime)
 		},
+		"nextRun": func(proc core.ProcStat) *time.Time {
+			return nextRun(proc).Ptr()
+		},
 	}).
 	Parse(`ID: {{.ID}}
 Name: {{.Name}}
-Tags: {{.Tags}}
+Tags: {{.Tags}}{{with .Profile}}
+Profile: {{.}}{{end}}{{with .ConfigFile}}
+Config file: {{.}}{{end}}
 Command: {{.Command}}
 Args: {{.Args}}
 Cwd: {{.Cwd}}
 Env: {{.Env}}
 StdoutFile: {{.StdoutFile}}
-StderrFile: {{.StderrFile}}{{if .Watch.Valid}}
-Watch: {{.Watch.Value}}{{end}}{{if .Cron.Valid}}
-Cron: {{.Cron.Value}}{{end}}
-KillTimeout: {{.KillTimeout}}
+StderrFile: {{.StderrFile}}{{with .Ports}}
+Ports: {{range $i, $port := .}}{{if $i}}, {{end}}{{$port}}{{end}}{{end}}{{if .Watch.Valid}}
+Watch: {{.Watch.Value}}{{end}}{{if .Build.Valid}}
+Build: {{.Build.Value}}{{end}}{{if .Cron.Valid}}
+Cron: {{.Cron.Value}}{{with nextRun .}}
+NextRun: {{formatTime .}}{{end}}{{end}}{{if .StartDelay}}
+StartDelay: {{.StartDelay}}{{end}}
+KillTimeout: {{.KillTimeout}}{{if .StopCommand.Valid}}
+StopCommand: {{.StopCommand.Value}}{{end}}
+StopSequence: {{range $i, $step := .StopSteps}}{{if $i}} -> {{end}}{{$step}}{{end}}{{with .Listen}}
+Listen: {{.}}{{end}}{{if .Lazy}}
+Lazy: {{.Lazy}}{{end}}{{if .IdleTimeout}}
+IdleTimeout: {{.IdleTimeout}}{{end}}{{if .Reload.Valid}}
+Reload: {{.Reload.Value.Strategy}}{{end}}{{with .Hooks.Defined}}
+Hooks:{{range $name, $hook := .}}
+	{{$name}}: {{$hook}} (on_failure={{$hook.OnFailure}}){{end}}{{end}}
 Status:
 	Status: {{.Status}}{{if eq (print .Status) "running"}}
 	StartTime: {{formatTime .StartTime}}
@@ -0,0 +1,368 @@
+package cli
+
+import (
+	"os"
+	"os/exec"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/rprtr258/fun"
+	"github.com/rs/zerolog/log"
+
+	"github.com/rprtr258/pm/internal/core"
+	"github.com/rprtr258/pm/internal/errors"
+	"github.com/rprtr258/pm/internal/fsnotify"
+	"github.com/rprtr258/pm/internal/logrotation"
+)
+
+// _jobCheckInterval is how often wall clock is checked while waiting for next
+// run, timers do not count time spent in suspend
+const _jobCheckInterval = time.Minute
+
+// _jobMissedAfter is how late run must be to count as missed, e.g. because
+// machine was suspended
+const _jobMissedAfter = time.Minute
+
+// jobRun is single running instance of job
+type jobRun struct {
+	cmd    *exec.Cmd
+	record core.CronRun
+	// generations of log files run output starts in
+	stdoutGeneration int
+	stderrGeneration int
+	timer            *time.Timer // kills run on timeout, nil if there is no timeout
+	timedOut         atomic.Bool
+}
+
+type jobExit struct {
+	run *jobRun
+	err error
+}
+
+// jobScheduler runs process to completion on cron schedule
+type jobScheduler struct {
+	proc     core.Proc
+	cron     core.Cron
+	cmdShape exec.Cmd
+	history  string // file to append runs to
+	state    string // file to persist next run time to
+	stdout   *logrotation.Writer
+	stderr   *logrotation.Writer
+
+	emit  func(core.Event)
+	hook  func(name string, hook fun.Option[core.Hook], exitCode fun.Option[int]) error
+	build func() bool
+
+	buildNeeded bool            // build before next run
+	running     map[int]*jobRun // by pid
+	exitCh      chan jobExit
+}
+
+func newJobScheduler(
+	proc core.Proc,
+	cron core.Cron,
+	cmdShape exec.Cmd,
+	stdout, stderr *logrotation.Writer,
+	emit func(core.Event),
+	hook func(name string, hook fun.Option[core.Hook], exitCode fun.Option[int]) error,
+	build func() bool,
+) *jobScheduler {
+	// runs might overlap, so they cannot share controlling terminal
+	cmdShape.Stdin = nil
+	cmdShape.Stdout = stdout
+	cmdShape.Stderr = stderr
+	cmdShape.SysProcAttr = &syscall.SysProcAttr{ //nolint:exhaustruct // only new session is needed
+		Setsid: true,
+	}
+
+	return &jobScheduler{
+		proc:     proc,
+		cron:     cron,
+		cmdShape: cmdShape,
+		history:  core.CronHistoryFile(core.DirLogs, proc.ID),
+		state:    core.CronStateFile(core.DirLogs, proc.ID),
+		stdout:   stdout,
+		stderr:   stderr,
+		emit:     emit,
+		hook:     hook,
+		build:    build,
+
+		buildNeeded: proc.Build.Valid,
+		running:     map[int]*jobRun{},
+		exitCh:      make(chan jobExit),
+	}
+}
+
+// persistedNext is time of next run persisted by previous shim
+func (s *jobScheduler) persistedNext() fun.Option[time.Time] {
+	next, err := core.ReadCronState(s.state, s.cron)
+	if err != nil {
+		log.Error().Err(err).Msg("read job state")
+	}
+	return next
+}
+
+func (s *jobScheduler) persistNext(next time.Time) {
+	if err := core.WriteCronState(s.state, s.cron, next); err != nil {
+		log.Error().Err(err).Msg("write job state")
+	}
+}
+
+func (s *jobScheduler) appendHistory(record core.CronRun) {
+	if err := core.AppendCronHistory(s.history, record); err != nil {
+		log.Error().Err(err).Msg("write job history")
+	}
+}
+
+// start new run scheduled at given time, applying concurrency policy
+func (s *jobScheduler) start(scheduledAt time.Time, reason string) {
+	if len(s.running) > 0 {
+		switch s.cron.Concurrency {
+		case core.CronConcurrencyForbid:
+			log.Warn().
+				Time("scheduled_at", scheduledAt).
+				Int("running", len(s.running)).
+				Msg("previous run is still running, skipping")
+			s.appendHistory(core.CronRun{
+				ScheduledAt: scheduledAt,
+				StartedAt:   time.Time{},
+				FinishedAt:  time.Time{},
+				PID:         0,
+				ExitCode:    fun.Invalid[int](),
+				Signal:      "",
+				TimedOut:    false,
+				Skipped:     true,
+				Stdout:      core.LogRange{File: "", Start: 0, End: 0},
+				Stderr:      core.LogRange{File: "", Start: 0, End: 0},
+			})
+			return
+		case core.CronConcurrencyReplace:
+			log.Info().Int("running", len(s.running)).Msg("replacing previous run")
+			s.stopAll()
+		case core.CronConcurrencyAllow:
+		}
+	}
+
+	if s.buildNeeded {
+		if !s.build() {
+			log.Warn().Time("scheduled_at", scheduledAt).Msg("build failed, skipping run")
+			return
+		}
+		s.buildNeeded = false
+	}
+
+	if err := s.hook("pre_start", s.proc.Hooks.PreStart, fun.Invalid[int]()); err != nil {
+		log.Error().Err(err).Time("scheduled_at", scheduledAt).Msg("abort run")
+		return
+	}
+
+	stdoutGeneration, stdoutStart := s.stdout.Position()
+	stderrGeneration, stderrStart := s.stderr.Position()
+	cmd, err := execCmd(s.cmdShape)
+	if err != nil {
+		log.Error().Err(err).Time("scheduled_at", scheduledAt).Msg("run job")
+		return
+	}
+
+	startedEvent := core.NewEvent(core.EventStarted, s.proc.ID, s.proc.Name)
+	startedEvent.PID = fun.Valid(cmd.Process.Pid)
+	startedEvent.Reason = reason
+	s.emit(startedEvent)
+
+	run := &jobRun{ //nolint:exhaustruct // timedOut is zero
+		cmd: cmd,
+		record: core.CronRun{
+			ScheduledAt: scheduledAt,
+			StartedAt:   time.Now(),
+			FinishedAt:  time.Time{},
+			PID:         cmd.Process.Pid,
+			ExitCode:    fun.Invalid[int](),
+			Signal:      "",
+			TimedOut:    false,
+			Skipped:     false,
+			Stdout:      core.LogRange{File: "", Start: stdoutStart, End: 0},
+			Stderr:      core.LogRange{File: "", Start: stderrStart, End: 0},
+		},
+		stdoutGeneration: stdoutGeneration,
+		stderrGeneration: stderrGeneration,
+		timer:            nil,
+	}
+	if s.cron.Timeout > 0 {
+		run.timer = time.AfterFunc(s.cron.Timeout, func() {
+			log.Warn().Int("pid", cmd.Process.Pid).Stringer("timeout", s.cron.Timeout).Msg("run timed out")
+			run.timedOut.Store(true)
+			killCmd(cmd, s.proc.StopSteps())
+		})
+	}
+	s.running[cmd.Process.Pid] = run
+
+	go func() {
+		s.exitCh <- jobExit{run: run, err: cmd.Wait()}
+	}()
+
+	if err := s.hook("post_start", s.proc.Hooks.PostStart, fun.Invalid[int]()); err != nil {
+		log.Error().Err(err).Msg("abort run")
+		killCmd(cmd, s.proc.StopSteps())
+	}
+}
+
+// finishLogRange of run output at current end of log, output starts in log
+// file of given generation
+func finishLogRange(r core.LogRange, generation int, w *logrotation.Writer) core.LogRange {
+	current, end := w.Position()
+	r.End = end
+	if current != generation {
+		// log was rotated during run
+		r.File = w.Backup(generation)
+	}
+	return r
+}
+
+// finish exited run: emit event, write history and run exit hooks
+func (s *jobScheduler) finish(exit jobExit) {
+	run := exit.run
+	if run.timer != nil {
+		run.timer.Stop()
+	}
+	delete(s.running, run.cmd.Process.Pid)
+
+	event := exitEvent(s.proc, run.cmd, exit.err, fun.Invalid[uint64]())
+	if run.timedOut.Load() {
+		event.Reason = core.ReasonTimeout
+	}
+	s.emit(event)
+
+	run.record.FinishedAt = time.Now()
+	run.record.ExitCode = event.ExitCode
+	run.record.Signal = event.Signal
+	run.record.TimedOut = run.timedOut.Load()
+	run.record.Stdout = finishLogRange(run.record.Stdout, run.stdoutGeneration, s.stdout)
+	run.record.Stderr = finishLogRange(run.record.Stderr, run.stderrGeneration, s.stderr)
+	s.appendHistory(run.record)
+
+	_ = s.hook("post_exit", s.proc.Hooks.PostExit, event.ExitCode)
+	if event.ExitCode != fun.Valid(0) {
+		_ = s.hook("on_crash", s.proc.Hooks.OnCrash, event.ExitCode)
+	}
+}
+
+// stopAll running runs and wait for them to exit
+func (s *jobScheduler) stopAll() {
+	if len(s.running) == 0 {
+		return
+	}
+
+	_ = s.hook("pre_stop", s.proc.Hooks.PreStop, fun.Invalid[int]())
+	for _, run := range s.running {
+		go killCmd(run.cmd, s.proc.StopSteps())
+	}
+
+	timeout := time.Second // to get exit statuses of killed runs
+	for _, step := range s.proc.StopSteps() {
+		timeout += step.Timeout
+	}
+	deadline := time.After(timeout)
+	for len(s.running) > 0 {
+		select {
+		case exit := <-s.exitCh:
+			s.finish(exit)
+		case <-deadline:
+			log.Warn().Int("running", len(s.running)).Msg("timed out waiting for runs to stop")
+			return
+		}
+	}
+}
+
+// run jobs on schedule until terminated. Reload and run request start run
+// immediately, watch trigger makes next run rebuild process first.
+func (s *jobScheduler) run(
+	terminateCh, reloadCh, runCh <-chan os.Signal,
+	watchCh <-chan []fsnotify.Event,
+) error {
+	now := time.Now()
+	next, err := s.cron.Next(now)
+	if err != nil {
+		return errors.Wrapf(err, "schedule job")
+	}
+
+	caughtUp := false
+	if persisted, ok := s.persistedNext().Unpack(); ok {
+		switch {
+		case persisted.IsZero(): // single run is done already
+			next = persisted
+		case !persisted.Before(now):
+			next = persisted
+		case s.cron.CatchUp:
+			log.Info().Time("missed", persisted).Msg("catching up missed run")
+			s.start(persisted, core.ReasonCatchUp)
+			caughtUp = true
+		default:
+			log.Warn().Time("scheduled_at", persisted).Msg("run missed")
+		}
+	}
+	if s.cron.RunOnStart && !caughtUp {
+		s.start(now, "")
+	}
+	s.persistNext(next)
+
+	for {
+		var tick <-chan time.Time
+		if next.IsZero() {
+			if len(s.running) == 0 {
+				log.Info().Msg("no more runs scheduled")
+				event := core.NewEvent(core.EventStopped, s.proc.ID, s.proc.Name)
+				event.Reason = core.ReasonFinished
+				s.emit(event)
+				return nil
+			}
+		} else {
+			tick = time.After(min(time.Until(next), _jobCheckInterval))
+		}
+
+		log.Debug().Time("next", next).Int("running", len(s.running)).Msg("waiting for next run")
+		select {
+		case <-terminateCh:
+			log.Debug().Msg("terminate signal received")
+			running := len(s.running) > 0
+			s.stopAll()
+			if running {
+				s.emit(core.NewEvent(core.EventStopped, s.proc.ID, s.proc.Name))
+			}
+			return nil
+		case <-reloadCh:
+			log.Debug().Msg("reload requested, running job now")
+			s.start(time.Now(), core.ReasonReload)
+		case <-runCh:
+			log.Debug().Msg("run requested")
+			s.start(time.Now(), core.ReasonManual)
+		case events := <-watchCh:
+			log.Debug().Any("events", events).Msg("watch triggered, running job now")
+			s.buildNeeded = s.proc.Build.Valid
+			s.start(time.Now(), core.ReasonWatch)
+		case exit := <-s.exitCh:
+			s.finish(exit)
+		case <-tick:
+			now := time.Now()
+			if now.Before(next) {
+				continue
+			}
+
+			if late := now.Sub(next); late > _jobMissedAfter && !s.cron.CatchUp {
+				log.Warn().Time("scheduled_at", next).Stringer("late", late).Msg("run missed")
+			} else {
+				s.start(next, core.ReasonCron)
+			}
+
+			// keep intervals counted from previous tick, unless ticks were missed
+			next, err = s.cron.Next(next)
+			if err == nil && !next.IsZero() && next.Before(now) {
+				next, err = s.cron.Next(now)
+			}
+			if err != nil {
+				return errors.Wrapf(err, "schedule job")
+			}
+			s.persistNext(next)
+		}
+	}
+}
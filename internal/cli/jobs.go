@@ -0,0 +1,183 @@
+package cli
+
+import (
+	stdErrors "errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/rprtr258/fun"
+	"github.com/rprtr258/scuf"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+
+	"github.com/rprtr258/pm/internal/core"
+	"github.com/rprtr258/pm/internal/errors"
+	"github.com/rprtr258/pm/internal/linuxprocess"
+	"github.com/rprtr258/pm/internal/table"
+)
+
+// _signalRunJob is sent to shim to make it run job out of schedule
+const _signalRunJob = syscall.SIGUSR1
+
+func renderRunResult(run core.CronRun) string {
+	switch {
+	case run.Skipped:
+		return scuf.String("skipped", scuf.FgYellow)
+	case run.TimedOut:
+		return scuf.String("timed out", scuf.FgRed, scuf.ModBold)
+	case run.Signal != "":
+		return scuf.String(run.Signal, scuf.FgRed)
+	}
+
+	code, ok := run.ExitCode.Unpack()
+	if !ok {
+		return ""
+	}
+
+	return scuf.String("exit "+strconv.Itoa(code), fun.IF(code == 0, scuf.FgGreen, scuf.FgRed))
+}
+
+func renderLogRange(r core.LogRange) string {
+	if r == (core.LogRange{File: "", Start: 0, End: 0}) {
+		return ""
+	}
+
+	if r.File != "" {
+		// output starts in rotated log file
+		return fmt.Sprintf("%s:%d-%d", filepath.Base(r.File), r.Start, r.End)
+	}
+
+	return fmt.Sprintf("%d-%d", r.Start, r.End)
+}
+
+func renderRuns(procs []core.ProcStat, limit int) {
+	rows := [][]string{}
+	for _, proc := range procs {
+		runs, err := core.ReadCronHistory(core.CronHistoryFile(core.DirLogs, proc.ID))
+		if err != nil {
+			log.Error().Err(err).Str("name", proc.Name).Msg("read job history")
+			continue
+		}
+
+		if limit > 0 && len(runs) > limit {
+			runs = runs[len(runs)-limit:]
+		}
+
+		for _, run := range runs {
+			var started, duration string
+			if !run.StartedAt.IsZero() {
+				started = run.StartedAt.Local().Format(time.DateTime)
+				duration = run.FinishedAt.Sub(run.StartedAt).Truncate(time.Millisecond).String()
+			}
+
+			rows = append(rows, []string{
+				renderName(proc),
+				run.ScheduledAt.Local().Format(time.DateTime),
+				started,
+				duration,
+				renderRunResult(run),
+				renderLogRange(run.Stdout),
+				renderLogRange(run.Stderr),
+			})
+		}
+	}
+
+	t := table.Table{
+		Headers: fun.Map[string](func(col string) string {
+			return scuf.String(col, scuf.ModBold)
+		}, "name", "scheduled", "started", "duration", "result", "stdout", "stderr"),
+		Rows:                  rows,
+		HaveInnerRowsDividers: false,
+	}
+
+	width, _, _ := term.GetSize(int(os.Stdout.Fd()))
+	fmt.Println(table.Render(t, width))
+}
+
+// implRunJobs asks shims to run jobs now, out of schedule
+func implRunJobs(procs ...core.ProcStat) error {
+	list := linuxprocess.List()
+	return errors.Combine(fun.Map[error](func(proc core.ProcStat) error {
+		if !proc.Cron.Valid {
+			return errors.Newf("%s is not a scheduled job", proc.Name)
+		}
+
+		stat, ok := linuxprocess.StatPMID(list, proc.ID)
+		if !ok {
+			return errors.Newf("job %s is stopped, start it first", proc.Name)
+		}
+
+		log.Debug().
+			Int("shim_pid", stat.ShimPID).
+			Str("id", proc.ID.String()).
+			Msg("sending run signal to shim")
+		if errKill := syscall.Kill(stat.ShimPID, _signalRunJob); errKill != nil {
+			if stdErrors.Is(errKill, syscall.ESRCH) {
+				return errors.Newf("job %s is stopped, start it first", proc.Name)
+			}
+
+			return errors.Wrapf(errKill, "run pmid=%s, shim_pid=%d", proc.ID, stat.ShimPID)
+		}
+
+		return nil
+	}, procs...)...)
+}
+
+var _cmdJobs = func() *cobra.Command {
+	const filter = filterAll
+	var names, ids, tags []string
+	var limit int
+	var run bool
+	cmd := &cobra.Command{
+		Use:               "jobs [name|tag|id]...",
+		Short:             "show history of scheduled jobs runs",
+		Aliases:           []string{"cron"},
+		ValidArgsFunction: completeArgGenericSelector(filter),
+		RunE: func(_ *cobra.Command, args []string) error {
+			if run {
+				// NOTE: jobs to run must be selected explicitly
+				filterFunc := core.FilterFunc(
+					core.WithGeneric(args...),
+					core.WithIDs(ids...),
+					core.WithNames(names...),
+					core.WithTags(tags...),
+				)
+				procs := listProcs(dbb).
+					Filter(func(ps core.ProcStat) bool { return filterFunc(ps.Proc) }).
+					Slice()
+				if len(procs) == 0 {
+					fmt.Println("nothing to run")
+					return nil
+				}
+
+				return implRunJobs(procs...)
+			}
+
+			filterFunc := core.FilterFunc(
+				core.WithAllIfNoFilters,
+				core.WithGeneric(args...),
+				core.WithIDs(ids...),
+				core.WithNames(names...),
+				core.WithTags(tags...),
+			)
+			procs := listProcs(dbb).
+				Filter(func(ps core.ProcStat) bool {
+					return ps.Cron.Valid && filterFunc(ps.Proc)
+				}).
+				Slice()
+
+			renderRuns(procs, limit)
+			return nil
+		},
+	}
+	cmd.Flags().IntVar(&limit, "limit", 10, "show at most this many last runs of each job, 0 to show all")
+	cmd.Flags().BoolVar(&run, "run", false, "run selected jobs now, out of schedule, instead of showing history")
+	cmd.MarkFlagsMutuallyExclusive("run", "limit")
+	addFlagGenerics(cmd, filter, &names, &tags, &ids)
+	return cmd
+}()
@@ -13,6 +13,7 @@ import (
 
 	"github.com/rprtr258/fun"
 	"github.com/rprtr258/scuf"
+	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
 	"golang.org/x/term"
 
@@ -101,12 +102,107 @@ func shortIDs(procs []core.ProcStat) []string {
 	return res
 }
 
+// groupReplicas places replicas of the same group next to each other, ordered
+// by instance, at the position of the first of them
+func groupReplicas(procs []core.ProcStat) []core.ProcStat {
+	byGroup := map[string][]core.ProcStat{}
+	for _, proc := range procs {
+		if proc.Group != "" {
+			byGroup[proc.Group] = append(byGroup[proc.Group], proc)
+		}
+	}
+
+	res := make([]core.ProcStat, 0, len(procs))
+	for _, proc := range procs {
+		if proc.Group == "" {
+			res = append(res, proc)
+			continue
+		}
+
+		replicas, ok := byGroup[proc.Group]
+		if !ok { // already placed
+			continue
+		}
+
+		slices.SortFunc(replicas, func(a, b core.ProcStat) int {
+			return cmp.Compare(a.Instance, b.Instance)
+		})
+		res = append(res, replicas...)
+		delete(byGroup, proc.Group)
+	}
+	return res
+}
+
+func renderName(proc core.ProcStat) string {
+	if proc.Group == "" {
+		return proc.Name
+	}
+
+	return proc.Group + scuf.String(fmt.Sprintf("#%d", proc.Instance), scuf.ModFaint)
+}
+
+func renderPorts(ports []core.Port) string {
+	return strings.Join(fun.Map[string](func(port core.Port) string {
+		return port.String()
+	}, ports...), " ")
+}
+
+// nextRun of scheduled job persisted by shim, or computed from schedule,
+// invalid if job is not scheduled now
+func nextRun(proc core.ProcStat) fun.Option[time.Time] {
+	cron, ok := proc.Cron.Unpack()
+	if !ok || proc.Status == core.StatusStopped {
+		return fun.Invalid[time.Time]()
+	}
+
+	next, err := core.ReadCronState(core.CronStateFile(core.DirLogs, proc.ID), cron)
+	if err != nil {
+		log.Error().Err(err).Str("name", proc.Name).Msg("read job state")
+	}
+	if !next.Valid || !next.Value.IsZero() && next.Value.Before(time.Now()) {
+		// not persisted yet or stale, e.g. job is waiting for start delay
+		computed, err := cron.Next(time.Now())
+		if err != nil {
+			log.Error().Err(err).Stringer("cron", cron).Msg("get next run")
+			return fun.Invalid[time.Time]()
+		}
+		next = fun.Valid(computed)
+	}
+
+	if next.Value.IsZero() {
+		return fun.Invalid[time.Time]()
+	}
+	return next
+}
+
+// renderNextRun of scheduled job, empty if job is not scheduled now
+func renderNextRun(proc core.ProcStat) string {
+	return fun.OptMap(nextRun(proc), func(next time.Time) string {
+		return next.Local().Format(time.DateTime)
+	}).OrDefault("")
+}
+
 func renderTable(procs []core.ProcStat, showRowDividers bool) {
 	ids := shortIDs(procs)
+	// show ports column only if there are processes with ports
+	showPorts := fun.Any(func(proc core.ProcStat) bool {
+		return len(proc.Ports) > 0
+	}, procs...)
+	// show next run column only if there are scheduled jobs
+	showNextRun := fun.Any(func(proc core.ProcStat) bool {
+		return proc.Cron.Valid
+	}, procs...)
+	columns := []string{"id", "name", "status", "uptime", "tags", "cpu", "memory"}
+	if showPorts {
+		columns = append(columns, "ports")
+	}
+	if showNextRun {
+		columns = append(columns, "next run")
+	}
 	t := table.Table{
 		Headers: fun.Map[string](func(col string) string {
 			return scuf.String(col, scuf.ModBold)
-		}, "id", "name", "status", "uptime", "tags", "cpu", "memory"),
+		}, columns...),
 		Rows: fun.Map[[]string](func(proc core.ProcStat, i int) []string {
 			uptime := time.Duration(0)
 			if proc.Status == core.StatusRunning {
@@ -119,9 +215,9 @@ func renderTable(procs []core.ProcStat, showRowDividers bool) {
 				memory = formatMemory(proc.Memory)
 			}
 
-			return []string{
+			row := []string{
 				scuf.String(ids[i], scuf.FgCyan, scuf.ModBold),
-				proc.Name,
+				renderName(proc),
 				mapStatus(proc.Status),
 				fun.
 					If(proc.Status != core.StatusRunning, "").
@@ -130,6 +226,13 @@ func renderTable(procs []core.ProcStat, showRowDividers bool) {
 				cpu,
 				memory,
 			}
+			if showPorts {
+				row = append(row, renderPorts(proc.Ports))
+			}
+			if showNextRun {
+				row = append(row, renderNextRun(proc))
+			}
+			return row
 		}, procs...),
 		HaveInnerRowsDividers: showRowDividers,
 	}
@@ -332,7 +435,7 @@ var _cmdList = func() *cobra.Command {
 			}
 
 			slices.SortFunc(procsToShow, less)
-			return format(procsToShow)
+			return format(groupReplicas(procsToShow))
 		},
 	}
 	cmd.Flags().StringVarP(&listFormat, "format", "f", _formatTable, _usageFlagListFormat)
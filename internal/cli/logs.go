@@ -124,6 +124,22 @@ func streamProcLogs(ctx context.Context, proc core.ProcStat) <-chan ProcLine {
 				Err(err).
 				Msg("failed to stream stderr log file")
 		}
+		if proc.Build.Valid {
+			buildFile := core.BuildLogFile(core.DirLogs, proc.ID)
+			if err := streamFile(
+				ctx,
+				logLinesCh,
+				proc.ID,
+				buildFile,
+				core.LogTypeBuild,
+				&wg,
+			); err != nil {
+				log.Error().
+					Str("file", buildFile).
+					Err(err).
+					Msg("failed to stream build log file")
+			}
+		}
 		wg.Wait()
 		close(logLinesCh)
 	}()
@@ -177,6 +193,7 @@ func getProcs(
 	db db.Handle,
 	rest, ids, names, tags []string,
 	config *string,
+	jsonnet jsonnetFlags,
 ) ([]core.ProcStat, error) {
 	filterFunc := core.FilterFunc(
 		core.WithGeneric(rest...),
@@ -188,14 +205,14 @@ func getProcs(
 
 	var filterConfig func(core.ProcStat) bool
 	if config != nil {
-		configs, errLoadConfigs := core.LoadConfigs(*config)
+		configs, errLoadConfigs := loadConfigs(*config, jsonnet.options()...)
 		if errLoadConfigs != nil {
 			return nil, errors.Wrapf(errLoadConfigs, "load configs: %v", *config)
 		}
 
 		procNames := fun.Map[string](func(cfg core.RunConfig) string {
 			return cfg.Name
-		}, configs...)
+		}, core.Replicas(configs...)...)
 		filterConfig = func(proc core.ProcStat) bool { return fun.Contains(proc.Name, procNames...) }
 	}
 
@@ -210,12 +227,14 @@ func getProcs(
 var (
 	_barStdout = scuf.String("|", scuf.FgGreen)
 	_barStderr = scuf.String("|", scuf.FgRed)
+	_barBuild  = scuf.String("|", scuf.FgBlue)
 )
 
 var _cmdLogs = func() *cobra.Command {
 	const filter = filterAll
 	var names, ids, tags []string
 	var config string
+	var jsonnet jsonnetFlags
 	cmd := &cobra.Command{
 		Use:               "logs [name|tag|id]...",
 		Short:             "watch for processes logs",
@@ -224,7 +243,7 @@ var _cmdLogs = func() *cobra.Command {
 			ctx := cmd.Context()
 			config := fun.IF(cmd.Flags().Lookup("config").Changed, &config, nil)
 
-			procs, err := getProcs(dbb, args, ids, names, tags, config)
+			procs, err := getProcs(dbb, args, ids, names, tags, config, jsonnet)
 			if err != nil {
 				return errors.Wrapf(err, "get proc ids")
 			}
@@ -250,9 +269,13 @@ var _cmdLogs = func() *cobra.Command {
 					lineColor := fun.Switch(line.Type, scuf.FgRed).
 						Case(scuf.FgHiWhite, core.LogTypeStdout).
 						Case(scuf.FgHiBlack, core.LogTypeStderr).
+						Case(scuf.FgHiBlue, core.LogTypeBuild).
 						End()
 
-					barColor := fun.IF(line.Type == core.LogTypeStdout, _barStdout, _barStderr)
+					barColor := fun.Switch(line.Type, _barStderr).
+						Case(_barStdout, core.LogTypeStdout).
+						Case(_barBuild, core.LogTypeBuild).
+						End()
 
 					pad = max(pad, len(line.ProcName))
 					// {proc} {pad}{sep} {line}
@@ -266,7 +289,7 @@ var _cmdLogs = func() *cobra.Command {
 		},
 	}
 	addFlagGenerics(cmd, filter, &names, &tags, &ids)
-	addFlagConfig(cmd, &config)
+	addFlagConfig(cmd, &config, &jsonnet)
 	return cmd
 }()
 
@@ -0,0 +1,174 @@
+package cli
+
+import (
+	"context"
+	"net"
+	"strconv"
+
+	"github.com/rprtr258/fun"
+
+	"github.com/rprtr258/pm/internal/core"
+	"github.com/rprtr258/pm/internal/db"
+	"github.com/rprtr258/pm/internal/errors"
+)
+
+// portAllocator allocates free ports from configured range for processes.
+// Ports looked up in configs for processes not added yet are kept pending,
+// so that they are assigned when processes are added.
+type portAllocator struct {
+	pending map[string][]core.Port // by process name
+}
+
+var _ports = &portAllocator{
+	pending: map[string][]core.Port{},
+}
+
+// used ports by all processes in db and pending ones
+func (a *portAllocator) used(procs map[core.PMID]core.Proc) map[int]struct{} {
+	res := map[int]struct{}{}
+	for _, proc := range procs {
+		for _, port := range proc.Ports {
+			res[port.Port] = struct{}{}
+		}
+	}
+	for _, ports := range a.pending {
+		for _, port := range ports {
+			res[port.Port] = struct{}{}
+		}
+	}
+	return res
+}
+
+// allocate first port from range which is neither used by processes nor
+// taken by someone else
+func (a *portAllocator) allocate(used map[int]struct{}) (int, error) {
+	from, to := cfg.Ports()
+	for port := from; port <= to; port++ {
+		if _, ok := used[port]; ok {
+			continue
+		}
+
+		l, err := (&net.ListenConfig{}).Listen(context.Background(), "tcp", ":"+strconv.Itoa(port)) //nolint:exhaustruct // defaults
+		if err != nil {
+			// taken by someone else
+			continue
+		}
+		_ = l.Close()
+
+		used[port] = struct{}{}
+		return port, nil
+	}
+
+	return 0, errors.Newf("no free ports in range %d-%d", from, to)
+}
+
+// assign ports with given names to process, keeping already assigned ones
+func (a *portAllocator) assign(db db.Handle, procName string, names []string, assigned []core.Port) ([]core.Port, error) {
+	procs, err := db.List(core.WithAllIfNoFilters)
+	if err != nil {
+		return nil, errors.Wrapf(err, "get procs")
+	}
+
+	used := a.used(procs)
+	pending := a.pending[procName]
+	delete(a.pending, procName)
+
+	return fun.MapErr[core.Port](func(name string) (core.Port, error) {
+		for _, ports := range [][]core.Port{assigned, pending} {
+			for _, port := range ports {
+				if port.Name == name {
+					return port, nil
+				}
+			}
+		}
+
+		port, err := a.allocate(used)
+		if err != nil {
+			return fun.Zero[core.Port](), errors.Wrapf(err, "allocate port %q", name)
+		}
+
+		return core.Port{
+			Name: name,
+			Port: port,
+		}, nil
+	}, names...)
+}
+
+// reassign ports to process, keeping ones which are not used by other
+// processes, e.g. ones saved in snapshot
+func (a *portAllocator) reassign(db db.Handle, procName string, ports []core.Port) ([]core.Port, error) {
+	procs, err := db.List(core.WithAllIfNoFilters)
+	if err != nil {
+		return nil, errors.Wrapf(err, "get procs")
+	}
+
+	used := a.used(procs)
+	free := fun.Filter(func(port core.Port) bool {
+		_, ok := used[port.Port]
+		return !ok
+	}, ports...)
+
+	names := fun.Map[string](func(port core.Port) string {
+		return port.Name
+	}, ports...)
+	return a.assign(db, procName, names, free)
+}
+
+// assigned port of process or pending one
+func (a *portAllocator) assigned(procs map[core.PMID]core.Proc, procName, portName string) (int, bool) {
+	for _, proc := range procs {
+		if proc.Name != procName {
+			continue
+		}
+
+		for _, port := range proc.Ports {
+			if port.Name == portName {
+				return port.Port, true
+			}
+		}
+	}
+
+	for _, port := range a.pending[procName] {
+		if port.Name == portName {
+			return port.Port, true
+		}
+	}
+
+	return 0, false
+}
+
+// peek port of process without allocating it, zero is returned if process
+// does not have it yet. Used for configs which are not run, e.g. to select
+// processes by them.
+func (a *portAllocator) peek(procName, portName string) (int, error) {
+	procs, err := dbb.List(core.WithAllIfNoFilters)
+	if err != nil {
+		return 0, errors.Wrapf(err, "get procs")
+	}
+
+	port, _ := a.assigned(procs, procName, portName)
+	return port, nil
+}
+
+// lookup port of process, allocating it if process does not have it yet
+func (a *portAllocator) lookup(procName, portName string) (int, error) {
+	procs, err := dbb.List(core.WithAllIfNoFilters)
+	if err != nil {
+		return 0, errors.Wrapf(err, "get procs")
+	}
+
+	if port, ok := a.assigned(procs, procName, portName); ok {
+		return port, nil
+	}
+
+	port, err := a.allocate(a.used(procs))
+	if err != nil {
+		return 0, err
+	}
+
+	a.pending[procName] = append(a.pending[procName], core.Port{
+		Name: portName,
+		Port: port,
+	})
+	return port, nil
+}
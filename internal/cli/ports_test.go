@@ -0,0 +1,149 @@
+package cli
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"testing"
+
+	"github.com/shoenig/test"
+	"github.com/spf13/afero"
+
+	"github.com/rprtr258/pm/internal/core"
+	"github.com/rprtr258/pm/internal/db"
+)
+
+func newPortAllocator() *portAllocator {
+	return &portAllocator{
+		pending: map[string][]core.Port{},
+	}
+}
+
+// addProcWithPorts to db, returning its id
+func addProcWithPorts(t *testing.T, h db.Handle, name string, ports ...core.Port) core.PMID {
+	t.Helper()
+
+	var query db.CreateQuery
+	query.Name = name
+	query.Command = "./" + name
+	query.Ports = ports
+	id, err := h.AddProc(query, "/logs")
+	test.NoError(t, err)
+	return id
+}
+
+func TestAllocate(t *testing.T) {
+	t.Parallel()
+
+	a := newPortAllocator()
+	from, to := cfg.Ports()
+
+	used := map[int]struct{}{}
+	first, err := a.allocate(used)
+	test.NoError(t, err)
+	test.Between(t, from, first, to)
+	test.MapContainsKey(t, used, first)
+
+	// used ports are skipped
+	second, err := a.allocate(used)
+	test.NoError(t, err)
+	test.Between(t, from, second, to)
+	test.NotEq(t, first, second)
+
+	// ports taken by someone else are skipped
+	l, err := (&net.ListenConfig{}).Listen(context.Background(), "tcp", ":"+strconv.Itoa(first)) //nolint:exhaustruct // defaults
+	test.NoError(t, err)
+	defer l.Close()
+
+	port, err := a.allocate(map[int]struct{}{})
+	test.NoError(t, err)
+	test.NotEq(t, first, port)
+
+	// all ports are used
+	used = map[int]struct{}{}
+	for port := from; port <= to; port++ {
+		used[port] = struct{}{}
+	}
+	_, err = a.allocate(used)
+	test.EqError(t, err, "no free ports in range 20000-29999")
+}
+
+func TestAssign(t *testing.T) {
+	t.Parallel()
+
+	h := db.New(afero.NewMemMapFs())
+	addProcWithPorts(t, h, "other", core.Port{Name: "http", Port: 20000})
+
+	a := newPortAllocator()
+	a.pending["web"] = []core.Port{{Name: "admin", Port: 20001}}
+
+	ports, err := a.assign(h, "web", []string{"http", "admin", "metrics"}, []core.Port{{Name: "http", Port: 20005}})
+	test.NoError(t, err)
+	test.SliceLen(t, 3, ports)
+	// assigned and pending ports are kept
+	test.Eq(t, core.Port{Name: "http", Port: 20005}, ports[0])
+	test.Eq(t, core.Port{Name: "admin", Port: 20001}, ports[1])
+	// new port is not used by other processes nor pending ones
+	test.EqOp(t, "metrics", ports[2].Name)
+	test.NotEq(t, 20000, ports[2].Port)
+	test.NotEq(t, 20001, ports[2].Port)
+	test.NotEq(t, 20005, ports[2].Port)
+	// pending ports are consumed
+	test.MapNotContainsKey(t, a.pending, "web")
+}
+
+func TestReassign(t *testing.T) {
+	t.Parallel()
+
+	h := db.New(afero.NewMemMapFs())
+	addProcWithPorts(t, h, "other", core.Port{Name: "http", Port: 20000})
+
+	a := newPortAllocator()
+	ports, err := a.reassign(h, "web", []core.Port{
+		{Name: "http", Port: 20000},
+		{Name: "admin", Port: 20007},
+	})
+	test.NoError(t, err)
+	test.SliceLen(t, 2, ports)
+	// port taken by other process since snapshot is reallocated
+	test.EqOp(t, "http", ports[0].Name)
+	test.NotEq(t, 20000, ports[0].Port)
+	// free port is kept
+	test.Eq(t, core.Port{Name: "admin", Port: 20007}, ports[1])
+}
+
+//nolint:paralleltest // uses global db
+func TestLookupPeek(t *testing.T) {
+	a := newPortAllocator()
+
+	addProcWithPorts(t, dbb, "lookup-added", core.Port{Name: "http", Port: 20003})
+
+	// port of added process
+	port, err := a.lookup("lookup-added", "http")
+	test.NoError(t, err)
+	test.EqOp(t, 20003, port)
+
+	// process not added yet has no port until it is looked up
+	port, err = a.peek("lookup-new", "http")
+	test.NoError(t, err)
+	test.EqOp(t, 0, port)
+
+	allocated, err := a.lookup("lookup-new", "http")
+	test.NoError(t, err)
+	test.NotEq(t, 20003, allocated)
+	test.Eq(t, []core.Port{{Name: "http", Port: allocated}}, a.pending["lookup-new"])
+
+	// same port is returned until process is added
+	port, err = a.lookup("lookup-new", "http")
+	test.NoError(t, err)
+	test.EqOp(t, allocated, port)
+
+	port, err = a.peek("lookup-new", "http")
+	test.NoError(t, err)
+	test.EqOp(t, allocated, port)
+
+	// pending port is assigned to added process
+	ports, err := a.assign(dbb, "lookup-new", []string{"http"}, nil)
+	test.NoError(t, err)
+	test.Eq(t, []core.Port{{Name: "http", Port: allocated}}, ports)
+}
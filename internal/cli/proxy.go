@@ -0,0 +1,344 @@
+package cli
+
+import (
+	"context"
+	stdErrors "errors"
+	"html/template"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rprtr258/fun"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+
+	"github.com/rprtr258/pm/internal/core"
+	"github.com/rprtr258/pm/internal/errors"
+	"github.com/rprtr258/pm/internal/eventlog"
+)
+
+const (
+	_proxyDomain          = ".localhost"
+	_proxyRefreshInterval = time.Second
+	_proxyEventsTail      = 64 << 10 // bytes of events log end to look up last events in
+)
+
+// proxyRoute is process and its port request is routed to
+type proxyRoute struct {
+	name   string // process or group name
+	port   string // port name, first port if empty
+	prefix string // path prefix to strip, if routed by path
+}
+
+// parseProxyRoute from host like "api.localhost" or "metrics.api.localhost",
+// otherwise from path prefix like "/api/"
+func parseProxyRoute(r *http.Request) (proxyRoute, bool) {
+	host, _, err := net.SplitHostPort(r.Host)
+	if err != nil {
+		host = r.Host
+	}
+
+	if subdomain, ok := strings.CutSuffix(host, _proxyDomain); ok {
+		port, name, ok := strings.Cut(subdomain, ".")
+		if !ok {
+			port, name = "", subdomain
+		}
+		return proxyRoute{
+			name:   name,
+			port:   port,
+			prefix: "",
+		}, name != ""
+	}
+
+	name, _, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	return proxyRoute{
+		name:   name,
+		port:   "",
+		prefix: "/" + name,
+	}, name != ""
+}
+
+// proxyPort of process: allocated port with given name, first allocated port
+// or first tcp listen socket
+func proxyPort(proc core.Proc, name string) (int, bool) {
+	for i, port := range proc.Ports {
+		if name == port.Name || name == "" && i == 0 {
+			return port.Port, true
+		}
+	}
+	if name != "" {
+		return 0, false
+	}
+
+	for _, addr := range proc.Listen {
+		network, address, err := core.ParseListenAddress(addr)
+		if err != nil || network == "unix" {
+			continue
+		}
+
+		_, portStr, err := net.SplitHostPort(address)
+		if err != nil {
+			continue
+		}
+
+		if port, err := strconv.Atoi(portStr); err == nil {
+			return port, true
+		}
+	}
+	return 0, false
+}
+
+var _proxyErrorTemplate = template.Must(template.New("proxy error").Parse(`<!doctype html>
+<html>
+<head><title>pm: {{.Name}} is unavailable</title></head>
+<body style="font-family: sans-serif; margin: 3em">
+<h1>{{.Name}} is unavailable</h1>
+<p>{{.Message}}</p>
+{{range .Procs}}<p><b>{{.Name}}</b>: {{.Status}}{{with .LastEvent}}, last event: {{.}}{{end}}</p>
+{{end}}</body>
+</html>
+`))
+
+type proxyErrorProc struct {
+	Name      string
+	Status    string
+	LastEvent string
+}
+
+// lastEvents of processes, only recent events are looked up
+func lastEvents() map[core.PMID]core.Event {
+	events, err := eventlog.ReadTail(core.FileEvents, _proxyEventsTail)
+	if err != nil {
+		log.Error().Err(err).Msg("read events")
+	}
+
+	res := map[core.PMID]core.Event{}
+	for _, event := range events {
+		res[event.ProcID] = event
+	}
+	return res
+}
+
+// writeProxyError renders page describing why process is unavailable
+func writeProxyError(w http.ResponseWriter, name, message string, procs []core.ProcStat) {
+	events := lastEvents()
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusBadGateway)
+	if err := _proxyErrorTemplate.Execute(w, map[string]any{
+		"Name":    name,
+		"Message": message,
+		"Procs": fun.Map[proxyErrorProc](func(proc core.ProcStat) proxyErrorProc {
+			lastEvent := ""
+			if event, ok := events[proc.ID]; ok {
+				lastEvent = event.Time.Format(time.DateTime) + " " + string(event.Type)
+				if code, ok := event.ExitCode.Unpack(); ok {
+					lastEvent += " with code " + strconv.Itoa(code)
+				}
+				if event.Signal != "" {
+					lastEvent += " by " + event.Signal
+				}
+			}
+
+			return proxyErrorProc{
+				Name:      proc.Name,
+				Status:    proc.Status.String(),
+				LastEvent: lastEvent,
+			}
+		}, procs...),
+	}); err != nil {
+		log.Error().Err(err).Msg("render proxy error page")
+	}
+}
+
+// waitPort to accept connections
+func waitPort(ctx context.Context, port int) error {
+	dialer := &net.Dialer{Timeout: time.Second} //nolint:exhaustruct // defaults
+	for {
+		conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort("localhost", strconv.Itoa(port)))
+		if err == nil {
+			_ = conn.Close()
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+type proxy struct {
+	start        bool          // start stopped processes on request
+	startTimeout time.Duration // time to wait for started process port
+
+	startMu sync.Mutex
+	counter atomic.Uint64 // for round robin between replicas
+
+	procsMu sync.RWMutex
+	procs   []core.ProcStat // routes snapshot, to not read db and procfs on each request
+}
+
+// refreshProcs snapshot
+func (p *proxy) refreshProcs() {
+	procs := listProcs(dbb).Slice()
+
+	p.procsMu.Lock()
+	defer p.procsMu.Unlock()
+	p.procs = procs
+}
+
+// refreshProcsLoop updates processes snapshot periodically until ctx is done
+func (p *proxy) refreshProcsLoop(ctx context.Context) {
+	ticker := time.NewTicker(_proxyRefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.refreshProcs()
+		}
+	}
+}
+
+// routeProcs are processes or replicas of group with given name
+func (p *proxy) routeProcs(name string) []core.ProcStat {
+	p.procsMu.RLock()
+	defer p.procsMu.RUnlock()
+
+	return fun.Filter(func(ps core.ProcStat) bool {
+		return ps.Name == name || ps.Group == name
+	}, p.procs...)
+}
+
+func (p *proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	route, ok := parseProxyRoute(r)
+	if !ok {
+		http.Error(w, "pm: use <name>.localhost host or /<name>/ path to route to process", http.StatusNotFound)
+		return
+	}
+
+	procs := p.routeProcs(route.name)
+	if len(procs) == 0 {
+		http.Error(w, "pm: process "+strconv.Quote(route.name)+" not found", http.StatusNotFound)
+		return
+	}
+
+	running := fun.Filter(func(ps core.ProcStat) bool {
+		return ps.Status == core.StatusRunning
+	}, procs...)
+	if len(running) == 0 {
+		if !p.start {
+			writeProxyError(w, route.name, "process is not running", procs)
+			return
+		}
+
+		if err := p.startProcs(r.Context(), route, procs); err != nil {
+			log.Error().Err(err).Str("name", route.name).Msg("start process on request")
+			writeProxyError(w, route.name, "failed to start process: "+err.Error(), procs)
+			return
+		}
+		running = procs
+	}
+
+	proc := running[p.counter.Add(1)%uint64(len(running))]
+	port, ok := proxyPort(proc.Proc, route.port)
+	if !ok {
+		writeProxyError(w, route.name, "process has no port "+strconv.Quote(route.port), procs)
+		return
+	}
+
+	target := &url.URL{ //nolint:exhaustruct // only host is needed
+		Scheme: "http",
+		Host:   net.JoinHostPort("localhost", strconv.Itoa(port)),
+	}
+	reverseProxy := httputil.NewSingleHostReverseProxy(target)
+	reverseProxy.ErrorHandler = func(w http.ResponseWriter, _ *http.Request, err error) {
+		log.Error().Err(err).Str("name", proc.Name).Int("port", port).Msg("proxy request")
+		writeProxyError(w, route.name, "process does not respond: "+err.Error(), procs)
+	}
+
+	if route.prefix != "" {
+		r.URL.Path = "/" + strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, route.prefix), "/")
+		r.URL.RawPath = ""
+	}
+	reverseProxy.ServeHTTP(w, r)
+}
+
+// startProcs and wait for their ports to accept connections
+func (p *proxy) startProcs(ctx context.Context, route proxyRoute, procs []core.ProcStat) error {
+	p.startMu.Lock()
+	defer p.startMu.Unlock()
+
+	ids := fun.Map[core.PMID](func(ps core.ProcStat) core.PMID {
+		return ps.ID
+	}, procs...)
+	if err := implStart(dbb, ids...); err != nil {
+		return err
+	}
+	defer p.refreshProcs()
+
+	ctx, cancel := context.WithTimeout(ctx, p.startTimeout)
+	defer cancel()
+
+	for _, proc := range procs {
+		port, ok := proxyPort(proc.Proc, route.port)
+		if !ok {
+			continue
+		}
+
+		if err := waitPort(ctx, port); err != nil {
+			return errors.Wrapf(err, "wait for %s port %d", proc.Name, port)
+		}
+	}
+	return nil
+}
+
+var _cmdProxy = func() *cobra.Command {
+	var addr string
+	var start bool
+	var startTimeout time.Duration
+	cmd := &cobra.Command{
+		Use:   "proxy",
+		Short: "route <name>.localhost and /<name>/ http requests to processes ports",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			handler := &proxy{ //nolint:exhaustruct // zero mutexes and counter
+				start:        start,
+				startTimeout: startTimeout,
+			}
+			handler.refreshProcs()
+			go handler.refreshProcsLoop(cmd.Context())
+
+			server := &http.Server{ //nolint:exhaustruct // defaults
+				Addr:              addr,
+				Handler:           handler,
+				ReadHeaderTimeout: 10 * time.Second,
+			}
+
+			go func() {
+				<-cmd.Context().Done()
+				_ = server.Close()
+			}()
+
+			log.Info().Str("addr", addr).Msg("proxy is listening")
+			if err := server.ListenAndServe(); err != nil && !stdErrors.Is(err, http.ErrServerClosed) {
+				return errors.Wrapf(err, "serve proxy")
+			}
+
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&addr, "addr", "localhost:8080", "address to listen on")
+	cmd.Flags().BoolVar(&start, "start", false, "start stopped process on first request")
+	cmd.Flags().DurationVar(&startTimeout, "start-timeout", 30*time.Second, "time to wait for started process port")
+	return cmd
+}()
@@ -0,0 +1,125 @@
+package cli
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/shoenig/test"
+
+	"github.com/rprtr258/pm/internal/core"
+)
+
+func TestParseProxyRoute(t *testing.T) {
+	t.Parallel()
+
+	for name, tc := range map[string]struct {
+		host string
+		path string
+		want proxyRoute
+		ok   bool
+	}{
+		"host": {
+			host: "api.localhost",
+			path: "/users",
+			want: proxyRoute{name: "api", port: "", prefix: ""},
+			ok:   true,
+		},
+		"host with port": {
+			host: "api.localhost:8080",
+			path: "/",
+			want: proxyRoute{name: "api", port: "", prefix: ""},
+			ok:   true,
+		},
+		"host with port name": {
+			host: "metrics.api.localhost",
+			path: "/",
+			want: proxyRoute{name: "api", port: "metrics", prefix: ""},
+			ok:   true,
+		},
+		"path": {
+			host: "localhost:8080",
+			path: "/api/users",
+			want: proxyRoute{name: "api", port: "", prefix: "/api"},
+			ok:   true,
+		},
+		"path without trailing slash": {
+			host: "127.0.0.1",
+			path: "/api",
+			want: proxyRoute{name: "api", port: "", prefix: "/api"},
+			ok:   true,
+		},
+		"root": {
+			host: "localhost",
+			path: "/",
+			want: proxyRoute{name: "", port: "", prefix: "/"},
+			ok:   false,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			r := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			r.Host = tc.host
+
+			got, ok := parseProxyRoute(r)
+			test.EqOp(t, tc.ok, ok)
+			test.EqOp(t, tc.want, got)
+		})
+	}
+}
+
+func TestProxyPort(t *testing.T) {
+	t.Parallel()
+
+	proc := func(listen []string, ports ...core.Port) core.Proc {
+		var proc core.Proc
+		proc.Listen = listen
+		proc.Ports = ports
+		return proc
+	}
+	ports := []core.Port{{Name: "http", Port: 20000}, {Name: "metrics", Port: 20001}}
+
+	for name, tc := range map[string]struct {
+		proc core.Proc
+		port string
+		want int
+		ok   bool
+	}{
+		"first port": {
+			proc: proc([]string{":8080"}, ports...),
+			want: 20000,
+			ok:   true,
+		},
+		"named port": {
+			proc: proc(nil, ports...),
+			port: "metrics",
+			want: 20001,
+			ok:   true,
+		},
+		"unknown port": {
+			proc: proc([]string{":8080"}, ports...),
+			port: "admin",
+		},
+		"listen socket": {
+			proc: proc([]string{"unix:/run/app.sock", "invalid", "tcp:127.0.0.1:8080", ":9090"}),
+			want: 8080,
+			ok:   true,
+		},
+		"named port of listen socket": {
+			proc: proc([]string{":8080"}),
+			port: "http",
+		},
+		"no ports": {
+			proc: proc([]string{"unix:/run/app.sock"}),
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			port, ok := proxyPort(tc.proc, tc.port)
+			test.EqOp(t, tc.ok, ok)
+			test.EqOp(t, tc.want, port)
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package cli
+
+import (
+	stdErrors "errors"
+	"fmt"
+	"slices"
+	"syscall"
+
+	"github.com/rprtr258/fun"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+
+	"github.com/rprtr258/pm/internal/core"
+	"github.com/rprtr258/pm/internal/db"
+	"github.com/rprtr258/pm/internal/errors"
+	"github.com/rprtr258/pm/internal/linuxprocess"
+)
+
+// _signalReload is sent to shim to make it reload child using configured strategy
+const _signalReload = syscall.SIGHUP
+
+// implReload asks shims to reload their children, processes which are not
+// running are just started
+func implReload(db db.Handle, ids ...core.PMID) error {
+	list := linuxprocess.List()
+
+	notRunning := []core.PMID{}
+	errs := fun.Map[error](func(id core.PMID) error {
+		proc, ok := linuxprocess.StatPMID(list, id)
+		if !ok {
+			notRunning = append(notRunning, id)
+			return nil
+		}
+
+		log.Debug().
+			Int("shim_pid", proc.ShimPID).
+			Str("id", id.String()).
+			Msg("sending reload signal to shim")
+		if errKill := syscall.Kill(proc.ShimPID, _signalReload); errKill != nil {
+			if stdErrors.Is(errKill, syscall.ESRCH) {
+				// shim is dead already, start proc again
+				notRunning = append(notRunning, id)
+				return nil
+			}
+
+			return errors.Wrapf(errKill, "reload pmid=%s, shim_pid=%d", id, proc.ShimPID)
+		}
+
+		return nil
+	}, ids...)
+
+	if len(notRunning) > 0 {
+		errs = append(errs, errors.Wrapf(implStart(db, notRunning...), "start not running procs"))
+	}
+
+	return errors.Combine(errs...)
+}
+
+var _cmdReload = func() *cobra.Command {
+	const filter = filterAll
+	var names, ids, tags []string
+	var config string
+	var jsonnet jsonnetFlags
+	var interactive bool
+	cmd := &cobra.Command{
+		Use:               "reload [name|tag|id]...",
+		Short:             "reload process(es) without downtime using configured strategy",
+		ValidArgsFunction: completeArgGenericSelector(filter),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			config := fun.IF(cmd.Flags().Lookup("config").Changed, &config, nil)
+
+			var filterFunc func(core.Proc) bool
+			if config != nil {
+				configs, errLoadConfigs := loadConfigs(*config, jsonnet.options()...)
+				if errLoadConfigs != nil {
+					return errors.Wrapf(errLoadConfigs, "load configs from %s", *config)
+				}
+
+				procNames := fun.Map[string](func(cfg core.RunConfig) string {
+					return cfg.Name
+				}, core.Replicas(configs...)...)
+
+				ff := core.FilterFunc(
+					core.WithGeneric(args...),
+					core.WithIDs(ids...),
+					core.WithNames(names...),
+					core.WithTags(tags...),
+					core.WithAllIfNoFilters,
+				)
+				filterFunc = func(proc core.Proc) bool {
+					return fun.Contains(proc.Name, procNames...) && ff(proc)
+				}
+			} else {
+				filterFunc = core.FilterFunc(
+					core.WithGeneric(args...),
+					core.WithIDs(ids...),
+					core.WithNames(names...),
+					core.WithTags(tags...),
+				)
+			}
+
+			procIDs := slices.Collect(listProcs(dbb).
+				Filter(func(ps core.ProcStat) bool {
+					return filterFunc(ps.Proc) &&
+						(!interactive || confirmProc(ps, "reload"))
+				}).
+				IDs())
+			if len(procIDs) == 0 {
+				fmt.Println("nothing to reload")
+				return nil
+			}
+
+			return errors.Wrapf(implReload(dbb, procIDs...), "reload")
+		},
+	}
+	addFlagInteractive(cmd, &interactive)
+	addFlagGenerics(cmd, filter, &names, &tags, &ids)
+	addFlagConfig(cmd, &config, &jsonnet)
+	return cmd
+}()
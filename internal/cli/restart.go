@@ -15,6 +15,7 @@ var _cmdRestart = func() *cobra.Command {
 	const filter = filterRunning
 	var names, ids, tags []string
 	var config string
+	var jsonnet jsonnetFlags
 	var interactive bool
 	cmd := &cobra.Command{
 		Use:               "restart [name|tag|id]...",
@@ -25,14 +26,14 @@ var _cmdRestart = func() *cobra.Command {
 
 			var filterFunc func(core.Proc) bool
 			if config != nil {
-				configs, errLoadConfigs := core.LoadConfigs(*config)
+				configs, errLoadConfigs := loadConfigs(*config, jsonnet.options()...)
 				if errLoadConfigs != nil {
 					return errors.Wrapf(errLoadConfigs, "load configs from %s", *config)
 				}
 
 				procNames := fun.Map[string](func(cfg core.RunConfig) string {
 					return cfg.Name
-				}, configs...)
+				}, core.Replicas(configs...)...)
 
 				ff := core.FilterFunc(
 					core.WithGeneric(args...),
@@ -77,6 +78,6 @@ var _cmdRestart = func() *cobra.Command {
 	}
 	addFlagInteractive(cmd, &interactive)
 	addFlagGenerics(cmd, filter, &names, &tags, &ids)
-	addFlagConfig(cmd, &config)
+	addFlagConfig(cmd, &config, &jsonnet)
 	return cmd
 }()
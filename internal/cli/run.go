@@ -3,14 +3,15 @@ package cli
 import (
 	"cmp"
 	"fmt"
+	"maps"
 	"os"
 	"os/exec"
 	"path/filepath"
-	"regexp"
+	"reflect"
 	"slices"
+	"syscall"
 	"time"
 
-	"github.com/adhocore/gronx"
 	"github.com/rprtr258/fun"
 	"github.com/rprtr258/fun/set"
 	"github.com/rs/zerolog/log"
@@ -20,6 +21,7 @@ import (
 	"github.com/rprtr258/pm/internal/core/namegen"
 	"github.com/rprtr258/pm/internal/db"
 	"github.com/rprtr258/pm/internal/errors"
+	"github.com/rprtr258/pm/internal/eventlog"
 	"github.com/rprtr258/pm/internal/linuxprocess"
 )
 
@@ -43,6 +45,13 @@ func compareTags(first, second []string) bool {
 }
 
 // compareArgs and return true if equal
+// compareCron configs, see core.Cron.Equal
+func compareCron(first, second fun.Option[core.Cron]) bool {
+	firstCron, okFirst := first.Unpack()
+	secondCron, okSecond := second.Unpack()
+	return okFirst == okSecond && (!okFirst || firstCron.Equal(secondCron))
+}
+
 func compareArgs(first, second []string) bool {
 	if len(first) != len(second) {
 		return false
@@ -88,69 +97,132 @@ func runProc(
 		}
 	}
 
-	id, errCreate := func() (core.PMID, error) {
-		watch := fun.OptMap(config.Watch, func(r *regexp.Regexp) string {
-			return r.String()
-		})
+	// upsert process record, if process is running and must be updated, it
+	// is reported instead, so that it is stopped without holding db lock and
+	// upsert is repeated with stopped set
+	upsert := func(stopped bool) (core.PMID, bool, error) {
+		// other pm invocations must not add process with same name meanwhile
+		unlock, err := dbb.Lock()
+		if err != nil {
+			return "", false, errors.Wrapf(err, "lock db")
+		}
+		defer unlock()
+
 		// try to find by name and update
 		procs, err := dbb.List(core.WithAllIfNoFilters)
 		if err != nil {
-			return "", errors.Wrapf(err, "get procs from db")
+			return "", false, errors.Wrapf(err, "get procs from db")
 		}
 
 		//nolint:nestif // fuck you
 		if procID, ok := fun.FindKeyBy(procs, func(_ core.PMID, procData core.Proc) bool {
 			return procData.Name == config.Name
 		}); ok {
+			ports, errPorts := _ports.assign(dbb, config.Name, config.Ports, procs[procID].Ports)
+			if errPorts != nil {
+				return "", false, errors.Wrapf(errPorts, "assign ports")
+			}
+
 			procData := core.Proc{
 				ID:          procID,
 				Name:        config.Name,
+				Group:       config.Group,
+				Instance:    config.Instance,
+				Profile:     config.Profile,
+				ConfigFile:  config.ConfigFile,
 				Cwd:         config.Cwd,
 				Tags:        fun.Uniq(append(config.Tags, "all")...),
 				Command:     command,
 				Args:        config.Args,
-				Watch:       watch,
+				Watch:       config.Watch,
+				Build:       config.Build,
 				Env:         config.Env,
 				StdoutFile:  config.StdoutFile.OrDefault(filepath.Join(dirLogs, fmt.Sprintf("%v.stdout", procID))),
 				StderrFile:  config.StderrFile.OrDefault(filepath.Join(dirLogs, fmt.Sprintf("%v.stderr", procID))),
 				Startup:     config.Startup,
-				KillTimeout: config.KillTimeout,
+				KillTimeout: cmp.Or(config.KillTimeout, 5*time.Second),
 				DependsOn:   config.DependsOn,
 				MaxRestarts: config.MaxRestarts,
 				Cron:        config.Cron,
+				StartDelay:  config.StartDelay,
+				Hooks:       config.Hooks,
+				Notify:      config.Notify,
+
+				StopSignal:   config.StopSignal,
+				StopCommand:  config.StopCommand,
+				StopSequence: config.StopSequence,
+
+				Listen: config.Listen,
+				Reload: config.Reload,
+
+				Lazy:        config.Lazy,
+				IdleTimeout: config.IdleTimeout,
+
+				Ports: ports,
 			}
 
 			proc := procs[procID]
+			// single run time set as duration is kept from first run of config
+			if compareCron(proc.Cron, procData.Cron) {
+				procData.Cron = proc.Cron
+			}
+
 			if proc.Cwd == procData.Cwd &&
+				proc.Group == procData.Group &&
+				proc.Instance == procData.Instance &&
 				compareTags(proc.Tags, procData.Tags) &&
 				proc.Command == procData.Command &&
 				compareArgs(proc.Args, procData.Args) &&
-				proc.Watch == procData.Watch {
+				maps.Equal(proc.Env, procData.Env) &&
+				proc.Profile == procData.Profile &&
+				proc.ConfigFile == procData.ConfigFile &&
+				reflect.DeepEqual(proc.Watch, procData.Watch) &&
+				reflect.DeepEqual(proc.Build, procData.Build) &&
+				compareCron(proc.Cron, procData.Cron) &&
+				proc.StartDelay == procData.StartDelay &&
+				reflect.DeepEqual(proc.Hooks, procData.Hooks) &&
+				reflect.DeepEqual(proc.Notify, procData.Notify) &&
+				proc.StopSignal == procData.StopSignal &&
+				reflect.DeepEqual(proc.StopCommand, procData.StopCommand) &&
+				slices.Equal(proc.StopSequence, procData.StopSequence) &&
+				slices.Equal(proc.Listen, procData.Listen) &&
+				proc.Reload == procData.Reload &&
+				proc.Lazy == procData.Lazy &&
+				proc.IdleTimeout == procData.IdleTimeout &&
+				slices.Equal(proc.Ports, procData.Ports) {
 				// not updated, do nothing
-				return procID, nil
+				return procID, false, nil
 			}
 
-			// proc updated, if it is running, stop it to start later
-			if _, ok := linuxprocess.StatPMID(dbb.ListRunning(), procID); ok {
-				if errStop := implStop(dbb, procID); errStop != nil {
-					return "", errors.Wrapf(errStop, "stop updated proc: %v", procID)
-				}
+			// proc updated, if it is running, it must be stopped to start later
+			if _, ok := linuxprocess.StatPMID(dbb.ListRunning(), procID); ok && !stopped {
+				return procID, true, nil
 			}
 
 			if errUpdate := dbb.UpdateProc(procData); errUpdate != nil {
-				return "", errors.Wrapf(errUpdate, "update proc: %v", procData)
+				return "", false, errors.Wrapf(errUpdate, "update proc: %v", procData)
 			}
 
-			return procID, nil
+			return procID, false, nil
+		}
+
+		ports, err := _ports.assign(dbb, config.Name, config.Ports, nil)
+		if err != nil {
+			return "", false, errors.Wrapf(err, "assign ports")
 		}
 
 		procID, err := dbb.AddProc(db.CreateQuery{
 			Name:        config.Name,
+			Group:       config.Group,
+			Instance:    config.Instance,
+			Profile:     config.Profile,
+			ConfigFile:  config.ConfigFile,
 			Cwd:         config.Cwd,
 			Tags:        fun.Uniq(append(config.Tags, "all")...),
 			Command:     command,
 			Args:        config.Args,
-			Watch:       watch,
+			Watch:       config.Watch,
+			Build:       config.Build,
 			Env:         config.Env,
 			StdoutFile:  config.StdoutFile,
 			StderrFile:  config.StderrFile,
@@ -159,13 +231,39 @@ func runProc(
 			DependsOn:   config.DependsOn,
 			MaxRestarts: config.MaxRestarts,
 			Cron:        config.Cron,
+			StartDelay:  config.StartDelay,
+			Hooks:       config.Hooks,
+			Notify:      config.Notify,
+
+			StopSignal:   config.StopSignal,
+			StopCommand:  config.StopCommand,
+			StopSequence: config.StopSequence,
+
+			Listen: config.Listen,
+			Reload: config.Reload,
+
+			Lazy:        config.Lazy,
+			IdleTimeout: config.IdleTimeout,
+
+			Ports: ports,
 		}, dirLogs)
 		if err != nil {
-			return "", errors.Wrapf(err, "save proc")
+			return "", false, errors.Wrapf(err, "save proc")
+		}
+
+		eventlog.Emit(core.NewEvent(core.EventCreated, procID, config.Name))
+
+		return procID, false, nil
+	}
+
+	id, running, errCreate := upsert(false)
+	if errCreate == nil && running {
+		if errStop := implStop(dbb, id); errStop != nil {
+			return "", "", errors.Wrapf(errStop, "stop updated proc: %v", id)
 		}
 
-		return procID, nil
-	}()
+		id, _, errCreate = upsert(true)
+	}
 	if errCreate != nil {
 		return "", "", errors.Wrapf(errCreate, "server.create: %v", config)
 	}
@@ -174,17 +272,117 @@ func runProc(
 	return id, config.Name, err
 }
 
+// sortByDependencies topologically, so that each item goes after items it
+// depends on. Dependencies not in items are ignored.
+func sortByDependencies[T any](items []T, deps func(T) (string, []string)) ([]T, error) {
+	indexByName := fun.SliceToMap[string, int](
+		func(item T, i int) (string, int) {
+			name, _ := deps(item)
+			return name, i
+		},
+		items...)
+
+	type visitStatus int8
+	const (
+		statusNotVisited visitStatus = iota
+		statusInProgress
+		statusProcessed
+	)
+	loopFound := false
+	res := []int{} // indices in items slice
+	visited := make([]visitStatus, len(items))
+	var dfs func(int)
+	dfs = func(i int) {
+		switch visited[i] {
+		case statusInProgress:
+			loopFound = true
+			log.Error().
+				Strs("loop", fun.Map[string](func(i int) string {
+					name, _ := deps(items[i])
+					return name
+				}, res...)).
+				Msg("loop found")
+		case statusProcessed:
+		case statusNotVisited:
+			visited[i] = statusInProgress
+			_, dependencies := deps(items[i])
+			for _, dependency := range dependencies {
+				j, ok := indexByName[dependency]
+				if !ok {
+					continue
+				}
+
+				dfs(j)
+				if loopFound {
+					return
+				}
+			}
+			res = append(res, i)
+			visited[i] = statusProcessed
+		}
+	}
+	for i := range items {
+		dfs(i)
+		if loopFound {
+			return nil, errors.Newf("loop found")
+		}
+	}
+
+	// actually sort items by indices in res slice
+	return fun.Map[T](func(i int) T { return items[i] }, res...), nil
+}
+
+// procNames of added processes and their replica groups
+func procNames() []string {
+	return slices.Collect(func(yield func(string) bool) {
+		for ps := range listProcs(dbb).Seq {
+			if !yield(ps.Name) {
+				break
+			}
+			if ps.Group != "" && !yield(ps.Group) {
+				break
+			}
+		}
+	})
+}
+
+// recordedProfile of processes run from config file or files of config dir,
+// so that running config again without --profile keeps profile processes
+// were run with. Configs are not evaluated, since evaluation might depend on
+// profile and have side effects.
+func recordedProfile(path string) string {
+	filenames, err := core.ConfigFilenames(path)
+	if err != nil {
+		return ""
+	}
+
+	for i, filename := range filenames {
+		if abs, err := filepath.Abs(filename); err == nil {
+			filenames[i] = abs
+		}
+	}
+
+	profiles := fun.Uniq(fun.Map[string](func(ps core.ProcStat) string {
+		return ps.Profile
+	}, listProcs(dbb).
+		Filter(func(ps core.ProcStat) bool { return fun.Contains(ps.ConfigFile, filenames...) }).
+		Slice()...)...)
+	if len(profiles) != 1 || profiles[0] == "" {
+		return ""
+	}
+
+	log.Info().Str("profile", profiles[0]).Msg("using profile processes were run with")
+	return profiles[0]
+}
+
 func runProcs(db db.Handle, dirLogs string, configs ...core.RunConfig) error {
+	groups := configs
+	configs = core.Replicas(configs...)
+
 	// depends_on validation
 	{
 		// collect all names from db and configs list
-		allNames := set.NewFrom(slices.Collect(func(yield func(string) bool) {
-			for ps := range listProcs(dbb).Seq {
-				if !yield(ps.Name) {
-					break
-				}
-			}
-		})...)
+		allNames := set.NewFrom(procNames()...)
 		for _, config := range configs {
 			allNames.Add(config.Name)
 		}
@@ -209,52 +407,13 @@ func runProcs(db db.Handle, dirLogs string, configs ...core.RunConfig) error {
 	}
 
 	// sort procs by depends_on
-	{
-		indexByName := fun.SliceToMap[string, int](
-			func(proc core.RunConfig, i int) (string, int) { return proc.Name, i },
-			configs...)
-
-		// topological sort
-		type visitStatus int8
-		const (
-			statusNotVisited visitStatus = iota
-			statusInProgress
-			statusProcessed
-		)
-		loopFound := false
-		res := []int{} // indices in configs slice
-		visited := make([]visitStatus, len(configs))
-		var dfs func(int)
-		dfs = func(i int) {
-			switch visited[i] {
-			case statusInProgress:
-				loopFound = true
-				log.Error().
-					Strs("loop", fun.Map[string](func(i int) string { return configs[i].Name }, res...)).
-					Msg("loop found")
-			case statusProcessed:
-			case statusNotVisited:
-				visited[i] = statusInProgress
-				for _, dependency := range configs[i].DependsOn {
-					dfs(indexByName[dependency])
-					if loopFound {
-						return
-					}
-				}
-				res = append(res, i)
-				visited[i] = statusProcessed
-			}
-		}
-		for i := range configs {
-			dfs(i)
-			if loopFound {
-				return errors.Newf("loop found")
-			}
-		}
-
-		// actually sort procs by indices in res slice
-		configs = fun.Map[core.RunConfig](func(i int) core.RunConfig { return configs[i] }, res...)
+	sorted, errSort := sortByDependencies(configs, func(config core.RunConfig) (string, []string) {
+		return config.Name, config.DependsOn
+	})
+	if errSort != nil {
+		return errSort
 	}
+	configs = sorted
 
 	var merr []error
 	for _, config := range configs {
@@ -264,13 +423,20 @@ func runProcs(db db.Handle, dirLogs string, configs ...core.RunConfig) error {
 			fmt.Println(name)
 		}
 	}
+	// remove replicas left after decreasing number of instances
+	for _, config := range groups {
+		if errRemove := removeReplicas(db, dirLogs, config.Name, config.Instances); errRemove != nil {
+			merr = append(merr, errors.Wrapf(errRemove, "remove extra replicas of %q", config.Name))
+		}
+	}
 	return errors.Combine(merr...)
 }
 
 var _cmdRun = func() *cobra.Command {
-	var name, cwd, config, watch, cron string
+	var name, cwd, config, watch, cron, profile string
+	var jsonnet jsonnetFlags
 	var tags []string
-	var maxRestarts uint
+	var maxRestarts, instances uint
 	cmd := &cobra.Command{
 		Use:   "run",
 		Short: "create and run new process",
@@ -280,6 +446,7 @@ var _cmdRun = func() *cobra.Command {
 			config := fun.IF(cmd.Flags().Lookup("config").Changed, &config, nil)
 			watch := fun.IF(cmd.Flags().Lookup("watch").Changed, &watch, nil)
 			cron := fun.IF(cmd.Flags().Lookup("cron").Changed, &cron, nil)
+			profile := fun.IF(cmd.Flags().Lookup("profile").Changed, &profile, nil)
 
 			if config == nil { // inline run, e.g. `pm run -- npm dev`
 				if len(posArgs) == 0 {
@@ -298,20 +465,25 @@ var _cmdRun = func() *cobra.Command {
 					workDir = *cwd
 				}
 
-				var watchOpt fun.Option[*regexp.Regexp]
+				var watchOpt fun.Option[core.Watch]
 				if pattern := watch; pattern != nil {
-					watchRE, errCompile := regexp.Compile(*pattern)
-					if errCompile != nil {
+					watchCfg := core.WatchRegex(*pattern)
+					if _, errCompile := core.CompileWatchPattern(watchCfg.Include[0]); errCompile != nil {
 						return errors.Wrapf(errCompile, "compile watch regex: %q", *pattern)
 					}
 
-					watchOpt = fun.Valid(watchRE)
+					watchOpt = fun.Valid(watchCfg)
 				}
 
-				if cron != nil && !gronx.IsValid(*cron) {
-					return errors.Newf("invalid cron expression: %q", *cron)
+				var cronOpt fun.Option[core.Cron]
+				if expr := cron; expr != nil {
+					cronCfg := core.CronSchedule(*expr)
+					if errValidate := cronCfg.Validate(); errValidate != nil {
+						return errValidate
+					}
+
+					cronOpt = fun.Valid(cronCfg)
 				}
-				cronOpt := fun.FromPtr(cron)
 
 				runConfig := core.RunConfig{
 					Command:     command,
@@ -321,6 +493,7 @@ var _cmdRun = func() *cobra.Command {
 					Cwd:         workDir,
 					Env:         nil,
 					Watch:       watchOpt,
+					Build:       fun.Invalid[core.Hook](),
 					StdoutFile:  fun.Invalid[string](),
 					StderrFile:  fun.Invalid[string](),
 					KillTimeout: 0,
@@ -329,12 +502,42 @@ var _cmdRun = func() *cobra.Command {
 					Startup:     false,
 					DependsOn:   nil,
 					Cron:        cronOpt,
+					StartDelay:  0,
+					Hooks:       fun.Zero[core.Hooks](),
+					Notify:      fun.Invalid[core.Notify](),
+
+					StopSignal:   fun.Invalid[syscall.Signal](),
+					StopCommand:  fun.Invalid[core.Hook](),
+					StopSequence: nil,
+
+					Listen: nil,
+					Reload: fun.Invalid[core.Reload](),
+
+					Lazy:        false,
+					IdleTimeout: 0,
+
+					Ports: nil,
+
+					Instances: instances,
+					Group:     "",
+					Instance:  0,
+
+					Profile:    "",
+					ConfigFile: "",
 				}
 
 				return runProcs(dbb, core.DirLogs, runConfig)
 			}
 
-			configs, errLoadConfigs := core.LoadConfigs(*config)
+			if profile == nil {
+				profile = fun.Ptr(recordedProfile(*config))
+			}
+
+			configs, errLoadConfigs := loadConfigs(*config, append(jsonnet.options(),
+				core.WithPortLookup(_ports.lookup),
+				core.WithProcNames(procNames()...),
+				core.WithProfile(*profile),
+			)...)
 			if errLoadConfigs != nil {
 				return errors.Wrapf(errLoadConfigs, "load run configs")
 			}
@@ -372,9 +575,11 @@ var _cmdRun = func() *cobra.Command {
 	cmd.Flags().StringVarP(&name, "name", "n", "", "set a name for the process")
 	cmd.Flags().StringSliceVarP(&tags, "tag", "t", nil, "add specified tag")
 	cmd.Flags().StringVar(&cwd, "cwd", "", "set working directory")
-	addFlagConfig(cmd, &config)
+	addFlagConfig(cmd, &config, &jsonnet)
+	addFlagProfile(cmd, &profile)
 	cmd.Flags().StringVar(&watch, "watch", "", "restart on changes to files matching specified regex")
-	cmd.Flags().StringVar(&cron, "cron", "", "restart periodically on cron expression")
+	cmd.Flags().StringVar(&cron, "cron", "", "run as job on cron expression schedule")
 	cmd.Flags().UintVar(&maxRestarts, "max-restarts", 0, "autorestart process, giving up after COUNT times")
+	cmd.Flags().UintVar(&instances, "instances", 0, "run COUNT replicas of process")
 	return cmd
 }()
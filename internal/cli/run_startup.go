@@ -1,6 +1,9 @@
 package cli
 
 import (
+	stdErrors "errors"
+	"io/fs"
+
 	"github.com/rprtr258/fun"
 	"github.com/spf13/cobra"
 
@@ -8,20 +11,51 @@ import (
 	"github.com/rprtr258/pm/internal/errors"
 )
 
+// runUserConfigs runs apps from config files of per-user config dir, if any
+func runUserConfigs() error {
+	filenames, err := core.ConfigFilenames(core.DirUserConfigs)
+	if err != nil {
+		if stdErrors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return errors.Wrapf(err, "list config files of %s", core.DirUserConfigs)
+	}
+
+	// dir might have only readme, libraries and such
+	if len(filenames) == 0 {
+		return nil
+	}
+
+	configs, err := loadConfigs(core.DirUserConfigs,
+		core.WithPortLookup(_ports.lookup),
+		core.WithProcNames(procNames()...),
+	)
+	if err != nil {
+		return errors.Wrapf(err, "load configs from %s", core.DirUserConfigs)
+	}
+
+	return runProcs(dbb, core.DirLogs, configs...)
+}
+
 var _cmdRunStartup = &cobra.Command{
 	Use:    "_startup",
 	Short:  "run startup processes",
 	Args:   cobra.NoArgs,
 	Hidden: true,
 	RunE: func(*cobra.Command, []string) error {
+		errUserConfigs := runUserConfigs()
+
 		procsToStart := listProcs(dbb).
 			Filter(func(p core.ProcStat) bool {
 				return p.Startup && p.Status != core.StatusRunning
 			}).
 			Slice()
 
-		return errors.Combine(fun.Map[error](func(proc core.ProcStat) error {
-			return implStart(dbb, proc.ID)
-		}, procsToStart...)...)
+		return errors.Combine(append(
+			[]error{errUserConfigs},
+			fun.Map[error](func(proc core.ProcStat) error {
+				return implStart(dbb, proc.ID)
+			}, procsToStart...)...,
+		)...)
 	},
 }
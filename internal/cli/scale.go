@@ -0,0 +1,155 @@
+package cli
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strconv"
+
+	"github.com/rprtr258/fun"
+	"github.com/spf13/cobra"
+
+	"github.com/rprtr258/pm/internal/core"
+	"github.com/rprtr258/pm/internal/db"
+	"github.com/rprtr258/pm/internal/errors"
+	"github.com/rprtr258/pm/internal/eventlog"
+)
+
+// listReplicas of group sorted by instance index
+func listReplicas(db db.Handle, group string) ([]core.Proc, error) {
+	procs, err := db.List(core.WithAllIfNoFilters)
+	if err != nil {
+		return nil, errors.Wrapf(err, "get procs")
+	}
+
+	replicas := fun.Filter(func(proc core.Proc) bool {
+		return proc.Group == group
+	}, fun.Values(procs)...)
+	slices.SortFunc(replicas, func(a, b core.Proc) int {
+		return cmp.Compare(a.Instance, b.Instance)
+	})
+	return replicas, nil
+}
+
+// removeReplicas stops and deletes replicas of group with index n or greater
+func removeReplicas(db db.Handle, dirLogs, group string, n uint) error {
+	replicas, err := listReplicas(db, group)
+	if err != nil {
+		return err
+	}
+
+	ids := fun.FilterMap[core.PMID](func(proc core.Proc) (core.PMID, bool) {
+		return proc.ID, proc.Instance >= int(n) //nolint:gosec // number of instances is small
+	}, replicas...)
+	if len(ids) == 0 {
+		return nil
+	}
+
+	if errStop := implStop(db, ids...); errStop != nil {
+		return errors.Wrapf(errStop, "stop replicas")
+	}
+
+	return errors.Wrapf(implDelete(db, dirLogs, ids...), "delete replicas")
+}
+
+// addReplicas of group missing to have n replicas, returns ids of added ones
+func addReplicas(db db.Handle, dirLogs, group string, n uint) ([]core.PMID, error) {
+	// other pm invocations must not add same replicas meanwhile
+	unlock, err := db.Lock()
+	if err != nil {
+		return nil, errors.Wrapf(err, "lock db")
+	}
+	defer unlock()
+
+	replicas, err := listReplicas(db, group)
+	if err != nil {
+		return nil, err
+	}
+	if len(replicas) == 0 {
+		return nil, errors.Newf("process group %q not found", group)
+	}
+
+	template := replicas[0]
+	instances := fun.Map[int](func(proc core.Proc) int {
+		return proc.Instance
+	}, replicas...)
+
+	portNames := fun.Map[string](func(port core.Port) string {
+		return port.Name
+	}, template.Ports...)
+
+	added := []core.PMID{}
+	for i := range int(n) { //nolint:gosec // number of instances is small
+		if slices.Contains(instances, i) {
+			continue
+		}
+
+		name := core.InstanceName(group, i)
+		replica := template
+		var errPorts error
+		replica.Ports, errPorts = _ports.assign(db, name, portNames, nil)
+		if errPorts != nil {
+			return nil, errors.Wrapf(errPorts, "assign ports to replica #%d", i)
+		}
+
+		id, errAdd := db.AddReplica(replica, i, dirLogs)
+		if errAdd != nil {
+			return nil, errors.Wrapf(errAdd, "add replica #%d", i)
+		}
+
+		eventlog.Emit(core.NewEvent(core.EventCreated, id, name))
+		fmt.Println(name)
+		added = append(added, id)
+	}
+
+	return added, nil
+}
+
+// implScale adds or removes replicas of group, so that there are exactly n
+// of them. Db is locked only while records are changed, replicas are
+// started and stopped after, so that slow replicas do not block other pm
+// invocations.
+func implScale(db db.Handle, dirLogs, group string, n uint) error {
+	if n == 0 {
+		return errors.New("number of instances must be positive, use delete command to remove all replicas")
+	}
+
+	added, err := addReplicas(db, dirLogs, group, n)
+	if err != nil {
+		return err
+	}
+
+	if errStart := implStart(db, added...); errStart != nil {
+		return errors.Wrapf(errStart, "start replicas")
+	}
+
+	return removeReplicas(db, dirLogs, group, n)
+}
+
+var _cmdScale = &cobra.Command{
+	Use:   "scale name count",
+	Short: "set number of replicas of process group",
+	Args:  cobra.ExactArgs(2),
+	ValidArgsFunction: func(_ *cobra.Command, args []string, _ string) ([]string, cobra.ShellCompDirective) {
+		if len(args) > 0 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+
+		groups := map[string]struct{}{}
+		for proc := range listProcs(dbb).Seq {
+			if proc.Group != "" {
+				groups[proc.Group] = struct{}{}
+			}
+		}
+		return fun.Keys(groups), cobra.ShellCompDirectiveNoFileComp
+	},
+	RunE: func(_ *cobra.Command, args []string) error {
+		group := args[0]
+		n, err := strconv.ParseUint(args[1], 10, 32)
+		if err != nil {
+			return errors.Wrapf(err, "invalid count %q", args[1])
+		}
+
+		return implScale(dbb, core.DirLogs, group, uint(n))
+	},
+}
@@ -1,6 +1,7 @@
 package cli
 
 import (
+	"cmp"
 	"context"
 	"encoding/json"
 	stdErrors "errors"
@@ -13,36 +14,41 @@ import (
 	"path/filepath"
 	"regexp"
 	"slices"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
 
-	"github.com/adhocore/gronx"
 	"github.com/creack/pty"
+	"github.com/rprtr258/fun"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
+	"golang.org/x/sys/unix"
 
 	"github.com/rprtr258/pm/internal/core"
 	"github.com/rprtr258/pm/internal/errors"
+	"github.com/rprtr258/pm/internal/eventlog"
 	"github.com/rprtr258/pm/internal/fsnotify"
 	"github.com/rprtr258/pm/internal/linuxprocess"
 	"github.com/rprtr258/pm/internal/logrotation"
+	"github.com/rprtr258/pm/internal/notify"
 )
 
+// _batchWindow is default time to gather file changes before restart
 const _batchWindow = time.Second
 
+// _idleCheckInterval is how often connections are counted to detect idle process
+const _idleCheckInterval = time.Second
+
+// _listenTrampoline is shell script setting LISTEN_PID for socket activation
+const _listenTrampoline = `export LISTEN_PID=$$; exec "$0" "$@"`
+
 type Entry struct {
 	RootDir     string
 	Pattern     *regexp.Regexp
 	LastModTime time.Time
 }
 
-type Watcher struct {
-	dir     string
-	re      *regexp.Regexp
-	watcher *fsnotify.BatchedRecursiveWatcher
-}
-
 // execCmd start copy of given command. We cannot use cmd itself since
 // we need to start and stop it repeatedly, but cmd stores it's state and cannot
 // be reused, so we need to copy it over and over again.
@@ -51,132 +57,59 @@ func execCmd(cmd exec.Cmd) (*exec.Cmd, error) {
 	return &c, c.Start()
 }
 
-func killCmd(cmd *exec.Cmd, killTimeout time.Duration) {
+// killCmd and its whole family by performing stop steps one by one until all
+// of them die.
+func killCmd(cmd *exec.Cmd, steps []core.StopStep) {
 	children := map[int]struct{}{cmd.Process.Pid: {}}
 	for _, child := range linuxprocess.Children(linuxprocess.List(), cmd.Process.Pid) {
 		children[child.Handle.Pid] = struct{}{}
 	}
 
-	for child := range children {
-		if errTerm := syscall.Kill(child, syscall.SIGTERM); errTerm != nil {
-			log.Error().
-				Int("pid", child).
-				Err(errTerm).
-				Msg("failed to send SIGTERM to process")
-		}
-	}
-
 	const pollInterval = 100 * time.Millisecond
 
-	timer := time.NewTimer(pollInterval)
-	defer timer.Stop()
-
-WAIT_FOR_DEATH:
-	for {
-		select {
-		case <-time.After(killTimeout):
-			break WAIT_FOR_DEATH
-		case <-timer.C:
-			// check if there is still alive child, if no, return
-			allDied := true
-			for child := range children {
-				if err := syscall.Kill(child, syscall.Signal(0)); err == nil {
-					allDied = false
-				} else {
-					delete(children, child)
-				}
-			}
-			if allDied {
-				return
+	// allDied checks if there is still alive child
+	allDied := func() bool {
+		for child := range children {
+			if err := syscall.Kill(child, syscall.Signal(0)); err != nil {
+				delete(children, child)
 			}
 		}
+		return len(children) == 0
 	}
 
-	// process is still alive, go kill all his family
-	log.Warn().Msg("timed out waiting for process to stop from SIGTERM, killing it")
-	for child := range children {
-		if errKill := syscall.Kill(child, syscall.SIGKILL); errKill != nil {
-			log.Error().
-				Int("pid", child).
-				Err(errKill).
-				Msg("failed to send SIGKILL to process")
+	for i, step := range steps {
+		if i > 0 {
+			log.Warn().
+				Stringer("signal", steps[i-1].Signal).
+				Msg("timed out waiting for process to stop, sending next signal")
 		}
-	}
-}
-
-func initWatchChannel(
-	ctx context.Context,
-	ch chan<- []fsnotify.Event,
-	cwd string,
-	watchPattern string,
-) (func(), error) {
-	watchRE, errCompilePattern := regexp.Compile(watchPattern)
-	if errCompilePattern != nil {
-		return nil, errors.Wrapf(errCompilePattern, "compile pattern %q", watchPattern)
-	}
-
-	watcher, err := fsnotify.NewBatchedRecursiveWatcher(cwd, "", _batchWindow)
-	if err != nil {
-		return nil, errors.Wrapf(err, "create watcher")
-	}
 
-	w := Watcher{
-		dir:     cwd,
-		re:      watchRE,
-		watcher: watcher,
-	}
+		for child := range children {
+			if errKill := syscall.Kill(child, step.Signal); errKill != nil {
+				log.Error().
+					Int("pid", child).
+					Stringer("signal", step.Signal).
+					Err(errKill).
+					Msg("failed to send signal to process")
+			}
+		}
 
-	go func() {
+		deadline := time.After(step.Timeout)
+		ticker := time.NewTicker(pollInterval)
+	WAIT_FOR_DEATH:
 		for {
 			select {
-			case <-ctx.Done():
-				return
-			case err := <-w.watcher.Errors:
-				if err != nil {
-					log.Error().Err(err).Msg("fsnotify error")
-				}
-				return
-			case events := <-w.watcher.Events:
-				triggered := false
-				for _, event := range events {
-					filename, err := filepath.Rel(w.dir, event.Name)
-					if err != nil {
-						log.Error().
-							Err(err).
-							Stringer("event", event).
-							Str("dir", w.dir).
-							Msg("get relative filename failed")
-						continue
-					}
-
-					// ignore changes in git directory
-					if filename == ".git" ||
-						strings.HasPrefix(filename, ".git/") ||
-						strings.HasSuffix(filename, "/.git") ||
-						strings.Contains(filename, "/.git/") {
-						continue
-					}
-
-					if !w.re.MatchString(filename) {
-						continue
-					}
-
-					triggered = true
-					break
-				}
-
-				if triggered {
-					ch <- events
+			case <-deadline:
+				break WAIT_FOR_DEATH
+			case <-ticker.C:
+				if allDied() {
+					ticker.Stop()
+					return
 				}
 			}
 		}
-	}()
-	return func() {
-		log.Debug().Msg("closing watcher")
-		if err := w.watcher.Close(); err != nil {
-			log.Error().Err(err).Msg("failed to close watcher")
-		}
-	}, nil
+		ticker.Stop()
+	}
 }
 
 type multiwriter struct {
@@ -207,6 +140,255 @@ func (m *multiwriter) Write(p []byte) (int, error) {
 	return n, err
 }
 
+// exitEvent describes child death. oomKillsBefore is OOM kills counter
+// taken before child start, it is used to tell OOM kills from others.
+func exitEvent(proc core.Proc, cmd *exec.Cmd, err error, oomKillsBefore fun.Option[uint64]) core.Event {
+	event := core.NewEvent(core.EventExited, proc.ID, proc.Name)
+	event.PID = fun.Valid(cmd.Process.Pid)
+
+	var errExit *exec.ExitError
+	switch {
+	case err == nil:
+		event.ExitCode = fun.Valid(0)
+	case stdErrors.As(err, &errExit):
+		status, ok := errExit.Sys().(syscall.WaitStatus)
+		if !ok || !status.Signaled() {
+			event.ExitCode = fun.Valid(errExit.ExitCode())
+			break
+		}
+
+		event.Signal = status.Signal().String()
+		if status.Signal() != syscall.SIGKILL {
+			break
+		}
+
+		if before, ok := oomKillsBefore.Unpack(); ok {
+			if after, ok := linuxprocess.OOMKills(); ok && after > before {
+				event.Type = core.EventOOM
+			}
+		}
+	}
+	return event
+}
+
+// hookVars are passed to hook in environment
+type hookVars struct {
+	name     string          // hook name
+	exitCode fun.Option[int] // exit code of child, if it exited
+	restarts int             // number of child restarts
+}
+
+// runHook runs hook and waits for it to finish. Error is returned only
+// if hook failed and failure must abort child start.
+func runHook(
+	proc core.Proc,
+	hook core.Hook,
+	vars hookVars,
+	env []string,
+	stdout, stderr io.Writer,
+) error {
+	ctx := context.Background()
+	if hook.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, hook.Timeout)
+		defer cancel()
+	}
+
+	cmd := exec.CommandContext(ctx, hook.Command, hook.Args...)
+	cmd.Dir = proc.Cwd
+	cmd.Env = append(slices.Clone(env),
+		"PM_HOOK="+vars.name,
+		"PM_NAME="+proc.Name,
+		"PM_EXIT_CODE="+fun.OptMap(vars.exitCode, strconv.Itoa).OrDefault(""),
+		"PM_RESTARTS="+strconv.Itoa(vars.restarts),
+	)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	// NOTE: kill whole hook process group on timeout, otherwise commands
+	// started by hook keep running and holding its output open
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Setpgid: true,
+	}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	cmd.WaitDelay = time.Second
+
+	log.Debug().Str("hook", vars.name).Str("cmd", cmd.String()).Msg("running hook")
+	err := cmd.Run()
+	if err == nil {
+		return nil
+	}
+
+	log.Error().
+		Err(err).
+		Str("hook", vars.name).
+		Str("on_failure", string(hook.OnFailure)).
+		Msg("hook failed")
+	if hook.OnFailure != core.HookFailureAbort {
+		return nil
+	}
+
+	return errors.Wrapf(err, "hook %s", vars.name)
+}
+
+// listenSockets binds sockets to pass to child. Sockets are owned by shim, so
+// they are not closed while child is restarted or reloaded.
+func listenSockets(addrs []string) ([]*os.File, error) {
+	files := make([]*os.File, 0, len(addrs))
+	for _, addr := range addrs {
+		file, err := func() (*os.File, error) {
+			network, address, err := core.ParseListenAddress(addr)
+			if err != nil {
+				return nil, err
+			}
+
+			if network == "unix" {
+				if errRm := os.Remove(address); errRm != nil && !os.IsNotExist(errRm) {
+					return nil, errors.Wrapf(errRm, "remove stale socket %q", address)
+				}
+			}
+
+			l, err := (&net.ListenConfig{}).Listen(context.Background(), network, address) //nolint:exhaustruct // defaults
+			if err != nil {
+				return nil, errors.Wrapf(err, "listen")
+			}
+			defer l.Close() // NOTE: file is a duplicate, socket stays open
+
+			switch l := l.(type) {
+			case *net.TCPListener:
+				return l.File()
+			case *net.UnixListener:
+				l.SetUnlinkOnClose(false)
+				return l.File()
+			default:
+				return nil, errors.Newf("unexpected listener type %T", l)
+			}
+		}()
+		if err != nil {
+			for _, f := range files {
+				_ = f.Close()
+			}
+			return nil, errors.Wrapf(err, "listen %q", addr)
+		}
+
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+// waitConnection waits for pending connection on any of listening sockets
+func waitConnection(ctx context.Context, files []*os.File) error {
+	fds := fun.Map[unix.PollFd](func(f *os.File) unix.PollFd {
+		return unix.PollFd{Fd: int32(f.Fd()), Events: unix.POLLIN, Revents: 0} //nolint:gosec // fds are small
+	}, files...)
+
+	const pollTimeoutMs = 500 // to check ctx periodically
+	for ctx.Err() == nil {
+		n, err := unix.Poll(fds, pollTimeoutMs)
+		switch {
+		case stdErrors.Is(err, unix.EINTR):
+			continue
+		case err != nil:
+			return errors.Wrapf(err, "poll listening sockets")
+		case n > 0:
+			return nil
+		}
+	}
+	return ctx.Err()
+}
+
+// hasConnections reports whether there are established connections to any
+// of listening sockets. Errors are treated as having connections, so that
+// process is not stopped by mistake.
+func hasConnections(addrs []string) bool {
+	for _, addr := range addrs {
+		network, address, err := core.ParseListenAddress(addr)
+		if err != nil {
+			log.Error().Err(err).Str("addr", addr).Msg("parse listen address")
+			return true
+		}
+
+		count, err := linuxprocess.Connections(network, address)
+		if err != nil {
+			log.Error().Err(err).Str("addr", addr).Msg("count connections")
+			return true
+		}
+
+		if count > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// listenNotify listens for sd_notify(3) style messages from children,
+// like "READY=1", on unix datagram socket
+func listenNotify(filename string) (<-chan string, func(), error) {
+	if errRm := os.Remove(filename); errRm != nil && !os.IsNotExist(errRm) {
+		return nil, nil, errors.Wrapf(errRm, "remove notify socket")
+	}
+
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: filename, Net: "unixgram"})
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "listen notify socket")
+	}
+
+	// buffered, so that messages sent not during reload are not blocking reader
+	ch := make(chan string, 16)
+	go func() {
+		buf := make([]byte, 4096)
+		for {
+			n, _, errRead := conn.ReadFromUnix(buf)
+			if errRead != nil {
+				if !stdErrors.Is(errRead, net.ErrClosed) {
+					log.Error().Err(errRead).Msg("read notify socket")
+				}
+				return
+			}
+
+			select {
+			case ch <- string(buf[:n]):
+			default:
+				log.Debug().Str("message", string(buf[:n])).Msg("dropping notify message")
+			}
+		}
+	}()
+	return ch, func() {
+		_ = conn.Close()
+	}, nil
+}
+
+// waitReady waits for newly started child to become ready: either to send
+// READY=1 to notify socket or to stay alive for ready delay. Returns whether
+// child exited while waiting.
+func waitReady(reload core.Reload, notifyCh <-chan string, waitCh <-chan error) (bool, error) {
+	if !reload.Notify {
+		select {
+		case err := <-waitCh:
+			log.Debug().Err(err).Msg("new instance exited")
+			return true, errors.Newf("exited before ready delay %s passed", reload.ReadyDelay)
+		case <-time.After(reload.ReadyDelay):
+			return false, nil
+		}
+	}
+
+	deadline := time.After(reload.ReadyTimeout)
+	for {
+		select {
+		case err := <-waitCh:
+			log.Debug().Err(err).Msg("new instance exited")
+			return true, errors.New("exited before sending READY=1")
+		case <-deadline:
+			return false, errors.Newf("did not send READY=1 in %s", reload.ReadyTimeout)
+		case msg := <-notifyCh:
+			if slices.Contains(strings.Split(msg, "\n"), "READY=1") {
+				return false, nil
+			}
+		}
+	}
+}
+
 //nolint:gocognit,funlen,gocyclo,cyclop,maintidx // very important function, must be verbose here, done my best for now
 func implShim(proc core.Proc) error {
 	// parse env because why the fuck not
@@ -224,6 +406,10 @@ func implShim(proc core.Proc) error {
 		Filename:   proc.StderrFile,
 		MaxBackups: 1,
 	})
+	buildw := logrotation.New(logrotation.Config{
+		Filename:   core.BuildLogFile(core.DirLogs, proc.ID),
+		MaxBackups: 1,
+	})
 
 	// allocating pseudo-terminal
 	ptmx, tty, err := pty.Open()
@@ -287,12 +473,41 @@ func implShim(proc core.Proc) error {
 		}
 	}()
 
+	log.Debug().Msg("listen sockets")
+	listenFiles, err := listenSockets(proc.Listen)
+	if err != nil {
+		return errors.Wrapf(err, "listen sockets")
+	}
+	defer func() {
+		for _, f := range listenFiles {
+			_ = f.Close()
+		}
+	}()
+
+	reloadCfg := proc.Reload.OrDefault(core.Reload{ //nolint:exhaustruct // only strategy is used
+		Strategy: core.ReloadRestart,
+	})
+
+	childEnv := slices.Clone(env)
+	var notifyCh <-chan string
+	if reloadCfg.Notify {
+		notifySocket := filepath.Join(core.DirHome, proc.ID.String()+".notify.sock")
+		ch, closeNotify, err := listenNotify(notifySocket)
+		if err != nil {
+			return errors.Wrapf(err, "listen notify socket")
+		}
+		defer closeNotify()
+
+		notifyCh = ch
+		childEnv = append(childEnv, "NOTIFY_SOCKET="+notifySocket)
+	}
+
 	log.Debug().Msg("create command")
 	cmdShape := exec.Cmd{
 		Path:   proc.Command,
 		Args:   append([]string{proc.Command}, proc.Args...),
 		Dir:    proc.Cwd,
-		Env:    env,
+		Env:    childEnv,
 		Stdin:  tty,
 		Stdout: tty,
 		Stderr: errw,
@@ -302,6 +517,15 @@ func implShim(proc core.Proc) error {
 			Setctty: true,
 		},
 	}
+	if len(listenFiles) > 0 {
+		// pass sockets as fds starting from 3, LISTEN_PID must be set to child
+		// pid, which is not known before start, so it is set by shell, which
+		// then replaces itself with child keeping pid
+		cmdShape.Path = "/bin/sh"
+		cmdShape.Args = append([]string{"/bin/sh", "-c", _listenTrampoline, proc.Command}, proc.Args...)
+		cmdShape.Env = append(cmdShape.Env, "LISTEN_FDS="+strconv.Itoa(len(listenFiles)))
+		cmdShape.ExtraFiles = listenFiles
+	}
 
 	log.Debug().Msg("init context")
 	ctx, cancel := context.WithCancel(context.Background())
@@ -312,9 +536,9 @@ func implShim(proc core.Proc) error {
 	defer close(watchCh)
 
 	log.Debug().Msg("check watch")
-	if watchPattern, ok := proc.Watch.Unpack(); ok {
-		log.Debug().Str("watch", watchPattern).Msg("init watch channel")
-		watchChClose, err := initWatchChannel(ctx, watchCh, proc.Cwd, watchPattern)
+	if watch, ok := proc.Watch.Unpack(); ok {
+		log.Debug().Stringer("watch", watch).Msg("init watch channel")
+		watchChClose, err := initWatchChannel(ctx, watchCh, proc.Cwd, watch)
 		if err != nil {
 			return errors.Wrapf(err, "init watch channel")
 		}
@@ -328,10 +552,190 @@ func implShim(proc core.Proc) error {
 		log.Debug().Msg("closing signals channel")
 		close(terminateCh)
 	}()
+	reloadCh := make(chan os.Signal, 1)
+	signal.Notify(reloadCh, _signalReload)
+	// requests to run job are handled by jobs only, other processes keep
+	// default handling of signal
+	runJobCh := make(chan os.Signal, 1)
+	if proc.Cron.Valid {
+		signal.Notify(runJobCh, _signalRunJob)
+	}
+
+	var notifier *notify.Notifier
+	if notifyCfg, ok := proc.Notify.Unpack(); ok {
+		notifier = notify.New(notifyCfg)
+		defer notifier.Wait()
+	}
+	emit := func(event core.Event) {
+		eventlog.Emit(event)
+		if notifier != nil && event.Failed() {
+			notifier.Notify(event)
+		}
+	}
+
+	restarts := 0
+	hook := func(name string, hook fun.Option[core.Hook], exitCode fun.Option[int]) error {
+		h, ok := hook.Unpack()
+		if !ok {
+			return nil
+		}
+
+		return runHook(proc, h, hookVars{
+			name:     name,
+			exitCode: exitCode,
+			restarts: restarts,
+		}, env, outw, errw)
+	}
+
+	// build runs build command, returns false if restart must be cancelled
+	build := func() bool {
+		b, ok := proc.Build.Unpack()
+		if !ok {
+			return true
+		}
+
+		fmt.Fprintf(buildw, "build started at %s\n", time.Now().Format(time.DateTime))
+		if err := runHook(proc, b, hookVars{
+			name:     "build",
+			exitCode: fun.Invalid[int](),
+			restarts: restarts,
+		}, env, buildw, buildw); err != nil {
+			emit(core.NewEvent(core.EventBuildFailed, proc.ID, proc.Name))
+			return false
+		}
+
+		return true
+	}
+
+	// stopChild stops child started by pm using stop command and stop
+	// sequence, running stop hooks around
+	stopChild := func(cmd *exec.Cmd, waitCh <-chan error) {
+		_ = hook("pre_stop", proc.Hooks.PreStop, fun.Invalid[int]())
+
+		exitCode := fun.Invalid[int]()
+		exited := false
+		if stopCommand, ok := proc.StopCommand.Unpack(); ok {
+			// stop command timeout includes waiting for process to exit
+			stopCommand.Timeout = cmp.Or(stopCommand.Timeout, proc.KillTimeout)
+			deadline := time.After(stopCommand.Timeout)
+			_ = runHook(proc, stopCommand, hookVars{
+				name:     "stop_command",
+				exitCode: fun.Invalid[int](),
+				restarts: restarts,
+			}, env, outw, errw)
+
+			select {
+			case err := <-waitCh:
+				exitCode, exited = exitEvent(proc, cmd, err, fun.Invalid[uint64]()).ExitCode, true
+			case <-deadline:
+				log.Warn().Msg("timed out waiting for process to stop after stop command")
+			}
+		}
+
+		if !exited {
+			killCmd(cmd, proc.StopSteps())
+
+			select {
+			case err := <-waitCh:
+				exitCode = exitEvent(proc, cmd, err, fun.Invalid[uint64]()).ExitCode
+			case <-time.After(time.Second):
+				log.Warn().Msg("timed out waiting for killed process exit status")
+			}
+		}
+
+		_ = hook("post_exit", proc.Hooks.PostExit, exitCode)
+	}
+
+	// startNewInstance starts new child alongside running one and waits for it
+	// to become ready, so that old one can be stopped without downtime
+	startNewInstance := func() (*exec.Cmd, chan error, error) {
+		if err := hook("pre_start", proc.Hooks.PreStart, fun.Invalid[int]()); err != nil {
+			return nil, nil, err
+		}
+
+		// drop readiness messages sent before new instance start
+		for len(notifyCh) > 0 {
+			<-notifyCh
+		}
+
+		cmd, err := execCmd(cmdShape)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "run new instance")
+		}
+
+		startedEvent := core.NewEvent(core.EventStarted, proc.ID, proc.Name)
+		startedEvent.PID = fun.Valid(cmd.Process.Pid)
+		emit(startedEvent)
+
+		waitCh := make(chan error, 1)
+		go func() {
+			waitCh <- cmd.Wait()
+		}()
+
+		if exited, err := waitReady(reloadCfg, notifyCh, waitCh); err != nil {
+			if !exited {
+				killCmd(cmd, proc.StopSteps())
+			}
+			return nil, nil, errors.Wrapf(err, "wait new instance pid=%d", cmd.Process.Pid)
+		}
+
+		if err := hook("post_start", proc.Hooks.PostStart, fun.Invalid[int]()); err != nil {
+			stopChild(cmd, waitCh)
+			return nil, nil, err
+		}
 
-	log.Debug().Msg("init wait channel")
-	waitCh := make(chan error) // process death events
-	defer close(waitCh)
+		return cmd, waitCh, nil
+	}
+
+	// awaitConnection blocks lazy process start until first connection,
+	// returns false if shim must exit
+	awaitConnection := func() (bool, error) {
+		log.Debug().Msg("waiting for connection")
+		connCtx, connCancel := context.WithCancel(ctx)
+		defer connCancel()
+
+		connCh := make(chan error, 1)
+		go func() {
+			connCh <- waitConnection(connCtx, listenFiles)
+		}()
+
+		select {
+		case err := <-connCh:
+			if err != nil {
+				return false, errors.Wrapf(err, "wait for connection")
+			}
+			return true, nil
+		case <-reloadCh:
+			log.Debug().Msg("reload requested awaiting for connection")
+			return true, nil
+		case <-terminateCh:
+			log.Debug().Msg("terminate signal received awaiting for connection")
+			return false, nil
+		}
+	}
+
+	if proc.StartDelay > 0 {
+		log.Debug().Stringer("start_delay", proc.StartDelay).Msg("delaying start")
+		select {
+		case <-time.After(proc.StartDelay):
+		case <-terminateCh:
+			log.Debug().Msg("terminate signal received awaiting for start delay")
+			return nil
+		}
+	}
+
+	if cron, ok := proc.Cron.Unpack(); ok {
+		log.Debug().Stringer("cron", cron).Msg("running as job")
+		return newJobScheduler(proc, cron, cmdShape, outw, errw, emit, hook, build).
+			run(terminateCh, reloadCh, runJobCh, watchCh)
+	}
+
+	var idleTick <-chan time.Time
+	if proc.IdleTimeout > 0 {
+		ticker := time.NewTicker(min(proc.IdleTimeout, _idleCheckInterval))
+		defer ticker.Stop()
+		idleTick = ticker.C
+	}
 
 	/*
 		Very important shit happens here in loop aka zaloopa.
@@ -340,14 +744,26 @@ func implShim(proc core.Proc) error {
 			- very first launch, just launch
 			- process exited or failed, autorestarts left, autorestart
 			- same case, but no autorestart, but watch enabled, wait for it
-		- then, launch proc. Setup waitCh with exit status
+		- on first launch and watch trigger process is built, if build fails,
+		  wait for next watch trigger
+		- lazy process is started only after first connection to its sockets
+		- then, run pre_start hook and launch proc. Setup waitCh with exit status
 		- listen for event leading to process death:
 			- terminate signal received, kill proc and exit
 			- process died, loop
-			- watch triggered, kill process, then loop
+			- watch triggered, build, kill process, then loop, or keep
+			  process running if build failed
+			- reload requested, either restart process, signal it or
+			  replace it with new instance and keep listening
+			- no connections for idle timeout, stop process and loop lazily
 	*/
 	waitTrigger := true
+	lastExitFailed := false
+	restartReason := "" // empty for the very first launch
 	autorestartsLeft := proc.MaxRestarts
+	lazyWait := proc.Lazy
+	buildNeeded := proc.Build.Valid // build before next start
+	buildFailed := false            // last build failed, do not autorestart
 	for {
 		log.Debug().
 			Bool("wait_trigger", waitTrigger).
@@ -356,74 +772,157 @@ func implShim(proc core.Proc) error {
 		case waitTrigger:
 			log.Debug().Msg("starting for the first time/restarting after watch")
 			waitTrigger = false
-		case autorestartsLeft > 0: // autorestart
+		case autorestartsLeft > 0 && !buildFailed: // autorestart
 			autorestartsLeft--
+			restartReason = core.ReasonAutorestart
 		case proc.Watch.Valid: // watch defined, waiting for it
 			select {
 			case events := <-watchCh:
 				log.Debug().Any("events", events).Msg("watch triggered")
+				restartReason = core.ReasonWatch
+				buildNeeded = proc.Build.Valid
 			case <-terminateCh:
 				log.Debug().Msg("terminate signal received awaiting for watch")
 				return nil
+			case <-reloadCh:
+				log.Debug().Msg("reload requested awaiting for watch")
+				restartReason = core.ReasonReload
 			}
 		default:
+			if proc.MaxRestarts > 0 && lastExitFailed {
+				emit(core.NewEvent(core.EventGaveUp, proc.ID, proc.Name))
+			}
 			return nil
 		}
 
+		if buildNeeded {
+			if !build() {
+				buildFailed = true
+				continue
+			}
+			buildNeeded, buildFailed = false, false
+		}
+
+		if lazyWait {
+			if ok, err := awaitConnection(); !ok {
+				return err
+			}
+			lazyWait = false
+		}
+
+		if restartReason != "" {
+			restarts++
+			event := core.NewEvent(core.EventRestarted, proc.ID, proc.Name)
+			event.Reason = restartReason
+			emit(event)
+		}
+
+		if err := hook("pre_start", proc.Hooks.PreStart, fun.Invalid[int]()); err != nil {
+			return errors.Wrapf(err, "abort start")
+		}
+
+		oomKillsBefore := fun.Optional(linuxprocess.OOMKills())
 		cmd, errRunFirst := execCmd(cmdShape)
 		if errRunFirst != nil {
 			return errors.Wrapf(errRunFirst, "run proc: %v", proc)
 		}
 
+		startedEvent := core.NewEvent(core.EventStarted, proc.ID, proc.Name)
+		startedEvent.PID = fun.Valid(cmd.Process.Pid)
+		emit(startedEvent)
+
+		// process death event, buffered so that death of abandoned child does not block
+		waitCh := make(chan error, 1)
 		go func() {
-			err := cmd.Wait()
-			// try notify, if not listening, ignore
-			select {
-			case waitCh <- err:
-			default:
-			}
+			waitCh <- cmd.Wait()
 		}()
 
-		select {
-		case <-terminateCh:
-			// NOTE: Terminate child completely.
-			// Stop is done by sending SIGTERM.
-			// Manual restart is done by restarting whole shim and child by cli.
-			log.Debug().Msg("terminate signal received")
-			killCmd(cmd, proc.KillTimeout)
-			return nil
-		case events := <-watchCh:
-			log.Debug().Any("events", events).Msg("watch triggered")
-			killCmd(cmd, proc.KillTimeout)
-			waitTrigger = true // do not wait for autorestart or watch, start immediately
-		case err := <-waitCh:
-			log.Debug().Err(err).Msg("proc stopped")
-			if cronExpr, ok := proc.Cron.Unpack(); ok {
-				nextAt, err := gronx.NextTick(cronExpr, false)
-				if err != nil {
-					return errors.Wrapf(err, "add cron %q", cronExpr)
-				}
-				waitFor := time.Until(nextAt)
-				log.Debug().
-					Stringer("for", waitFor).
-					Err(err).
-					Msg("waiting for cron")
-				select {
-				case <-terminateCh:
-					// NOTE: Terminate child completely.
-					// Stop is done by sending SIGTERM.
-					// Manual restart is done by restarting whole shim and child by cli.
-					log.Debug().Msg("terminate signal received")
-					killCmd(cmd, proc.KillTimeout)
-					return nil
-				case events := <-watchCh:
-					log.Debug().Any("events", events).Msg("watch triggered")
-					killCmd(cmd, proc.KillTimeout)
-					waitTrigger = true // do not wait for autorestart or watch, start immediately
-				case <-time.After(waitFor):
-					log.Debug().Time("time", time.Now()).Msg("restarting due to cron")
+		if err := hook("post_start", proc.Hooks.PostStart, fun.Invalid[int]()); err != nil {
+			stopChild(cmd, waitCh)
+			emit(core.NewEvent(core.EventStopped, proc.ID, proc.Name))
+			return errors.Wrapf(err, "abort start")
+		}
+		lastActiveAt := time.Now()
+
+	RUNNING:
+		for {
+			select {
+			case <-terminateCh:
+				// NOTE: Terminate child completely.
+				// Stop is done by sending SIGTERM.
+				// Manual restart is done by restarting whole shim and child by cli.
+				log.Debug().Msg("terminate signal received")
+				stopChild(cmd, waitCh)
+				emit(core.NewEvent(core.EventStopped, proc.ID, proc.Name))
+				return nil
+			case <-reloadCh:
+				log.Debug().Str("strategy", string(reloadCfg.Strategy)).Msg("reload requested")
+				switch reloadCfg.Strategy {
+				case core.ReloadRestart:
+					stopChild(cmd, waitCh)
 					waitTrigger = true // do not wait for autorestart or watch, start immediately
+					restartReason = core.ReasonReload
+					break RUNNING
+				case core.ReloadSignal:
+					if errKill := syscall.Kill(cmd.Process.Pid, reloadCfg.Signal); errKill != nil {
+						log.Error().Err(errKill).Stringer("signal", reloadCfg.Signal).Msg("send reload signal")
+						continue
+					}
+				case core.ReloadBlueGreen:
+					newCmd, newWaitCh, errStart := startNewInstance()
+					if errStart != nil {
+						log.Error().Err(errStart).Msg("reload failed, keeping old instance")
+						continue
+					}
+
+					stopChild(cmd, waitCh)
+					cmd, waitCh = newCmd, newWaitCh
+					oomKillsBefore = fun.Optional(linuxprocess.OOMKills())
+				}
+
+				event := core.NewEvent(core.EventReloaded, proc.ID, proc.Name)
+				event.PID = fun.Valid(cmd.Process.Pid)
+				event.Reason = string(reloadCfg.Strategy)
+				emit(event)
+			case events := <-watchCh:
+				log.Debug().Any("events", events).Msg("watch triggered")
+				if !build() {
+					log.Debug().Msg("build failed, keeping running process")
+					continue
+				}
+
+				stopChild(cmd, waitCh)
+				waitTrigger = true // do not wait for autorestart or watch, start immediately
+				restartReason = core.ReasonWatch
+				break RUNNING
+			case <-idleTick:
+				if hasConnections(proc.Listen) {
+					lastActiveAt = time.Now()
+					continue
+				}
+
+				if time.Since(lastActiveAt) < proc.IdleTimeout {
+					continue
+				}
+
+				log.Debug().Stringer("idle_timeout", proc.IdleTimeout).Msg("no connections, stopping")
+				stopChild(cmd, waitCh)
+				event := core.NewEvent(core.EventStopped, proc.ID, proc.Name)
+				event.Reason = core.ReasonIdle
+				emit(event)
+				waitTrigger, lazyWait = true, true // start again on next connection
+				restartReason = core.ReasonConnection
+				break RUNNING
+			case err := <-waitCh:
+				log.Debug().Err(err).Msg("proc stopped")
+				event := exitEvent(proc, cmd, err, oomKillsBefore)
+				emit(event)
+				lastExitFailed = event.Failed()
+				_ = hook("post_exit", proc.Hooks.PostExit, event.ExitCode)
+				if event.ExitCode != fun.Valid(0) {
+					_ = hook("on_crash", proc.Hooks.OnCrash, event.ExitCode)
 				}
+				break RUNNING
 			}
 		}
 	}
@@ -0,0 +1,89 @@
+package cli
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rprtr258/fun"
+	"github.com/shoenig/test"
+
+	"github.com/rprtr258/pm/internal/core"
+)
+
+func TestRunHook(t *testing.T) {
+	t.Parallel()
+
+	var proc core.Proc
+	proc.Name = "app"
+	proc.Cwd = t.TempDir()
+
+	vars := hookVars{
+		name:     "build",
+		exitCode: fun.Invalid[int](),
+		restarts: 2,
+	}
+	shell := func(script string, onFailure core.HookFailure, timeout time.Duration) core.Hook {
+		return core.Hook{
+			Command:   "/bin/sh",
+			Args:      []string{"-c", script},
+			OnFailure: onFailure,
+			Timeout:   timeout,
+		}
+	}
+
+	for name, tc := range map[string]struct {
+		hook   core.Hook
+		vars   hookVars
+		stdout string
+		stderr string
+		err    string
+	}{
+		"vars": {
+			hook:   shell(`echo "$PM_HOOK $PM_NAME [$PM_EXIT_CODE] $PM_RESTARTS $FOO $(basename "$PWD")"`, core.HookFailureAbort, 0),
+			vars:   vars,
+			stdout: "build app [] 2 bar %CWD%\n",
+		},
+		"exit code": {
+			hook: shell(`echo "$PM_EXIT_CODE"`, core.HookFailureIgnore, 0),
+			vars: hookVars{
+				name:     "post_exit",
+				exitCode: fun.Valid(3),
+				restarts: 0,
+			},
+			stdout: "3\n",
+		},
+		"ignored failure": {
+			hook:   shell(`echo oops >&2; exit 1`, core.HookFailureIgnore, 0),
+			vars:   vars,
+			stderr: "oops\n",
+		},
+		"aborting failure": {
+			hook:   shell(`echo oops >&2; exit 1`, core.HookFailureAbort, 0),
+			vars:   vars,
+			stderr: "oops\n",
+			err:    "hook build: exit status 1",
+		},
+		"timeout": {
+			hook: shell(`sleep 10`, core.HookFailureAbort, 100*time.Millisecond),
+			vars: vars,
+			err:  "hook build: signal: killed",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var stdout, stderr bytes.Buffer
+			err := runHook(proc, tc.hook, tc.vars, []string{"FOO=bar"}, &stdout, &stderr)
+			if tc.err != "" {
+				test.EqError(t, err, tc.err)
+			} else {
+				test.NoError(t, err)
+			}
+			test.EqOp(t, strings.ReplaceAll(tc.stdout, "%CWD%", filepath.Base(proc.Cwd)), stdout.String())
+			test.EqOp(t, tc.stderr, stderr.String())
+		})
+	}
+}
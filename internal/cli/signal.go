@@ -4,9 +4,6 @@ import (
 	stdErrors "errors"
 	"fmt"
 	"os"
-	"sort"
-	"strconv"
-	"strings"
 	"syscall"
 
 	"github.com/rprtr258/fun"
@@ -62,36 +59,11 @@ func implSignal(
 	return errors.Combine(errs...)
 }
 
-var (
-	_signals = func() map[string]syscall.Signal {
-		names := map[string]syscall.Signal{
-			"SIGHUP":  syscall.SIGHUP,
-			"SIGINT":  syscall.SIGINT,
-			"SIGQUIT": syscall.SIGQUIT,
-			"SIGKILL": syscall.SIGKILL,
-			"SIGTERM": syscall.SIGTERM,
-			"SIGCONT": syscall.SIGCONT,
-			"SIGSTOP": syscall.SIGSTOP,
-		}
-
-		res := make(map[string]syscall.Signal, 2*len(names))
-		for name, sig := range names {
-			res[name] = sig
-			res[strings.TrimPrefix(name, "SIG")] = sig
-		}
-		return res
-	}()
-	_signalNames = func() []string {
-		res := fun.Keys(_signals)
-		sort.Strings(res)
-		return res
-	}()
-)
-
 var _cmdSignal = func() *cobra.Command {
 	const filter = filterRunning
 	var names, ids, tags []string
 	var config string
+	var jsonnet jsonnetFlags
 	var interactive bool
 	cmd := &cobra.Command{
 		Use:     "signal [sigspec] [name|tag|id]...",
@@ -103,7 +75,7 @@ var _cmdSignal = func() *cobra.Command {
 			prefix string,
 		) ([]string, cobra.ShellCompDirective) {
 			if len(args) == 0 {
-				return _signalNames, cobra.ShellCompDirectiveNoFileComp
+				return core.SignalNames, cobra.ShellCompDirectiveNoFileComp
 			}
 
 			return completeArgGenericSelector(filter)(cmd, args, prefix)
@@ -114,26 +86,22 @@ var _cmdSignal = func() *cobra.Command {
 			args = args[1:]
 			config := fun.IF(cmd.Flags().Lookup("config").Changed, &config, nil)
 
-			sig, ok := _signals[signal]
-			if !ok {
-				if x, err := strconv.Atoi(signal); err == nil {
-					sig = syscall.Signal(x)
-				} else {
-					return errors.Newf("unknown signal: %q", signal)
-				}
+			sig, err := core.ParseSignal(signal)
+			if err != nil {
+				return err
 			}
 
 			list := listProcs(dbb)
 
 			if config != nil {
-				configs, errLoadConfigs := core.LoadConfigs(*config)
+				configs, errLoadConfigs := loadConfigs(*config, jsonnet.options()...)
 				if errLoadConfigs != nil {
 					return errors.Wrap(errLoadConfigs, "load configs")
 				}
 
 				namesFilter := fun.Map[string](func(cfg core.RunConfig) string {
 					return cfg.Name
-				}, configs...)
+				}, core.Replicas(configs...)...)
 
 				list = list.
 					Filter(func(proc core.ProcStat) bool {
@@ -170,6 +138,6 @@ var _cmdSignal = func() *cobra.Command {
 	}
 	addFlagInteractive(cmd, &interactive)
 	addFlagGenerics(cmd, filter, &names, &tags, &ids)
-	addFlagConfig(cmd, &config)
+	addFlagConfig(cmd, &config, &jsonnet)
 	return cmd
 }()
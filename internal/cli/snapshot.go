@@ -0,0 +1,224 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rprtr258/fun"
+	"github.com/rprtr258/scuf"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+
+	"github.com/rprtr258/pm/internal/core"
+	"github.com/rprtr258/pm/internal/db"
+	"github.com/rprtr258/pm/internal/errors"
+	"github.com/rprtr258/pm/internal/eventlog"
+	"github.com/rprtr258/pm/internal/table"
+)
+
+func completeArgSnapshot(
+	_ *cobra.Command, _ []string,
+	prefix string,
+) ([]string, cobra.ShellCompDirective) {
+	snapshots, _ := db.ListSnapshots(core.DirSnapshots)
+	names := []string{}
+	for _, snapshot := range snapshots {
+		if strings.HasPrefix(snapshot.Name, prefix) {
+			names = append(names, snapshot.Name)
+		}
+	}
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
+
+// implResurrect starts processes from snapshot in dependency order,
+// recreating ones deleted since snapshot was saved
+func implResurrect(dbb db.Handle, dirLogs string, snapshot db.Snapshot) error {
+	procs, err := sortByDependencies(snapshot.Procs, func(proc core.Proc) (string, []string) {
+		return proc.Name, proc.DependsOn
+	})
+	if err != nil {
+		return err
+	}
+
+	ids, err := func() ([]core.PMID, error) {
+		unlock, err := dbb.Lock()
+		if err != nil {
+			return nil, errors.Wrapf(err, "lock db")
+		}
+		defer unlock()
+
+		existing, err := dbb.List(core.WithAllIfNoFilters)
+		if err != nil {
+			return nil, errors.Wrapf(err, "get procs from db")
+		}
+
+		return fun.MapErr[core.PMID](func(proc core.Proc) (core.PMID, error) {
+			// process might be recreated with other id since snapshot, so find it by name
+			if id, ok := fun.FindKeyBy(existing, func(_ core.PMID, p core.Proc) bool {
+				return p.Name == proc.Name
+			}); ok {
+				return id, nil
+			}
+
+			if _, ok := existing[proc.ID]; ok {
+				oldID := proc.ID
+				proc.ID = core.GenPMID()
+				// default log files are named by id, so they must not be shared with other process
+				for _, file := range []*string{&proc.StdoutFile, &proc.StderrFile} {
+					ext := filepath.Ext(*file)
+					if *file == filepath.Join(dirLogs, oldID.String()+ext) {
+						*file = filepath.Join(dirLogs, proc.ID.String()+ext)
+					}
+				}
+			}
+
+			// ports might be allocated to other processes since snapshot was saved
+			ports, err := _ports.reassign(dbb, proc.Name, proc.Ports)
+			if err != nil {
+				return "", errors.Wrapf(err, "assign ports to proc %s", proc.Name)
+			}
+			proc.Ports = ports
+
+			if err := dbb.UpdateProc(proc); err != nil {
+				return "", errors.Wrapf(err, "recreate proc %s", proc.Name)
+			}
+
+			eventlog.Emit(core.NewEvent(core.EventCreated, proc.ID, proc.Name))
+			return proc.ID, nil
+		}, procs...)
+	}()
+	if err != nil {
+		return err
+	}
+
+	return implStart(dbb, ids...)
+}
+
+var _cmdSave = func() *cobra.Command {
+	var name string
+	cmd := &cobra.Command{
+		Use:   "save",
+		Short: "save running processes to snapshot",
+		Args:  cobra.NoArgs,
+		RunE: func(*cobra.Command, []string) error {
+			if err := db.ValidateSnapshotName(name); err != nil {
+				return err
+			}
+
+			procs := listProcs(dbb).
+				Filter(func(ps core.ProcStat) bool { return ps.Status != core.StatusStopped }).
+				Slice()
+			slices.SortFunc(procs, func(a, b core.ProcStat) int {
+				return strings.Compare(a.Name, b.Name)
+			})
+
+			if err := db.SaveSnapshot(core.DirSnapshots, db.Snapshot{
+				Name:      name,
+				CreatedAt: time.Now(),
+				Procs:     fun.Map[core.Proc](func(ps core.ProcStat) core.Proc { return ps.Proc }, procs...),
+			}); err != nil {
+				return errors.Wrapf(err, "save snapshot %q", name)
+			}
+
+			fmt.Printf("saved %d processes to snapshot %q\n", len(procs), name)
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&name, "name", "n", db.DefaultSnapshot, "snapshot name")
+	return cmd
+}()
+
+var _cmdResurrect = func() *cobra.Command {
+	return &cobra.Command{
+		Use:               "resurrect [snapshot]",
+		Short:             "start processes saved in snapshot",
+		Args:              cobra.MaximumNArgs(1),
+		ValidArgsFunction: completeArgSnapshot,
+		RunE: func(_ *cobra.Command, args []string) error {
+			name := db.DefaultSnapshot
+			if len(args) > 0 {
+				name = args[0]
+			}
+
+			snapshot, err := db.LoadSnapshot(core.DirSnapshots, name)
+			if err != nil {
+				return err
+			}
+
+			if len(snapshot.Procs) == 0 {
+				fmt.Println("nothing to resurrect")
+				return nil
+			}
+
+			if err := implResurrect(dbb, core.DirLogs, snapshot); err != nil {
+				return err
+			}
+
+			names := fun.Map[string](func(proc core.Proc) string { return proc.Name }, snapshot.Procs...)
+			printProcs(listProcs(dbb).
+				Filter(func(ps core.ProcStat) bool { return fun.Contains(ps.Name, names...) }).
+				Slice()...)
+			return nil
+		},
+	}
+}()
+
+var _cmdSnapshotsDelete = func() *cobra.Command {
+	return &cobra.Command{
+		Use:               "delete snapshot...",
+		Short:             "delete snapshot(s)",
+		Aliases:           []string{"del", "rm"},
+		Args:              cobra.MinimumNArgs(1),
+		ValidArgsFunction: completeArgSnapshot,
+		RunE: func(_ *cobra.Command, args []string) error {
+			return errors.Combine(fun.Map[error](func(name string) error {
+				if err := db.DeleteSnapshot(core.DirSnapshots, name); err != nil {
+					return err
+				}
+
+				fmt.Println(name)
+				return nil
+			}, args...)...)
+		},
+	}
+}()
+
+var _cmdSnapshots = func() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "snapshots",
+		Short: "list saved snapshots",
+		Args:  cobra.NoArgs,
+		RunE: func(*cobra.Command, []string) error {
+			snapshots, err := db.ListSnapshots(core.DirSnapshots)
+			if err != nil {
+				return err
+			}
+
+			t := table.Table{
+				Headers: fun.Map[string](func(col string) string {
+					return scuf.String(col, scuf.ModBold)
+				}, "name", "created", "procs", "names"),
+				Rows: fun.Map[[]string](func(snapshot db.Snapshot) []string {
+					return []string{
+						snapshot.Name,
+						snapshot.CreatedAt.Local().Format(time.DateTime),
+						strconv.Itoa(len(snapshot.Procs)),
+						strings.Join(fun.Map[string](func(proc core.Proc) string { return proc.Name }, snapshot.Procs...), " "),
+					}
+				}, snapshots...),
+				HaveInnerRowsDividers: false,
+			}
+
+			width, _, _ := term.GetSize(int(os.Stdout.Fd()))
+			fmt.Println(table.Render(t, width))
+			return nil
+		},
+	}
+	cmd.AddCommand(_cmdSnapshotsDelete)
+	return cmd
+}()
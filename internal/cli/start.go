@@ -14,6 +14,7 @@ var _cmdStart = func() *cobra.Command {
 	const filter = filterStopped
 	var names, ids, tags []string
 	var config string
+	var jsonnet jsonnetFlags
 	cmd := &cobra.Command{
 		Use:               "start [name|tag|id]...",
 		Short:             "start already added process(es)",
@@ -23,14 +24,14 @@ var _cmdStart = func() *cobra.Command {
 
 			var filterFunc func(core.Proc) bool
 			if config != nil {
-				configs, errLoadConfigs := core.LoadConfigs(*config)
+				configs, errLoadConfigs := loadConfigs(*config, jsonnet.options()...)
 				if errLoadConfigs != nil {
 					return errors.Wrapf(errLoadConfigs, "load configs: %s", *config)
 				}
 
 				procNames := fun.Map[string](func(cfg core.RunConfig) string {
 					return cfg.Name
-				}, configs...)
+				}, core.Replicas(configs...)...)
 
 				ff := core.FilterFunc(
 					core.WithGeneric(args...),
@@ -73,6 +74,6 @@ var _cmdStart = func() *cobra.Command {
 		},
 	}
 	addFlagGenerics(cmd, filter, &names, &tags, &ids)
-	addFlagConfig(cmd, &config)
+	addFlagConfig(cmd, &config, &jsonnet)
 	return cmd
 }()
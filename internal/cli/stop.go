@@ -14,6 +14,7 @@ var _cmdStop = func() *cobra.Command {
 	const filter = filterRunning
 	var names, ids, tags []string
 	var config string
+	var jsonnet jsonnetFlags
 	var interactive bool
 	cmd := &cobra.Command{
 		Use:               "stop [name|tag|id]...",
@@ -25,14 +26,14 @@ var _cmdStop = func() *cobra.Command {
 
 			list := listProcs(dbb)
 			if config != nil {
-				configs, errLoadConfigs := core.LoadConfigs(*config)
+				configs, errLoadConfigs := loadConfigs(*config, jsonnet.options()...)
 				if errLoadConfigs != nil {
 					return errors.Wrapf(errLoadConfigs, "load configs")
 				}
 
 				namesFilter := fun.Map[string](func(cfg core.RunConfig) string {
 					return cfg.Name
-				}, configs...)
+				}, core.Replicas(configs...)...)
 
 				list = list.
 					Filter(func(proc core.ProcStat) bool {
@@ -71,6 +72,6 @@ var _cmdStop = func() *cobra.Command {
 	}
 	addFlagInteractive(cmd, &interactive)
 	addFlagGenerics(cmd, filter, &names, &tags, &ids)
-	addFlagConfig(cmd, &config)
+	addFlagConfig(cmd, &config, &jsonnet)
 	return cmd
 }()
@@ -14,6 +14,7 @@ var _cmdTUI = func() *cobra.Command {
 	const filter = filterAll
 	var names, ids, tags []string
 	var config string
+	var jsonnet jsonnetFlags
 	cmd := &cobra.Command{
 		Use:               "tui [name|tag|id]...",
 		Short:             "open TUI dashboard process(es)",
@@ -24,14 +25,14 @@ var _cmdTUI = func() *cobra.Command {
 			list := listProcs(dbb)
 
 			if config != nil {
-				configs, errLoadConfigs := core.LoadConfigs(*config)
+				configs, errLoadConfigs := loadConfigs(*config, jsonnet.options()...)
 				if errLoadConfigs != nil {
 					return errors.Wrap(errLoadConfigs, "load configs")
 				}
 
 				namesFilter := fun.Map[string](func(cfg core.RunConfig) string {
 					return cfg.Name
-				}, configs...)
+				}, core.Replicas(configs...)...)
 
 				list = list.
 					Filter(func(proc core.ProcStat) bool {
@@ -66,6 +67,6 @@ var _cmdTUI = func() *cobra.Command {
 		},
 	}
 	addFlagGenerics(cmd, filter, &names, &tags, &ids)
-	addFlagConfig(cmd, &config)
+	addFlagConfig(cmd, &config, &jsonnet)
 	return cmd
 }()
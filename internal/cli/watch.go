@@ -0,0 +1,222 @@
+package cli
+
+import (
+	"cmp"
+	"context"
+	stdErrors "errors"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"syscall"
+
+	"github.com/rprtr258/fun"
+	"github.com/rs/zerolog/log"
+
+	"github.com/rprtr258/pm/internal/core"
+	"github.com/rprtr258/pm/internal/errors"
+	"github.com/rprtr258/pm/internal/fsnotify"
+)
+
+// watchMatcher decides which changed files trigger restart
+type watchMatcher struct {
+	include   []*regexp.Regexp
+	exclude   []*regexp.Regexp
+	gitignore *core.Gitignore // nil if gitignore is not honoured or there is no repository
+}
+
+func newWatchMatcher(watch core.Watch, toplevel string) (*watchMatcher, error) {
+	include, err := fun.MapErr[*regexp.Regexp](core.CompileWatchPattern, watch.Include...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "compile include patterns")
+	}
+
+	exclude, err := fun.MapErr[*regexp.Regexp](core.CompileWatchPattern, watch.Exclude...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "compile exclude patterns")
+	}
+
+	var gi *core.Gitignore
+	if watch.Gitignore && toplevel != "" {
+		gi = core.NewGitignore(toplevel)
+	}
+
+	return &watchMatcher{
+		include:   include,
+		exclude:   exclude,
+		gitignore: gi,
+	}, nil
+}
+
+// ignored checks whether file or directory at absolute path under root must
+// not be watched at all
+func (m *watchMatcher) ignored(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return false
+	}
+	rel = filepath.ToSlash(rel)
+
+	// ignore changes in git directory
+	if rel == ".git" ||
+		strings.HasPrefix(rel, ".git/") ||
+		strings.HasSuffix(rel, "/.git") ||
+		strings.Contains(rel, "/.git/") {
+		return true
+	}
+
+	for _, re := range m.exclude {
+		if re.MatchString(rel) {
+			return true
+		}
+	}
+
+	return m.gitignore != nil && m.gitignore.Ignored(path)
+}
+
+// matches checks whether change of file at absolute path under root must
+// trigger restart
+func (m *watchMatcher) matches(root, path string) bool {
+	if m.ignored(root, path) {
+		return false
+	}
+
+	if len(m.include) == 0 {
+		return true
+	}
+
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		log.Error().
+			Err(err).
+			Str("path", path).
+			Str("dir", root).
+			Msg("get relative filename failed")
+		return false
+	}
+
+	for _, re := range m.include {
+		if re.MatchString(filepath.ToSlash(rel)) {
+			return true
+		}
+	}
+	return false
+}
+
+// fileWatcher is either inotify based or polling watcher
+type fileWatcher struct {
+	events <-chan []fsnotify.Event
+	errors <-chan error
+	close  func() error
+}
+
+// isWatchLimitError reports whether inotify instances or watches limit is reached
+func isWatchLimitError(err error) bool {
+	return stdErrors.Is(err, syscall.ENOSPC) || stdErrors.Is(err, syscall.EMFILE)
+}
+
+// newFileWatcher of root directory using configured watch mode
+func newFileWatcher(
+	root, toplevel string,
+	watch core.Watch,
+	ignore func(string) bool,
+) (fileWatcher, error) {
+	if watch.Mode != core.WatchModePoll {
+		watcher, err := fsnotify.NewBatchedRecursiveWatcher(root, toplevel, cmp.Or(watch.Debounce, _batchWindow), ignore)
+		if err == nil {
+			return fileWatcher{
+				events: watcher.Events,
+				errors: watcher.Errors,
+				close:  watcher.Close,
+			}, nil
+		}
+
+		if watch.Mode == core.WatchModeNotify || !isWatchLimitError(err) {
+			return fun.Zero[fileWatcher](), errors.Wrapf(err, "create watcher for %s", root)
+		}
+
+		log.Warn().
+			Err(err).
+			Str("root", root).
+			Msg("inotify limit reached, falling back to polling")
+	}
+
+	watcher, err := fsnotify.NewPollingWatcher(root, cmp.Or(watch.PollInterval, _batchWindow), ignore)
+	if err != nil {
+		return fun.Zero[fileWatcher](), errors.Wrapf(err, "create polling watcher for %s", root)
+	}
+
+	return fileWatcher{
+		events: watcher.Events,
+		errors: watcher.Errors,
+		close:  watcher.Close,
+	}, nil
+}
+
+// initWatchChannel starts watching paths of watch config, batches of changes
+// to files matching config are sent to ch
+func initWatchChannel(
+	ctx context.Context,
+	ch chan<- []fsnotify.Event,
+	cwd string,
+	watch core.Watch,
+) (func(), error) {
+	roots := watch.Paths
+	if len(roots) == 0 {
+		roots = []string{cwd}
+	}
+
+	watchers := make([]fileWatcher, 0, len(roots))
+	closeWatchers := func() {
+		log.Debug().Msg("closing watchers")
+		for _, w := range watchers {
+			if err := w.close(); err != nil {
+				log.Error().Err(err).Msg("failed to close watcher")
+			}
+		}
+	}
+
+	for _, root := range roots {
+		toplevel, _ := core.FindGitToplevel(root)
+
+		matcher, err := newWatchMatcher(watch, toplevel)
+		if err != nil {
+			closeWatchers()
+			return nil, err
+		}
+
+		watcher, err := newFileWatcher(root, toplevel, watch, func(path string) bool {
+			return matcher.ignored(root, path)
+		})
+		if err != nil {
+			closeWatchers()
+			return nil, err
+		}
+		watchers = append(watchers, watcher)
+
+		go func() {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case err := <-watcher.errors:
+					if err != nil {
+						log.Error().Err(err).Str("root", root).Msg("fsnotify error")
+					}
+					return
+				case events, ok := <-watcher.events:
+					if !ok {
+						return
+					}
+
+					if fun.Any(func(event fsnotify.Event) bool {
+						return matcher.matches(root, event.Name)
+					}, events...) {
+						ch <- events
+					}
+				}
+			}
+		}()
+	}
+
+	return closeWatchers, nil
+}
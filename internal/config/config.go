@@ -17,6 +17,7 @@ import (
 	"github.com/rprtr258/pm/internal/core"
 	"github.com/rprtr258/pm/internal/db"
 	"github.com/rprtr258/pm/internal/errors"
+	"github.com/rprtr258/pm/internal/eventlog"
 )
 
 func ensureDir(dirname string) error {
@@ -40,13 +41,15 @@ func pruneLogs(db db.Handle) error {
 		return errors.Wrapf(err, "read log dir %s", core.DirLogs)
 	}
 
-	procs, err := db.List(core.WithAllIfNoFilters)
+	// NOTE: records which cannot be read, e.g. written by newer pm, and
+	// quarantined ones keep their logs
+	procIDs, err := db.IDs()
 	if err != nil {
 		return errors.Wrapf(err, "get procs")
 	}
 
-	ids := make(map[core.PMID]struct{}, len(procs))
-	for id := range procs {
+	ids := make(map[core.PMID]struct{}, len(procIDs))
+	for _, id := range procIDs {
 		ids[id] = struct{}{}
 	}
 
@@ -112,6 +115,13 @@ func New() (db.Handle, core.Config, error) {
 			return errors.Wrapf(err, "ensure home dir %s", core.DirHome)
 		}
 
+		// NOTE: config and db of older pm versions are backed up to the same dir
+		backupDir := filepath.Join(core.DirBackups, time.Now().Format("20060102T150405"))
+
+		if _, err := core.MigrateConfig(backupDir); err != nil {
+			return errors.Wrap(err, "migrate config")
+		}
+
 		var errConfig error
 		config, errConfig = core.ReadConfig()
 		if errConfig != nil {
@@ -124,20 +134,24 @@ func New() (db.Handle, core.Config, error) {
 			return errors.Wrapf(err, "ensure logs dir %s", core.DirLogs)
 		}
 
-		if core.Version != "dev" && config.Version != core.Version {
-			return errors.Newf("config version mismatch, config=%s, pm=%s", config.Version, core.Version)
-		}
-
 		var errDB error
 		dbFs, errDB = db.InitRealDir(core.DirDB)
 		if errDB != nil {
 			return errors.Wrapf(errDB, "new db, dir=%q", core.DirDB)
 		}
 
+		if _, err := db.New(dbFs).Migrate(afero.NewOsFs(), filepath.Join(backupDir, "db")); err != nil {
+			return errors.Wrap(err, "migrate db")
+		}
+
 		if err := pruneLogs(db.New(dbFs)); err != nil {
 			return errors.Wrap(err, "prune logs")
 		}
 
+		if err := eventlog.Rotate(core.FileEvents); err != nil {
+			return errors.Wrap(err, "rotate events log")
+		}
+
 		return nil
 	}(); err != nil {
 		return fun.Zero[db.Handle](), fun.Zero[core.Config](), err
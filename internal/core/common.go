@@ -6,13 +6,22 @@ import (
 	"github.com/adrg/xdg"
 )
 
-const EnvPMID = "PM_PMID"
+const (
+	EnvPMID            = "PM_PMID"
+	EnvInstance        = "PM_INSTANCE"       // index of replica in group
+	EnvNodeAppInstance = "NODE_APP_INSTANCE" // same as EnvInstance, for pm2 compatibility
+)
 
 var (
-	DirHome     = filepath.Join(xdg.DataHome, "pm")
-	DirLogs     = filepath.Join(DirHome, "logs")
-	DirDB       = filepath.Join(DirHome, "db")
-	_configPath = filepath.Join(xdg.ConfigHome, "pm.json")
+	DirHome      = filepath.Join(xdg.DataHome, "pm")
+	DirLogs      = filepath.Join(DirHome, "logs")
+	DirDB        = filepath.Join(DirHome, "db")
+	DirBackups   = filepath.Join(DirHome, "backups")
+	DirSnapshots = filepath.Join(DirHome, "snapshots")
+	FileEvents   = filepath.Join(DirHome, "events.jsonl")
+	_configPath  = filepath.Join(xdg.ConfigHome, "pm.json")
+	// DirUserConfigs is per-user dir with config files, run on startup
+	DirUserConfigs = filepath.Join(xdg.ConfigHome, "pm.d")
 )
 
 type LogType int
@@ -21,8 +30,14 @@ const (
 	LogTypeUnspecified LogType = iota
 	LogTypeStdout
 	LogTypeStderr
+	LogTypeBuild // output of build command
 )
 
+// BuildLogFile is file with output of process build command
+func BuildLogFile(dirLogs string, id PMID) string {
+	return filepath.Join(dirLogs, id.String()+".build")
+}
+
 type LogLine struct {
 	ProcID   PMID
 	ProcName string
@@ -5,6 +5,7 @@ import (
 	stdErrors "errors"
 	"io/fs"
 	"os"
+	"path/filepath"
 
 	"github.com/rprtr258/fun"
 	"github.com/rs/zerolog/log"
@@ -15,14 +16,37 @@ import (
 // NOTE: being set at compile time using ldflags
 var Version = "dev"
 
+// ConfigSchemaVersion is version of config file format, it is increased on
+// incompatible changes and old configs are upgraded by _configMigrations
+const ConfigSchemaVersion = 1
+
 type Config struct {
-	Version string
-	Debug   bool
+	Version       string // Version - version of pm which last wrote config
+	SchemaVersion int    // SchemaVersion - version of config format
+	Debug         bool
+	PortRange     [2]int // PortRange - inclusive range to allocate process ports from
 }
 
 var DefaultConfig = Config{
-	Version: Version,
-	Debug:   false,
+	Version:       Version,
+	SchemaVersion: ConfigSchemaVersion,
+	Debug:         false,
+	PortRange:     [2]int{20000, 29999},
+}
+
+// _configMigrations[i] upgrades config fields from schema version i to i+1
+var _configMigrations = []func(config map[string]json.RawMessage) error{
+	// 0 -> 1: schema version is introduced, nothing else changed
+	func(map[string]json.RawMessage) error { return nil },
+}
+
+// Ports range to allocate process ports from, default one if not configured
+func (c Config) Ports() (int, int) {
+	if c.PortRange == [2]int{} {
+		return DefaultConfig.PortRange[0], DefaultConfig.PortRange[1]
+	}
+
+	return c.PortRange[0], c.PortRange[1]
 }
 
 func writeConfig(config Config) error {
@@ -31,11 +55,93 @@ func writeConfig(config Config) error {
 		return errors.Wrapf(errMarshal, "marshal config")
 	}
 
-	if errWrite := os.WriteFile(_configPath, configBytes, 0o640); errWrite != nil {
-		return errors.Wrapf(errWrite, "write config %q", _configPath)
+	return writeConfigBytes(configBytes)
+}
+
+// writeConfigBytes to temporary file and rename it, so that config is never
+// partially written
+func writeConfigBytes(configBytes []byte) error {
+	tmp := _configPath + ".tmp"
+	if errWrite := os.WriteFile(tmp, configBytes, 0o640); errWrite != nil {
+		return errors.Wrapf(errWrite, "write config %q", tmp)
+	}
+
+	return errors.Wrapf(os.Rename(tmp, _configPath), "rename config %q", tmp)
+}
+
+// MigrateConfig upgrades config file written by other pm version. Config is
+// copied to backupDir before being changed. Returns whether config was
+// migrated, missing config is not migrated.
+func MigrateConfig(backupDir string) (bool, error) {
+	configBytes, errRead := os.ReadFile(_configPath)
+	if errRead != nil {
+		if stdErrors.Is(errRead, fs.ErrNotExist) {
+			return false, nil
+		}
+
+		return false, errors.Wrapf(errRead, "read config file %q", _configPath)
+	}
+
+	var config map[string]json.RawMessage
+	if errUnmarshal := json.Unmarshal(configBytes, &config); errUnmarshal != nil {
+		return false, errors.Wrapf(errUnmarshal, "parse config")
+	}
+
+	var schemaVersion int
+	if raw, ok := config["SchemaVersion"]; ok {
+		if err := json.Unmarshal(raw, &schemaVersion); err != nil {
+			return false, errors.Wrapf(err, "parse config schema version")
+		}
+	}
+
+	var version string
+	if raw, ok := config["Version"]; ok {
+		if err := json.Unmarshal(raw, &version); err != nil {
+			return false, errors.Wrapf(err, "parse config version")
+		}
+	}
+
+	switch {
+	case schemaVersion > ConfigSchemaVersion:
+		return false, errors.Newf(
+			"config schema version %d is newer than supported %d, config was written by pm %s, upgrade pm",
+			schemaVersion, ConfigSchemaVersion, version)
+	case schemaVersion == ConfigSchemaVersion && (version == Version || Version == "dev"):
+		return false, nil
+	}
+
+	if err := os.MkdirAll(backupDir, 0o755); err != nil {
+		return false, errors.Wrapf(err, "create backup dir %q", backupDir)
+	}
+	backupPath := filepath.Join(backupDir, filepath.Base(_configPath))
+	if err := os.WriteFile(backupPath, configBytes, 0o640); err != nil {
+		return false, errors.Wrapf(err, "backup config to %q", backupPath)
+	}
+
+	for v := schemaVersion; v < ConfigSchemaVersion; v++ {
+		if err := _configMigrations[v](config); err != nil {
+			return false, errors.Wrapf(err, "migrate config from schema version %d", v)
+		}
+	}
+
+	config["Version"], _ = json.Marshal(Version)
+	config["SchemaVersion"], _ = json.Marshal(ConfigSchemaVersion)
+
+	newConfigBytes, errMarshal := json.Marshal(config)
+	if errMarshal != nil {
+		return false, errors.Wrapf(errMarshal, "marshal config")
+	}
+
+	if err := writeConfigBytes(newConfigBytes); err != nil {
+		return false, err
 	}
 
-	return nil
+	log.Info().
+		Str("from", version).
+		Str("to", Version).
+		Str("backup", backupPath).
+		Msg("migrated config")
+	return true, nil
 }
 
 func ReadConfig() (Config, error) {
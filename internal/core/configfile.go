@@ -0,0 +1,427 @@
+package core
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	stdErrors "errors"
+	"fmt"
+	"maps"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/rprtr258/fun"
+	"sigs.k8s.io/yaml"
+
+	"github.com/rprtr258/pm/internal/errors"
+)
+
+// ConfigSchema is JSON Schema of config file
+//
+//go:embed config.schema.json
+var ConfigSchema []byte
+
+// evaluateConfigFile into json, parser is chosen by format
+func evaluateConfigFile(filename string, format ConfigFormat, cfg loadConfig) ([]byte, error) {
+	if format == ConfigFormatJsonnet {
+		jsonText, err := newVM(cfg).EvaluateFile(filename)
+		if err != nil {
+			return nil, errors.Wrapf(err, "evaluate jsonnet file")
+		}
+		return []byte(jsonText), nil
+	}
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, errors.Wrapf(err, "read %s", filename)
+	}
+
+	switch format {
+	case ConfigFormatJSON:
+		return data, nil
+	case ConfigFormatYAML:
+		res, err := yaml.YAMLToJSON(data)
+		return res, errors.Wrapf(err, "parse yaml")
+	case ConfigFormatTOML:
+		var v map[string]any
+		if _, err := toml.Decode(string(data), &v); err != nil {
+			return nil, errors.Wrapf(err, "parse toml")
+		}
+
+		res, err := json.Marshal(v)
+		return res, errors.Wrapf(err, "convert toml to json")
+	case ConfigFormatCompose:
+		return nil, errors.Newf("docker compose files are loaded with pm import")
+	default:
+		return nil, errors.Newf("format %q can't be evaluated", format)
+	}
+}
+
+// configFileDTO is config file in object form
+type configFileDTO struct {
+	Apps     []map[string]json.RawMessage          `json:"apps"`
+	Defaults map[string]json.RawMessage            `json:"defaults"`
+	Profiles map[string]map[string]json.RawMessage `json:"profiles"`
+}
+
+// mergeConfigEntries merges overlay into base: objects are merged
+// recursively, other values of overlay replace values of base
+func mergeConfigEntries(base, overlay map[string]json.RawMessage) map[string]json.RawMessage {
+	res := make(map[string]json.RawMessage, len(base)+len(overlay))
+	maps.Copy(res, base)
+	for key, value := range overlay {
+		res[key] = mergeConfigValues(res[key], value)
+	}
+	return res
+}
+
+func mergeConfigValues(base, overlay json.RawMessage) json.RawMessage {
+	var baseObject, overlayObject map[string]json.RawMessage
+	if base == nil ||
+		json.Unmarshal(base, &baseObject) != nil || baseObject == nil ||
+		json.Unmarshal(overlay, &overlayObject) != nil || overlayObject == nil {
+		return overlay
+	}
+
+	merged, _ := json.Marshal(mergeConfigEntries(baseObject, overlayObject))
+	return merged
+}
+
+// isOverlayKey is key of app which is applied to app when profile is selected
+func isOverlayKey(key string) bool {
+	return key == "profiles" || strings.HasPrefix(key, "env_")
+}
+
+// decodeConfigEntries of config file, which is either list of apps or object
+// with apps, defaults and profiles. Defaults and overlay of selected profile
+// are merged into each app. Names of profiles defined in file are returned.
+func decodeConfigEntries(data []byte, profile string) ([]map[string]json.RawMessage, []string, error) {
+	data = bytes.TrimSpace(data)
+	if bytes.HasPrefix(data, []byte("[")) {
+		var apps []map[string]json.RawMessage
+		if err := json.Unmarshal(data, &apps); err != nil {
+			return nil, nil, errors.Wrapf(err, "expected list of apps")
+		}
+		return apps, nil, nil
+	}
+
+	var keys map[string]json.RawMessage
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return nil, nil, errors.Wrapf(err, "expected list of apps or object with apps, defaults and profiles")
+	}
+	var file configFileDTO
+	for _, key := range slices.Sorted(maps.Keys(keys)) {
+		if !fun.Contains(key, "apps", "defaults", "profiles") {
+			return nil, nil, keyError{
+				key: key,
+				err: errors.Newf("unknown key %q, expected apps, defaults and profiles", key),
+			}
+		}
+
+		// NOTE: decode keys one by one to know which key is invalid
+		b, _ := json.Marshal(map[string]json.RawMessage{key: keys[key]})
+		if err := json.Unmarshal(b, &file); err != nil {
+			return nil, nil, decodeKeyError(key, err)
+		}
+	}
+
+	for _, key := range slices.Sorted(maps.Keys(file.Defaults)) {
+		if !fun.Contains(key, _configKeys...) && !isOverlayKey(key) {
+			return nil, nil, keyError{
+				key: "defaults." + key,
+				err: unknownKeyError(key),
+			}
+		}
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(file.Profiles)) {
+		for _, key := range slices.Sorted(maps.Keys(file.Profiles[name])) {
+			if !fun.Contains(key, _configKeys...) {
+				return nil, nil, keyError{
+					key: "profiles",
+					err: errors.Wrapf(unknownKeyError(key), "profile %q", name),
+				}
+			}
+		}
+	}
+
+	apps := fun.Map[map[string]json.RawMessage](func(app map[string]json.RawMessage) map[string]json.RawMessage {
+		app = mergeConfigEntries(file.Defaults, app)
+		if overlay, ok := file.Profiles[profile]; ok {
+			app = mergeConfigEntries(app, overlay)
+		}
+		return app
+	}, file.Apps...)
+	return apps, fun.Keys(file.Profiles), nil
+}
+
+// applyEntryProfile overlays of app: profiles.<profile> is merged into app and
+// env_<profile> is merged into env. Overlay keys are removed from app. Names
+// of profiles defined by app are returned.
+func applyEntryProfile(entry map[string]json.RawMessage, profile string) (map[string]json.RawMessage, []string, error) {
+	entry = maps.Clone(entry)
+
+	var profiles map[string]map[string]json.RawMessage
+	if raw, ok := entry["profiles"]; ok {
+		if err := json.Unmarshal(raw, &profiles); err != nil {
+			return nil, nil, decodeKeyError("profiles", err)
+		}
+		delete(entry, "profiles")
+	}
+	names := fun.Keys(profiles)
+
+	envs := map[string]json.RawMessage{}
+	for key, value := range entry {
+		if name, ok := strings.CutPrefix(key, "env_"); ok {
+			envs[name] = value
+			names = append(names, name)
+			delete(entry, key)
+		}
+	}
+
+	if overlay, ok := profiles[profile]; ok && profile != "" {
+		entry = mergeConfigEntries(entry, overlay)
+	}
+	if env, ok := envs[profile]; ok && profile != "" {
+		entry = mergeConfigEntries(entry, map[string]json.RawMessage{"env": env})
+	}
+	return entry, names, nil
+}
+
+// ConfigError is problem with app in config file
+type ConfigError struct {
+	Filename     string
+	Line, Column int    // position of app or its key in config file, zero if unknown
+	Entry        int    // index of app in config file, negative for problems with whole file
+	Name         string // name of app, empty if not set
+	Key          string // key of app with invalid value, empty if unknown
+	Err          error
+}
+
+func (e *ConfigError) Error() string {
+	pos := e.Filename
+	if e.Line > 0 {
+		pos = fmt.Sprintf("%s:%d:%d", e.Filename, e.Line, e.Column)
+	}
+
+	if e.Entry < 0 {
+		return fmt.Sprintf("%s: %s", pos, e.Err)
+	}
+
+	if e.Name == "" {
+		return fmt.Sprintf("%s: apps[%d]: %s", pos, e.Entry, e.Err)
+	}
+
+	return fmt.Sprintf("%s: apps[%d] %q: %s", pos, e.Entry, e.Name, e.Err)
+}
+
+func (e *ConfigError) Unwrap() error {
+	return e.Err
+}
+
+// keyError is error in value of key of config entry, key is used to find
+// position of error in config file
+type keyError struct {
+	key string
+	err error
+}
+
+func (e keyError) Error() string {
+	return e.err.Error()
+}
+
+func (e keyError) Unwrap() error {
+	return e.err
+}
+
+// withKey marks error as caused by value of key
+func withKey(key string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return keyError{
+		key: key,
+		err: err,
+	}
+}
+
+// _configKeys are keys of config entry
+var _configKeys = func() []string {
+	typ := reflect.TypeFor[configScanDTO]()
+	keys := make([]string, typ.NumField())
+	for i := range typ.NumField() {
+		keys[i], _, _ = strings.Cut(typ.Field(i).Tag.Get("json"), ",")
+	}
+	return keys
+}()
+
+// unknownKeyError with suggestion of known key, if there is similar one
+func unknownKeyError(key string) error {
+	if suggestion, ok := closestKey(key, _configKeys...).Unpack(); ok {
+		return errors.Newf("unknown key %q, did you mean %q?", key, suggestion)
+	}
+
+	return errors.Newf("unknown key %q", key)
+}
+
+// closestKey to given one, differing by at most two edits
+func closestKey(key string, keys ...string) fun.Option[string] {
+	res, best := fun.Invalid[string](), 3
+	for _, k := range keys {
+		if d := editDistance(key, k); d < best {
+			res, best = fun.Valid(k), d
+		}
+	}
+	return res
+}
+
+// editDistance of strings, number of inserted, deleted and replaced chars
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := range len(a) {
+		cur := make([]int, len(b)+1)
+		cur[0] = i + 1
+		for j := range len(b) {
+			cur[j+1] = min(prev[j+1]+1, cur[j]+1, prev[j]+fun.IF(a[i] == b[j], 0, 1))
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+// decodeKeyError names key with invalid value in config entry, nested key
+// is named if it is known
+func decodeKeyError(key string, err error) error {
+	var errType *json.UnmarshalTypeError
+	if stdErrors.As(err, &errType) {
+		field := errType.Field
+		switch {
+		case field == "":
+			field = key
+		case field != key && !strings.HasPrefix(field, key+"."):
+			// error from nested decoder, field is relative to key
+			field = key + "." + field
+		}
+
+		return keyError{
+			key: key,
+			err: errors.Newf("key %q: expected %s, got %s", field, configTypeName(errType.Type), errType.Value),
+		}
+	}
+
+	// NOTE: json package has no typed error for unknown fields
+	if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		return keyError{
+			key: key,
+			err: errors.Newf("key %q: unknown key %s", key, field),
+		}
+	}
+
+	return keyError{
+		key: key,
+		err: errors.Wrapf(err, "key %q", key),
+	}
+}
+
+// configTypeName of go type as it is named in config
+func configTypeName(typ reflect.Type) string {
+	switch typ.Kind() { //nolint:exhaustive // other kinds are not used in config
+	case reflect.Slice, reflect.Array:
+		return "list"
+	case reflect.Map, reflect.Struct:
+		return "object"
+	case reflect.Pointer:
+		return configTypeName(typ.Elem())
+	case reflect.Bool:
+		return "bool"
+	case reflect.String:
+		return "string"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	default:
+		return typ.String()
+	}
+}
+
+// jsonTypeName of decoded json value
+func jsonTypeName(value any) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case map[string]any:
+		return "object"
+	case []any:
+		return "list"
+	default:
+		return configTypeName(reflect.TypeOf(value))
+	}
+}
+
+// unmarshalStrict json, failing on unknown fields
+func unmarshalStrict(data []byte, v any) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(v)
+}
+
+// configEntryName of config entry, if it is set
+func configEntryName(entry map[string]json.RawMessage) string {
+	var name string
+	if raw, ok := entry["name"]; ok && json.Unmarshal(raw, &name) == nil {
+		return name
+	}
+	return ""
+}
+
+// decodeConfigEntry into config scan DTO, returning errors of all invalid keys
+func decodeConfigEntry(entry map[string]json.RawMessage) (configScanDTO, []error) {
+	var config configScanDTO
+	var errs []error
+	invalidKeys := []string{}
+	for _, key := range slices.Sorted(maps.Keys(entry)) {
+		if !fun.Contains(key, _configKeys...) {
+			errs = append(errs, keyError{
+				key: key,
+				err: unknownKeyError(key),
+			})
+			continue
+		}
+
+		// NOTE: decode keys one by one to know which key is invalid
+		b, _ := json.Marshal(map[string]json.RawMessage{key: entry[key]})
+		if err := unmarshalStrict(b, &config); err != nil {
+			errs = append(errs, decodeKeyError(key, err))
+			invalidKeys = append(invalidKeys, key)
+		}
+	}
+
+	for i, arg := range config.Args {
+		switch arg.(type) {
+		case string, float64, bool:
+		default:
+			errs = append(errs, keyError{
+				key: "args",
+				err: errors.Newf("key %q: element #%d: expected string, number or bool, got %s", "args", i, jsonTypeName(arg)),
+			})
+		}
+	}
+
+	if config.Command == "" && !fun.Contains("command", invalidKeys...) {
+		errs = append(errs, keyError{
+			key: "command",
+			err: errors.New("missing command"),
+		})
+	}
+
+	return config, errs
+}
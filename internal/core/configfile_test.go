@@ -0,0 +1,630 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rprtr258/fun"
+	"github.com/shoenig/test"
+)
+
+// writeConfigFile to temp dir, returning its filename
+func writeConfigFile(t *testing.T, name, data string) string {
+	t.Helper()
+
+	filename := filepath.Join(t.TempDir(), name)
+	test.NoError(t, os.WriteFile(filename, []byte(data), 0o644))
+	return filename
+}
+
+func TestLoadConfigsFormats(t *testing.T) {
+	t.Parallel()
+
+	type app struct {
+		Name    string
+		Command string
+		Args    []string
+		Env     map[string]string
+		Startup bool
+	}
+	want := []app{
+		{
+			Name:    "api",
+			Command: "./api",
+			Args:    []string{"--port", "8080", "1.5", "true"},
+			Env:     map[string]string{"LOG_LEVEL": "debug", "PORT": "8080"},
+			Startup: true,
+		},
+		{
+			Name:    "worker",
+			Command: "./worker",
+			Args:    nil,
+			Env:     map[string]string{"LOG_LEVEL": "info"},
+			Startup: true,
+		},
+	}
+
+	for name, data := range map[string]string{
+		"pm.yaml": `
+defaults:
+  env: {LOG_LEVEL: debug}
+  startup: true
+apps:
+  - name: api
+    command: ./api
+    args: [--port, 8080, 1.5, true]
+    env: {PORT: "8080"}
+  - name: worker
+    command: ./worker
+    env: {LOG_LEVEL: info}
+`,
+		"pm.toml": `
+[defaults]
+env = { LOG_LEVEL = "debug" }
+startup = true
+
+[[apps]]
+name = "api"
+command = "./api"
+args = ["--port", 8080, 1.5, true]
+env = { PORT = "8080" }
+
+[[apps]]
+name = "worker"
+command = "./worker"
+  [apps.env]
+  LOG_LEVEL = "info"
+`,
+		"pm.json": `{
+  "defaults": {"env": {"LOG_LEVEL": "debug"}, "startup": true},
+  "apps": [
+    {"name": "api", "command": "./api", "args": ["--port", 8080, 1.5, true], "env": {"PORT": "8080"}},
+    {"name": "worker", "command": "./worker", "env": {"LOG_LEVEL": "info"}}
+  ]
+}`,
+		"pm.jsonnet": `
+local app(name) = {name: name, command: "./" + name};
+{
+  defaults: {env: {LOG_LEVEL: "debug"}, startup: true},
+  apps: [
+    app("api") + {args: ["--port", 8080, 1.5, true], env: {PORT: "8080"}},
+    app("worker") + {env: {LOG_LEVEL: "info"}},
+  ],
+}`,
+	} {
+		configs, err := LoadConfigs(writeConfigFile(t, name, data))
+		test.NoError(t, err, test.Sprint(name))
+
+		got := make([]app, len(configs))
+		for i, config := range configs {
+			got[i] = app{
+				Name:    config.Name,
+				Command: config.Command,
+				Args:    config.Args,
+				Env:     config.Env,
+				Startup: config.Startup,
+			}
+		}
+		test.Eq(t, want, got, test.Sprint(name))
+	}
+}
+
+func TestValidateConfigsPositions(t *testing.T) {
+	t.Parallel()
+
+	for name, tc := range map[string]struct {
+		filename string
+		data     string
+		want     []string // errors without filename prefix
+	}{
+		"yaml list": {
+			filename: "pm.yaml",
+			data: `- name: a
+  command: x
+- name: a
+  command: "y"
+  cron: "* * *"
+`,
+			want: []string{
+				`3:3: apps[1] "a": duplicate name "a", already used by apps[0]`,
+				`5:3: apps[1] "a": invalid cron: invalid cron expression: "* * *"`,
+			},
+		},
+		"yaml object": {
+			filename: "pm.yaml",
+			data: `defaults:
+  env:
+    LOG: debug
+apps:
+  - name: api
+    command: ./api
+    depend_on: [db]
+  - name: db
+    command: 5
+  - command: ./worker
+    args: [1, {a: 1}]
+`,
+			want: []string{
+				`7:5: apps[0] "api": unknown key "depend_on", did you mean "depends_on"?`,
+				`9:5: apps[1] "db": key "command": expected string, got number`,
+				`11:5: apps[2]: key "args": element #1: expected string, number or bool, got object`,
+			},
+		},
+		"yaml defaults": {
+			filename: "pm.yaml",
+			data: `defaults:
+  comand: x
+apps:
+  - name: a
+    command: x
+`,
+			want: []string{
+				`2:3: unknown key "comand", did you mean "command"?`,
+			},
+		},
+		"toml": {
+			filename: "pm.toml",
+			data: `[defaults]
+env = { LOG = "debug" }
+
+[[apps]]
+name = "api"
+command = "./api"
+depend_on = ["db"]
+
+[[apps]]
+  name = "db"
+  command = 5
+`,
+			want: []string{
+				`7:1: apps[0] "api": unknown key "depend_on", did you mean "depends_on"?`,
+				`11:3: apps[1] "db": key "command": expected string, got number`,
+			},
+		},
+		"toml inherited from defaults": {
+			filename: "pm.toml",
+			data: `[defaults]
+command = "./app"
+start_delay = "soon"
+
+[[apps]]
+name = "a"
+`,
+			want: []string{
+				`3:1: apps[0] "a": invalid start_delay "soon": time: invalid duration "soon"`,
+			},
+		},
+		"toml top level": {
+			filename: "pm.toml",
+			data: `app = 1
+
+[[apps]]
+name = "a"
+command = "x"
+`,
+			want: []string{
+				`1:1: unknown key "app", expected apps, defaults and profiles`,
+			},
+		},
+		"jsonnet": {
+			filename: "pm.jsonnet",
+			data: `local base = {command: "x"};
+[
+  base + {name: "a"},
+  base + {
+    name: "b",
+    watch: "(",
+  },
+]
+`,
+			want: []string{
+				`6:5: apps[1] "b": invalid watch: invalid watch pattern: compile regex "(": error parsing regexp: missing closing ): ` + "`(`",
+			},
+		},
+	} {
+		filename := writeConfigFile(t, tc.filename, tc.data)
+		errs, err := ValidateConfigs(filename)
+		test.NoError(t, err, test.Sprint(name))
+
+		got := make([]string, len(errs))
+		for i, err := range errs {
+			got[i] = strings.TrimPrefix(err.Error(), filename+":")
+		}
+		test.Eq(t, tc.want, got, test.Sprint(name))
+	}
+}
+
+func TestLoadConfigsLazy(t *testing.T) {
+	t.Parallel()
+
+	for name, tc := range map[string]struct {
+		app         string
+		lazy        bool
+		idleTimeout time.Duration
+		err         string
+	}{
+		"eager": {
+			app: `{"name": "a", "command": "x", "listen": [":8080"]}`,
+		},
+		"lazy": {
+			app:  `{"name": "a", "command": "x", "listen": [":8080"], "lazy": true}`,
+			lazy: true,
+		},
+		"lazy with idle timeout": {
+			app:         `{"name": "a", "command": "x", "listen": ["unix:/run/a.sock"], "lazy": true, "idle_timeout": "10m"}`,
+			lazy:        true,
+			idleTimeout: 10 * time.Minute,
+		},
+		"lazy without sockets": {
+			app: `{"name": "a", "command": "x", "lazy": true}`,
+			err: `:1:32: apps[0] "a": lazy and idle_timeout require listen sockets`,
+		},
+		"idle timeout without sockets": {
+			app: `{"name": "a", "command": "x", "idle_timeout": "1m"}`,
+			err: `:1:32: apps[0] "a": lazy and idle_timeout require listen sockets`,
+		},
+		"invalid idle timeout": {
+			app: `{"name": "a", "command": "x", "listen": [":8080"], "idle_timeout": "later"}`,
+			err: `:1:53: apps[0] "a": invalid idle_timeout "later": time: invalid duration "later"`,
+		},
+		"invalid listen address": {
+			app: `{"name": "a", "command": "x", "listen": ["8080"], "lazy": true}`,
+			err: `:1:32: apps[0] "a": invalid listen: invalid listen address "8080", expected network:address`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			filename := writeConfigFile(t, "pm.json", "["+tc.app+"]")
+			configs, err := LoadConfigs(filename)
+			if tc.err != "" {
+				test.EqError(t, err, filename+tc.err)
+				return
+			}
+			test.NoError(t, err)
+			test.SliceLen(t, 1, configs)
+			test.EqOp(t, tc.lazy, configs[0].Lazy)
+			test.EqOp(t, tc.idleTimeout, configs[0].IdleTimeout)
+		})
+	}
+}
+
+func TestLoadConfigsBuild(t *testing.T) {
+	t.Parallel()
+
+	for name, tc := range map[string]struct {
+		build   string
+		noWatch bool
+		want    fun.Option[Hook]
+		err     string
+	}{
+		"none": {
+			build: `null`,
+			want:  fun.Invalid[Hook](),
+		},
+		"aborts restart by default": {
+			build: `"go build -o app ."`,
+			want: fun.Valid(Hook{
+				Command:   "/bin/sh",
+				Args:      []string{"-c", "go build -o app ."},
+				OnFailure: HookFailureAbort,
+				Timeout:   0,
+			}),
+		},
+		"ignored failure": {
+			build: `{"command": "make", "args": ["app"], "on_failure": "ignore", "timeout": "1m"}`,
+			want: fun.Valid(Hook{
+				Command:   "make",
+				Args:      []string{"app"},
+				OnFailure: HookFailureIgnore,
+				Timeout:   time.Minute,
+			}),
+		},
+		"argv": {
+			build: `["make", "app"]`,
+			want: fun.Valid(Hook{
+				Command:   "make",
+				Args:      []string{"app"},
+				OnFailure: HookFailureAbort,
+				Timeout:   0,
+			}),
+		},
+		"missing command": {
+			build: `{"timeout": "1m"}`,
+			err:   `:1:32: apps[0] "a": invalid build: hook build: missing command`,
+		},
+		"without watch": {
+			build:   `"make"`,
+			noWatch: true,
+			err:     `:1:32: apps[0] "a": build requires watch`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			watch := fun.IF(tc.noWatch, "", `, "watch": "\\.go$"`)
+			filename := writeConfigFile(t, "pm.json", `[{"name": "a", "command": "x", "build": `+tc.build+watch+`}]`)
+			configs, err := LoadConfigs(filename)
+			if tc.err != "" {
+				test.EqError(t, err, filename+tc.err)
+				return
+			}
+			test.NoError(t, err)
+			test.SliceLen(t, 1, configs)
+			test.Eq(t, tc.want, configs[0].Build)
+		})
+	}
+}
+
+func TestMergeConfigEntries(t *testing.T) {
+	t.Parallel()
+
+	for name, tc := range map[string]struct {
+		base, overlay, want string
+	}{
+		"empty overlay": {
+			base:    `{"command": "./app", "env": {"A": "1"}}`,
+			overlay: `{}`,
+			want:    `{"command": "./app", "env": {"A": "1"}}`,
+		},
+		"values are replaced": {
+			base:    `{"command": "./app", "args": ["a", "b"], "startup": true}`,
+			overlay: `{"args": ["c"], "startup": false}`,
+			want:    `{"command": "./app", "args": ["c"], "startup": false}`,
+		},
+		"objects are merged recursively": {
+			base:    `{"env": {"A": "1", "B": "2"}, "cron": {"schedule": {"every": "1m"}, "timezone": "UTC"}}`,
+			overlay: `{"env": {"B": "3", "C": "4"}, "cron": {"schedule": {"at": "1h"}}}`,
+			want:    `{"env": {"A": "1", "B": "3", "C": "4"}, "cron": {"schedule": {"every": "1m", "at": "1h"}, "timezone": "UTC"}}`,
+		},
+		"object replaces other value": {
+			base:    `{"watch": "\\.go$"}`,
+			overlay: `{"watch": {"paths": ["src"]}}`,
+			want:    `{"watch": {"paths": ["src"]}}`,
+		},
+		"other value replaces object": {
+			base:    `{"hooks": {"pre_start": "make"}, "env": {"A": "1"}}`,
+			overlay: `{"hooks": null, "env": "oops"}`,
+			want:    `{"hooks": null, "env": "oops"}`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var base, overlay, want map[string]any
+			test.NoError(t, json.Unmarshal([]byte(tc.base), &base))
+			test.NoError(t, json.Unmarshal([]byte(tc.overlay), &overlay))
+			test.NoError(t, json.Unmarshal([]byte(tc.want), &want))
+
+			raw := func(m map[string]any) map[string]json.RawMessage {
+				res := map[string]json.RawMessage{}
+				for key, value := range m {
+					res[key], _ = json.Marshal(value)
+				}
+				return res
+			}
+			merged, err := json.Marshal(mergeConfigEntries(raw(base), raw(overlay)))
+			test.NoError(t, err)
+
+			var got map[string]any
+			test.NoError(t, json.Unmarshal(merged, &got))
+			test.Eq(t, want, got)
+		})
+	}
+}
+
+func TestLoadConfigsProfiles(t *testing.T) {
+	t.Parallel()
+
+	filename := writeConfigFile(t, "pm.yaml", `
+defaults:
+  env: {LOG_LEVEL: info, REGION: eu}
+  args: [--verbose]
+profiles:
+  prod:
+    env: {LOG_LEVEL: warn}
+    startup: true
+apps:
+  - name: api
+    command: ./api
+    env: {PORT: "8080"}
+    env_dev: {LOG_LEVEL: debug}
+  - name: worker
+    command: ./worker
+    args: [--queue, default]
+    profiles:
+      prod:
+        args: [--queue, critical]
+      staging:
+        env: {REGION: us}
+`)
+
+	type app struct {
+		Name    string
+		Args    []string
+		Env     map[string]string
+		Startup bool
+		Profile string
+	}
+	for profile, tc := range map[string]struct {
+		want []app
+		err  string
+	}{
+		"": {
+			want: []app{
+				{"api", []string{"--verbose"}, map[string]string{"LOG_LEVEL": "info", "REGION": "eu", "PORT": "8080"}, false, ""},
+				{"worker", []string{"--queue", "default"}, map[string]string{"LOG_LEVEL": "info", "REGION": "eu"}, false, ""},
+			},
+		},
+		"prod": {
+			want: []app{
+				{"api", []string{"--verbose"}, map[string]string{"LOG_LEVEL": "warn", "REGION": "eu", "PORT": "8080"}, true, "prod"},
+				{"worker", []string{"--queue", "critical"}, map[string]string{"LOG_LEVEL": "warn", "REGION": "eu"}, true, "prod"},
+			},
+		},
+		"dev": {
+			want: []app{
+				{"api", []string{"--verbose"}, map[string]string{"LOG_LEVEL": "debug", "REGION": "eu", "PORT": "8080"}, false, "dev"},
+				{"worker", []string{"--queue", "default"}, map[string]string{"LOG_LEVEL": "info", "REGION": "eu"}, false, "dev"},
+			},
+		},
+		"staging": {
+			want: []app{
+				{"api", []string{"--verbose"}, map[string]string{"LOG_LEVEL": "info", "REGION": "eu", "PORT": "8080"}, false, "staging"},
+				{"worker", []string{"--queue", "default"}, map[string]string{"LOG_LEVEL": "info", "REGION": "us"}, false, "staging"},
+			},
+		},
+		"test": {
+			err: `unknown profile "test", defined profiles are ["dev" "prod" "staging"]`,
+		},
+	} {
+		t.Run(fun.IF(profile == "", "none", profile), func(t *testing.T) {
+			t.Parallel()
+
+			configs, err := LoadConfigs(filename, WithProfile(profile))
+			if tc.err != "" {
+				test.ErrorContains(t, err, tc.err)
+				return
+			}
+			test.NoError(t, err)
+
+			got := fun.Map[app](func(config RunConfig) app {
+				return app{config.Name, config.Args, config.Env, config.Startup, config.Profile}
+			}, configs...)
+			test.Eq(t, tc.want, got)
+		})
+	}
+}
+
+func TestLoadConfigsProfileJsonnet(t *testing.T) {
+	t.Parallel()
+
+	// jsonnet configs can use any profile
+	filename := writeConfigFile(t, "pm.jsonnet", `
+local profile = std.extVar("profile");
+[{name: "app", command: "./app", args: ["--env", if profile == "" then "local" else profile]}]
+`)
+	for profile, want := range map[string]string{
+		"":     "local",
+		"test": "test",
+	} {
+		configs, err := LoadConfigs(filename, WithProfile(profile))
+		test.NoError(t, err)
+		test.SliceLen(t, 1, configs)
+		test.Eq(t, []string{"--env", want}, configs[0].Args)
+		test.EqOp(t, profile, configs[0].Profile)
+	}
+}
+
+func TestConfigFilenames(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"10-db.yaml":          "",
+		"20-api.jsonnet":      "",
+		"30-jobs.toml":        "",
+		"40-misc.json":        "",
+		"50-web.yml":          "",
+		"lib.libsonnet":       "",
+		".hidden.json":        "",
+		"Procfile":            "",
+		"Procfile.dev":        "",
+		"docker-compose.yaml": "",
+		"README.md":           "",
+		"sub/60-nested.json":  "",
+	})
+
+	filenames, err := ConfigFilenames(dir)
+	test.NoError(t, err)
+	test.Eq(t, fun.Map[string](func(name string) string {
+		return filepath.Join(dir, name)
+	}, "10-db.yaml", "20-api.jsonnet", "30-jobs.toml", "40-misc.json", "50-web.yml"), filenames)
+
+	// file is config file itself, whatever its name
+	filename := filepath.Join(dir, "Procfile")
+	filenames, err = ConfigFilenames(filename)
+	test.NoError(t, err)
+	test.Eq(t, []string{filename}, filenames)
+
+	// directory might have no config files, but loading it is an error
+	empty := filepath.Join(dir, "empty")
+	test.NoError(t, os.Mkdir(empty, 0o755))
+	filenames, err = ConfigFilenames(empty)
+	test.NoError(t, err)
+	test.SliceEmpty(t, filenames)
+
+	_, err = LoadConfigs(empty)
+	test.EqError(t, err, fmt.Sprintf("no config files in dir %q", empty))
+
+	_, err = ConfigFilenames(filepath.Join(dir, "missing"))
+	test.ErrorIs(t, err, fs.ErrNotExist)
+}
+
+func TestLoadConfigsDir(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"pm.d/common.libsonnet": `{app(name): {name: name, command: "./" + name, cwd: "/srv"}}`,
+		"pm.d/10-db.yaml":       "- name: db\n  command: ./db\n",
+		"pm.d/20-api.jsonnet": `
+local common = import "common.libsonnet";
+local ports = import "ports.libsonnet";
+[common.app("api") + {args: ["--port", std.toString(ports.api)], depends_on: ["db", "cache"]}]
+`,
+		"pm.d/README.md":        "configs run on startup",
+		"lib/ports.libsonnet":   `{api: 8080}`,
+		"other/ports.libsonnet": `{api: 9090}`,
+	})
+
+	for name, tc := range map[string]struct {
+		jpath []string
+		want  string
+	}{
+		"jpath":                   {jpath: []string{filepath.Join(dir, "lib")}, want: "8080"},
+		"last jpath has priority": {jpath: []string{filepath.Join(dir, "lib"), filepath.Join(dir, "other")}, want: "9090"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			configs, err := LoadConfigs(filepath.Join(dir, "pm.d"), WithJPath(tc.jpath...), WithProcNames("cache"))
+			test.NoError(t, err)
+			test.Eq(t, []string{"db", "api"}, fun.Map[string](func(config RunConfig) string {
+				return config.Name
+			}, configs...))
+
+			// each app knows its own config file
+			test.EqOp(t, filepath.Join(dir, "pm.d", "10-db.yaml"), configs[0].ConfigFile)
+			test.EqOp(t, filepath.Join(dir, "pm.d", "20-api.jsonnet"), configs[1].ConfigFile)
+			test.EqOp(t, filepath.Join(dir, "pm.d"), configs[0].Cwd)
+			test.EqOp(t, "/srv", configs[1].Cwd)
+			test.Eq(t, []string{"--port", tc.want}, configs[1].Args)
+		})
+	}
+}
+
+func TestValidateConfigsDir(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"a.json": `[{"name": "api", "command": "./api"}, {"name": "web", "command": "./web", "depends_on": ["db"]}]`,
+		"b.yaml": "- name: worker\n  command: ./worker\n- name: api\n  command: ./api2\n",
+	})
+
+	errs, err := ValidateConfigs(dir, WithProcNames())
+	test.NoError(t, err)
+	test.Eq(t, []string{
+		filepath.Join(dir, "a.json") + `:1:75: apps[1] "web": depends on unknown process "db"`,
+		filepath.Join(dir, "b.yaml") + `:3:3: apps[1] "api": duplicate name "api", already used by apps[0] of ` + filepath.Join(dir, "a.json"),
+	}, fun.Map[string](func(err *ConfigError) string {
+		return err.Error()
+	}, errs...))
+}
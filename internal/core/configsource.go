@@ -0,0 +1,296 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/google/go-jsonnet"
+	"github.com/google/go-jsonnet/ast"
+	yaml3 "go.yaml.in/yaml/v3"
+)
+
+// sourcePos is position in config file, line and column start from 1, zero
+// line means position is unknown
+type sourcePos struct {
+	line, column int
+}
+
+// sourceObject is position of object in config file and of its keys
+type sourceObject struct {
+	pos  sourcePos
+	keys map[string]sourcePos
+}
+
+// configSource is positions of apps in config file, used to point errors to
+// lines of config file. Positions are found on best effort basis, e.g. apps
+// generated by jsonnet comprehensions have no known positions.
+type configSource struct {
+	root     sourceObject
+	apps     []sourceObject
+	defaults sourceObject
+}
+
+// lookup position of key of app, or of app itself if key position is unknown.
+// Negative entry refers to top level keys, "defaults.key" refers to key of
+// defaults.
+func (s configSource) lookup(entry int, key string) sourcePos {
+	if entry < 0 {
+		if defaultsKey, ok := strings.CutPrefix(key, "defaults."); ok {
+			if pos, ok := s.defaults.keys[defaultsKey]; ok {
+				return pos
+			}
+			return s.defaults.pos
+		}
+		return s.root.keys[key]
+	}
+
+	if entry >= len(s.apps) {
+		return sourcePos{}
+	}
+
+	app := s.apps[entry]
+	if pos, ok := app.keys[key]; ok {
+		return pos
+	}
+	// key might be inherited from defaults
+	if pos, ok := s.defaults.keys[key]; ok {
+		return pos
+	}
+	return app.pos
+}
+
+// locateConfigSource of config file in given format, unknown positions are
+// left unset
+func locateConfigSource(filename string, format ConfigFormat) configSource {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return configSource{}
+	}
+
+	switch format {
+	case ConfigFormatJsonnet, ConfigFormatJSON: // NOTE: json is valid jsonnet
+		return locateJsonnetSource(filename, data)
+	case ConfigFormatYAML:
+		return locateYAMLSource(data)
+	case ConfigFormatTOML:
+		return locateTOMLSource(data)
+	default:
+		return configSource{}
+	}
+}
+
+// configSourceFromObjects of apps list or object with apps and defaults
+func configSourceFromObjects[T any](
+	root T,
+	asList func(T) ([]T, bool),
+	asObject func(T) (sourceObject, map[string]T, bool),
+) configSource {
+	var res configSource
+	appsList := func(apps []T) {
+		for _, app := range apps {
+			obj, _, _ := asObject(app)
+			res.apps = append(res.apps, obj)
+		}
+	}
+
+	if apps, ok := asList(root); ok {
+		appsList(apps)
+		return res
+	}
+
+	obj, values, ok := asObject(root)
+	if !ok {
+		return res
+	}
+
+	res.root = obj
+	if apps, ok := values["apps"]; ok {
+		if list, ok := asList(apps); ok {
+			appsList(list)
+		}
+	}
+	if defaults, ok := values["defaults"]; ok {
+		res.defaults, _, _ = asObject(defaults)
+	}
+	return res
+}
+
+// jsonnetValue strips locals and parens around value
+func jsonnetValue(node ast.Node) ast.Node {
+	for {
+		switch n := node.(type) {
+		case *ast.Local:
+			node = n.Body
+		case *ast.Parens:
+			node = n.Inner
+		default:
+			return node
+		}
+	}
+}
+
+func jsonnetPos(loc ast.LocationRange) sourcePos {
+	return sourcePos{
+		line:   loc.Begin.Line,
+		column: loc.Begin.Column,
+	}
+}
+
+func locateJsonnetSource(filename string, data []byte) configSource {
+	root, err := jsonnet.SnippetToAST(filename, string(data))
+	if err != nil {
+		return configSource{}
+	}
+
+	return configSourceFromObjects(
+		root,
+		func(node ast.Node) ([]ast.Node, bool) {
+			array, ok := jsonnetValue(node).(*ast.Array)
+			if !ok {
+				return nil, false
+			}
+
+			elems := make([]ast.Node, len(array.Elements))
+			for i, elem := range array.Elements {
+				elems[i] = elem.Expr
+			}
+			return elems, true
+		},
+		func(node ast.Node) (sourceObject, map[string]ast.Node, bool) {
+			node = jsonnetValue(node)
+			res := sourceObject{
+				pos:  jsonnetPos(*node.Loc()),
+				keys: map[string]sourcePos{},
+			}
+
+			// app might be defined like base + {...}, then keys are taken from last object
+			if binary, ok := node.(*ast.Binary); ok && binary.Op == ast.BopPlus {
+				node = jsonnetValue(binary.Right)
+			}
+
+			obj, ok := node.(*ast.DesugaredObject)
+			if !ok {
+				return res, nil, false
+			}
+
+			values := map[string]ast.Node{}
+			for _, field := range obj.Fields {
+				name, ok := field.Name.(*ast.LiteralString)
+				if !ok {
+					continue
+				}
+
+				res.keys[name.Value] = jsonnetPos(field.LocRange)
+				values[name.Value] = field.Body
+			}
+			return res, values, true
+		},
+	)
+}
+
+func locateYAMLSource(data []byte) configSource {
+	var doc yaml3.Node
+	if err := yaml3.Unmarshal(data, &doc); err != nil || len(doc.Content) == 0 {
+		return configSource{}
+	}
+
+	yamlValue := func(node *yaml3.Node) *yaml3.Node {
+		for node.Kind == yaml3.AliasNode && node.Alias != nil {
+			node = node.Alias
+		}
+		return node
+	}
+
+	return configSourceFromObjects(
+		doc.Content[0],
+		func(node *yaml3.Node) ([]*yaml3.Node, bool) {
+			node = yamlValue(node)
+			return node.Content, node.Kind == yaml3.SequenceNode
+		},
+		func(node *yaml3.Node) (sourceObject, map[string]*yaml3.Node, bool) {
+			res := sourceObject{
+				pos: sourcePos{
+					line:   node.Line,
+					column: node.Column,
+				},
+				keys: map[string]sourcePos{},
+			}
+
+			node = yamlValue(node)
+			if node.Kind != yaml3.MappingNode {
+				return res, nil, false
+			}
+
+			values := map[string]*yaml3.Node{}
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				key := node.Content[i]
+				res.keys[key.Value] = sourcePos{
+					line:   key.Line,
+					column: key.Column,
+				}
+				values[key.Value] = node.Content[i+1]
+			}
+			return res, values, true
+		},
+	)
+}
+
+var (
+	_tomlTableRegex = regexp.MustCompile(`^(\s*)\[\s*([\w-]+)(?:\s*\.\s*([\w-]+))?\s*\]`)
+	_tomlAppsRegex  = regexp.MustCompile(`^(\s*)\[\[\s*apps\s*\]\]`)
+	_tomlKeyRegex   = regexp.MustCompile(`^(\s*)(?:"([\w-]+)"|([\w-]+))\s*[=.]`)
+)
+
+// locateTOMLSource by scanning table headers and keys line by line, since
+// toml decoder does not provide positions
+func locateTOMLSource(data []byte) configSource {
+	res := configSource{
+		root:     sourceObject{pos: sourcePos{}, keys: map[string]sourcePos{}},
+		apps:     nil,
+		defaults: sourceObject{pos: sourcePos{}, keys: map[string]sourcePos{}},
+	}
+
+	current := &res.root // object, keys of which are being read
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if m := _tomlAppsRegex.FindStringSubmatch(text); m != nil {
+			res.apps = append(res.apps, sourceObject{
+				pos:  sourcePos{line: line, column: len(m[1]) + 1},
+				keys: map[string]sourcePos{},
+			})
+			current = &res.apps[len(res.apps)-1]
+			continue
+		}
+
+		if m := _tomlTableRegex.FindStringSubmatch(text); m != nil {
+			pos := sourcePos{line: line, column: len(m[1]) + 1}
+			table, subtable := m[2], m[3]
+			switch {
+			case table == "defaults" && subtable == "":
+				res.defaults.pos = pos
+				current = &res.defaults
+				continue
+			case table == "defaults":
+				res.defaults.keys[subtable] = pos
+			case table == "apps" && subtable != "" && len(res.apps) > 0:
+				res.apps[len(res.apps)-1].keys[subtable] = pos
+			default:
+				res.root.keys[table] = pos
+			}
+			current = nil // keys of subtables are not tracked
+			continue
+		}
+
+		if m := _tomlKeyRegex.FindStringSubmatch(text); m != nil && current != nil {
+			key := m[2] + m[3] // either quoted or bare key
+			if _, ok := current.keys[key]; !ok {
+				current.keys[key] = sourcePos{line: line, column: len(m[1]) + 1}
+			}
+		}
+	}
+	return res
+}
@@ -0,0 +1,266 @@
+package core
+
+import (
+	"bufio"
+	"encoding/json"
+	stdErrors "errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/adhocore/gronx"
+	"github.com/rprtr258/fun"
+
+	"github.com/rprtr258/pm/internal/errors"
+)
+
+// CronConcurrency is what to do when run is scheduled while previous one is
+// still running
+type CronConcurrency string
+
+const (
+	CronConcurrencyForbid  CronConcurrency = "forbid"  // skip new run
+	CronConcurrencyAllow   CronConcurrency = "allow"   // start new run alongside
+	CronConcurrencyReplace CronConcurrency = "replace" // stop running one, then start new run
+)
+
+var CronConcurrencies = []CronConcurrency{CronConcurrencyForbid, CronConcurrencyAllow, CronConcurrencyReplace}
+
+// Cron describes schedule of job, which is run to completion on each tick.
+// Schedule is either cron expression, interval or single time.
+type Cron struct {
+	Schedule    string          // Schedule - cron expression, empty if Every or At is set
+	Every       time.Duration   // Every - interval between runs
+	At          time.Time       // At - time of single run
+	AtDelay     time.Duration   // AtDelay - if at is set as duration, At is time config was first run plus it
+	Timezone    string          // Timezone - IANA timezone name schedule is in, local if empty
+	Concurrency CronConcurrency // Concurrency - policy for overlapping runs
+	CatchUp     bool            // CatchUp - run once on start if runs were missed while job was not scheduled
+	Timeout     time.Duration   // Timeout - kill run after this long, no limit if zero
+	RunOnStart  bool            // RunOnStart - run immediately when started, not waiting for first tick
+}
+
+// Equal reports whether configs are the same. Single run times set as equal
+// durations are the same, since At depends on when config was loaded.
+func (c Cron) Equal(other Cron) bool {
+	atEqual := c.At.Equal(other.At) || c.AtDelay != 0 && c.AtDelay == other.AtDelay
+	c.At, other.At = time.Time{}, time.Time{}
+	return atEqual && c == other
+}
+
+// Spec is schedule part of config, next run time depends only on it
+func (c Cron) Spec() string {
+	switch {
+	case c.Every > 0:
+		return "every " + c.Every.String()
+	case !c.At.IsZero():
+		return "at " + c.At.Format(time.RFC3339)
+	default:
+		return c.Schedule
+	}
+}
+
+func (c Cron) String() string {
+	var b strings.Builder
+	b.WriteString(c.Spec())
+	if c.Timezone != "" {
+		fmt.Fprintf(&b, " timezone=%s", c.Timezone)
+	}
+	fmt.Fprintf(&b, " concurrency=%s", c.Concurrency)
+	if c.CatchUp {
+		b.WriteString(" catch_up")
+	}
+	if c.Timeout > 0 {
+		fmt.Fprintf(&b, " timeout=%s", c.Timeout)
+	}
+	if c.RunOnStart {
+		b.WriteString(" run_on_start")
+	}
+	return b.String()
+}
+
+// CronSchedule is cron config for legacy cron expression: process is run on
+// start and then on each tick after previous run exits
+func CronSchedule(schedule string) Cron {
+	return Cron{
+		Schedule:    schedule,
+		Every:       0,
+		At:          time.Time{},
+		AtDelay:     0,
+		Timezone:    "",
+		Concurrency: CronConcurrencyForbid,
+		CatchUp:     false,
+		Timeout:     0,
+		RunOnStart:  true,
+	}
+}
+
+// Location of schedule timezone
+func (c Cron) Location() (*time.Location, error) {
+	if c.Timezone == "" {
+		return time.Local, nil
+	}
+
+	loc, err := time.LoadLocation(c.Timezone)
+	return loc, errors.Wrapf(err, "load timezone %q", c.Timezone)
+}
+
+// Next tick of schedule strictly after given time, zero time if there are
+// no more ticks
+func (c Cron) Next(after time.Time) (time.Time, error) {
+	switch {
+	case c.Every > 0:
+		return after.Add(c.Every), nil
+	case !c.At.IsZero():
+		if c.At.After(after) {
+			return c.At, nil
+		}
+		return time.Time{}, nil
+	}
+
+	loc, err := c.Location()
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	// NOTE: gronx matches schedule against fields of time in its location
+	next, err := gronx.NextTickAfter(c.Schedule, after.In(loc), false)
+	return next, errors.Wrapf(err, "next tick of %q", c.Schedule)
+}
+
+// Validate schedule and timezone
+func (c Cron) Validate() error {
+	switch {
+	case c.Every < 0:
+		return errors.Newf("every must be positive, got %s", c.Every)
+	case c.Every > 0 || !c.At.IsZero():
+	case !gronx.IsValid(c.Schedule):
+		return errors.Newf("invalid cron expression: %q", c.Schedule)
+	}
+
+	_, err := c.Location()
+	return err
+}
+
+// LogRange is byte offsets of run output in log file. If log file was
+// rotated during run, output starts in File, which log file was moved to, and
+// ends in current log file.
+type LogRange struct {
+	File  string `json:"file,omitempty"`
+	Start int64  `json:"start"`
+	End   int64  `json:"end"`
+}
+
+// CronRun is record of single job run in history
+type CronRun struct {
+	ScheduledAt time.Time       `json:"scheduled_at"`
+	StartedAt   time.Time       `json:"started_at,omitzero"`
+	FinishedAt  time.Time       `json:"finished_at,omitzero"`
+	PID         int             `json:"pid,omitempty"`
+	ExitCode    fun.Option[int] `json:"exit_code"`
+	Signal      string          `json:"signal,omitempty"`
+	TimedOut    bool            `json:"timed_out,omitempty"`
+	Skipped     bool            `json:"skipped,omitempty"` // not started because previous run was still running
+	Stdout      LogRange        `json:"stdout,omitzero"`
+	Stderr      LogRange        `json:"stderr,omitzero"`
+}
+
+// CronHistoryFile is file with history of job runs
+func CronHistoryFile(dirLogs string, id PMID) string {
+	return filepath.Join(dirLogs, id.String()+".runs.jsonl")
+}
+
+// ReadCronHistory of job runs, oldest first. Missing file means no runs.
+func ReadCronHistory(filename string) ([]CronRun, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		if stdErrors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "open %s", filename)
+	}
+	defer f.Close()
+
+	runs := []CronRun{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var run CronRun
+		if err := json.Unmarshal(scanner.Bytes(), &run); err != nil {
+			// skip corrupted line, e.g. partially written one
+			continue
+		}
+		runs = append(runs, run)
+	}
+	return runs, errors.Wrapf(scanner.Err(), "read %s", filename)
+}
+
+// CronState is persisted state of job schedule
+type CronState struct {
+	Spec string    `json:"spec"` // schedule state belongs to, see Cron.Spec
+	Next time.Time `json:"next"` // time of next run
+}
+
+// CronStateFile is file with persisted time of next job run
+func CronStateFile(dirLogs string, id PMID) string {
+	return filepath.Join(dirLogs, id.String()+".schedule.json")
+}
+
+// ReadCronState of job schedule, next run is invalid if there is no state or
+// it belongs to other schedule
+func ReadCronState(filename string, cron Cron) (fun.Option[time.Time], error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		if stdErrors.Is(err, fs.ErrNotExist) {
+			return fun.Invalid[time.Time](), nil
+		}
+		return fun.Invalid[time.Time](), errors.Wrapf(err, "read %s", filename)
+	}
+
+	var state CronState
+	if err := json.Unmarshal(b, &state); err != nil {
+		return fun.Invalid[time.Time](), errors.Wrapf(err, "unmarshal %s", filename)
+	}
+
+	if state.Spec != cron.Spec() {
+		return fun.Invalid[time.Time](), nil
+	}
+	return fun.Valid(state.Next), nil
+}
+
+// WriteCronState persists time of next job run
+func WriteCronState(filename string, cron Cron, next time.Time) error {
+	b, err := json.Marshal(CronState{
+		Spec: cron.Spec(),
+		Next: next,
+	})
+	if err != nil {
+		return errors.Wrapf(err, "marshal state")
+	}
+
+	// NOTE: write to temporary file and rename, so that state is never partially written
+	tmp := filename + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return errors.Wrapf(err, "write %s", tmp)
+	}
+	return errors.Wrapf(os.Rename(tmp, filename), "rename %s", tmp)
+}
+
+// AppendCronHistory writes run record to the end of history file
+func AppendCronHistory(filename string, run CronRun) error {
+	b, err := json.Marshal(run)
+	if err != nil {
+		return errors.Wrapf(err, "marshal run")
+	}
+
+	f, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return errors.Wrapf(err, "open %s", filename)
+	}
+	defer f.Close()
+
+	_, err = f.Write(append(b, '\n'))
+	return errors.Wrapf(err, "write %s", filename)
+}
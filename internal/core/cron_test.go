@@ -0,0 +1,141 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shoenig/test"
+)
+
+func TestCronNext(t *testing.T) {
+	t.Parallel()
+
+	after := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	every := CronSchedule("")
+	every.Every = 90 * time.Second
+
+	at := CronSchedule("")
+	at.At = after.Add(time.Hour)
+
+	daily := CronSchedule("0 9 * * *")
+	daily.Timezone = "UTC"
+
+	dailyNewYork := CronSchedule("0 9 * * *")
+	dailyNewYork.Timezone = "America/New_York"
+
+	for name, tc := range map[string]struct {
+		cron  Cron
+		after time.Time
+		want  time.Time
+	}{
+		"every": {
+			cron:  every,
+			after: after,
+			want:  after.Add(90 * time.Second),
+		},
+		"at": {
+			cron:  at,
+			after: after,
+			want:  after.Add(time.Hour),
+		},
+		"at passed": {
+			cron:  at,
+			after: after.Add(time.Hour),
+			want:  time.Time{},
+		},
+		"cron": {
+			cron:  daily,
+			after: after,
+			want:  time.Date(2030, 1, 2, 9, 0, 0, 0, time.UTC),
+		},
+		"cron strictly after": {
+			cron:  daily,
+			after: time.Date(2030, 1, 2, 9, 0, 0, 0, time.UTC),
+			want:  time.Date(2030, 1, 3, 9, 0, 0, 0, time.UTC),
+		},
+		"cron in timezone": {
+			cron:  dailyNewYork,
+			after: after,
+			want:  time.Date(2030, 1, 2, 14, 0, 0, 0, time.UTC), // 9:00 EST
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := tc.cron.Next(tc.after)
+			test.NoError(t, err)
+			test.True(t, tc.want.Equal(got), test.Sprintf("want %s, got %s", tc.want, got))
+		})
+	}
+
+	invalid := CronSchedule("0 9 * * *")
+	invalid.Timezone = "Mars/Olympus"
+	_, err := invalid.Next(after)
+	test.EqError(t, err, `load timezone "Mars/Olympus": unknown time zone Mars/Olympus`)
+}
+
+func TestCronEqual(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	cron := func(f func(*Cron)) Cron {
+		res := CronSchedule("")
+		f(&res)
+		return res
+	}
+	atDelay := func(loadedAt time.Time) Cron {
+		return cron(func(c *Cron) {
+			c.At = loadedAt.Add(time.Hour)
+			c.AtDelay = time.Hour
+		})
+	}
+
+	for name, tc := range map[string]struct {
+		a, b Cron
+		want bool
+	}{
+		"same schedule": {
+			a:    CronSchedule("* * * * *"),
+			b:    CronSchedule("* * * * *"),
+			want: true,
+		},
+		"different schedule": {
+			a:    CronSchedule("* * * * *"),
+			b:    CronSchedule("0 * * * *"),
+			want: false,
+		},
+		"different policy": {
+			a:    CronSchedule("* * * * *"),
+			b:    cron(func(c *Cron) { c.Schedule = "* * * * *"; c.Concurrency = CronConcurrencyAllow }),
+			want: false,
+		},
+		"same at in other location": {
+			a:    cron(func(c *Cron) { c.At = now }),
+			b:    cron(func(c *Cron) { c.At = now.UTC() }),
+			want: true,
+		},
+		"different at": {
+			a:    cron(func(c *Cron) { c.At = now }),
+			b:    cron(func(c *Cron) { c.At = now.Add(time.Second) }),
+			want: false,
+		},
+		"same delay loaded at different times": {
+			a:    atDelay(now),
+			b:    atDelay(now.Add(time.Minute)),
+			want: true,
+		},
+		"delay and time": {
+			a:    atDelay(now),
+			b:    cron(func(c *Cron) { c.At = now.Add(2 * time.Hour) }),
+			want: false,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			test.EqOp(t, tc.want, tc.a.Equal(tc.b))
+			test.EqOp(t, tc.want, tc.b.Equal(tc.a))
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package core
+
+import (
+	"time"
+
+	"github.com/rprtr258/fun"
+)
+
+// EventType is kind of process lifecycle transition
+type EventType string
+
+const (
+	EventCreated     EventType = "created"      // process added to pm
+	EventStarted     EventType = "started"      // child process started by shim
+	EventExited      EventType = "exited"       // child process exited by itself
+	EventRestarted   EventType = "restarted"    // child process is going to be restarted, see Event.Reason
+	EventOOM         EventType = "oom"          // child process was killed by OOM killer
+	EventStopped     EventType = "stopped"      // child process was stopped by pm
+	EventReloaded    EventType = "reloaded"     // child process was reloaded without downtime, see Event.Reason
+	EventBuildFailed EventType = "build_failed" // build command failed, running child is kept
+	EventGaveUp      EventType = "gave_up"      // child process keeps failing, no restarts left
+	EventDeleted     EventType = "deleted"      // process removed from pm
+)
+
+var EventTypes = []EventType{
+	EventCreated,
+	EventStarted,
+	EventExited,
+	EventRestarted,
+	EventOOM,
+	EventStopped,
+	EventReloaded,
+	EventBuildFailed,
+	EventGaveUp,
+	EventDeleted,
+}
+
+// Failed reports whether event is about failure of child process
+func (e Event) Failed() bool {
+	switch e.Type { //nolint:exhaustive // other events are not failures
+	case EventOOM, EventGaveUp, EventBuildFailed:
+		return true
+	case EventExited:
+		return e.ExitCode != fun.Valid(0)
+	default:
+		return false
+	}
+}
+
+// Restart reasons
+const (
+	ReasonWatch       = "watch"
+	ReasonCron        = "cron"
+	ReasonAutorestart = "autorestart"
+	ReasonReload      = "reload"
+	ReasonConnection  = "connection" // first connection to lazy process
+	ReasonIdle        = "idle"       // no connections for idle timeout
+	ReasonCatchUp     = "catch_up"   // job run missed while job was not scheduled
+	ReasonTimeout     = "timeout"    // job run was killed after timeout
+	ReasonManual      = "manual"     // job run was requested by user
+	ReasonFinished    = "finished"   // job has no more runs scheduled
+)
+
+// Event is a single record in events log
+type Event struct {
+	Time     time.Time       `json:"time"`
+	Type     EventType       `json:"type"`
+	ProcID   PMID            `json:"proc_id"`
+	ProcName string          `json:"proc_name"`
+	PID      fun.Option[int] `json:"pid"`       // child pid, if there is child
+	ExitCode fun.Option[int] `json:"exit_code"` // exit code, for exited child
+	Signal   string          `json:"signal,omitempty"`
+	Reason   string          `json:"reason,omitempty"` // restart reason
+}
+
+func NewEvent(typ EventType, id PMID, name string) Event {
+	return Event{
+		Time:     time.Now(),
+		Type:     typ,
+		ProcID:   id,
+		ProcName: name,
+		PID:      fun.Invalid[int](),
+		ExitCode: fun.Invalid[int](),
+		Signal:   "",
+		Reason:   "",
+	}
+}
@@ -0,0 +1,394 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/google/go-jsonnet/formatter"
+	"github.com/rprtr258/fun"
+	"github.com/rs/zerolog/log"
+	"sigs.k8s.io/yaml"
+
+	"github.com/rprtr258/pm/internal/errors"
+)
+
+// ConfigFormat is format of config file
+type ConfigFormat string
+
+const (
+	ConfigFormatJsonnet  ConfigFormat = "jsonnet"
+	ConfigFormatJSON     ConfigFormat = "json"
+	ConfigFormatYAML     ConfigFormat = "yaml"
+	ConfigFormatTOML     ConfigFormat = "toml"
+	ConfigFormatProcfile ConfigFormat = "procfile"
+	ConfigFormatCompose  ConfigFormat = "compose" // docker compose file
+)
+
+var ConfigFormats = []ConfigFormat{
+	ConfigFormatJsonnet,
+	ConfigFormatJSON,
+	ConfigFormatYAML,
+	ConfigFormatTOML,
+	ConfigFormatProcfile,
+	ConfigFormatCompose,
+}
+
+// DetectConfigFormat by file name, invalid if it is unknown
+func DetectConfigFormat(filename string) fun.Option[ConfigFormat] {
+	base := filepath.Base(filename)
+	ext := filepath.Ext(base)
+	switch {
+	case strings.HasPrefix(base, "Procfile"):
+		return fun.Valid(ConfigFormatProcfile)
+	case strings.Contains(base, "compose") && (ext == ".yml" || ext == ".yaml"):
+		return fun.Valid(ConfigFormatCompose)
+	case ext == ".yml" || ext == ".yaml":
+		return fun.Valid(ConfigFormatYAML)
+	case ext == ".toml":
+		return fun.Valid(ConfigFormatTOML)
+	case ext == ".json":
+		return fun.Valid(ConfigFormatJSON)
+	case ext == ".jsonnet" || ext == ".libsonnet":
+		return fun.Valid(ConfigFormatJsonnet)
+	default:
+		return fun.Invalid[ConfigFormat]()
+	}
+}
+
+// relPath of path to dir, path is kept absolute if it is outside of dir
+func relPath(dir, path string) string {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil || !filepath.IsLocal(rel) && rel != "." {
+		return path
+	}
+	return rel
+}
+
+func formatDuration(d time.Duration) *string {
+	if d == 0 {
+		return nil
+	}
+	return fun.Ptr(d.String())
+}
+
+func hookToConfig(hook fun.Option[Hook]) *hookScanDTO {
+	return fun.OptMap(hook, func(hook Hook) hookScanDTO {
+		return hookScanDTO{
+			Command:   hook.Command,
+			Args:      hook.Args,
+			OnFailure: string(hook.OnFailure),
+			Timeout:   fun.Deref(formatDuration(hook.Timeout)),
+		}
+	}).Ptr()
+}
+
+func watchToConfig(watch Watch, cwd string) *watchScanDTO {
+	return &watchScanDTO{
+		Paths:     fun.Map[string](func(path string) string { return relPath(cwd, path) }, watch.Paths...),
+		Include:   watch.Include,
+		Exclude:   watch.Exclude,
+		Gitignore: &watch.Gitignore,
+		Debounce:  watch.Debounce.String(),
+		regex:     nil,
+	}
+}
+
+func cronToConfig(cron Cron) *cronScanDTO {
+	schedule := scheduleScanDTO{
+		Every: "",
+		At:    "",
+		expr:  cron.Schedule,
+	}
+	switch {
+	case cron.Every > 0:
+		schedule.Every = cron.Every.String()
+	case !cron.At.IsZero():
+		schedule.At = cron.At.Format(time.RFC3339)
+	}
+
+	return &cronScanDTO{
+		Schedule:    schedule,
+		Timezone:    cron.Timezone,
+		Concurrency: string(cron.Concurrency),
+		CatchUp:     cron.CatchUp,
+		Timeout:     fun.Deref(formatDuration(cron.Timeout)),
+		RunOnStart:  cron.RunOnStart,
+		expr:        nil,
+	}
+}
+
+// procToConfig maps process back to config entry, cwd is made relative to
+// dir. Fields which are not part of config, like ids and log files, are
+// dropped.
+func procToConfig(proc Proc, dir string, instances uint) configScanDTO {
+	name := proc.Name
+	if proc.Group != "" {
+		name = proc.Group
+	}
+
+	var watchMode, watchInterval *string
+	if watch, ok := proc.Watch.Unpack(); ok {
+		if watch.Mode != WatchModeAuto {
+			watchMode = fun.Ptr(string(watch.Mode))
+		}
+		if watch.PollInterval != time.Second {
+			watchInterval = fun.Ptr(watch.PollInterval.String())
+		}
+	}
+
+	var cwd *string
+	if rel := relPath(dir, proc.Cwd); rel != "." {
+		cwd = &rel
+	}
+
+	return configScanDTO{
+		Name:          &name,
+		Cwd:           cwd,
+		Env:           proc.Env,
+		Command:       proc.Command,
+		Args:          fun.Map[any](func(arg string) any { return arg }, proc.Args...),
+		Tags:          slices.DeleteFunc(slices.Clone(proc.Tags), func(tag string) bool { return tag == "all" }),
+		Watch:         fun.OptMap(proc.Watch, func(watch Watch) *watchScanDTO { return watchToConfig(watch, proc.Cwd) }).OrDefault(nil),
+		WatchMode:     watchMode,
+		WatchInterval: watchInterval,
+		Startup:       proc.Startup,
+		DependsOn:     proc.DependsOn,
+		Cron:          fun.OptMap(proc.Cron, cronToConfig).OrDefault(nil),
+		StartDelay:    formatDuration(proc.StartDelay),
+		Hooks: hooksScanDTO{
+			PreStart:  hookToConfig(proc.Hooks.PreStart),
+			PostStart: hookToConfig(proc.Hooks.PostStart),
+			PreStop:   hookToConfig(proc.Hooks.PreStop),
+			PostExit:  hookToConfig(proc.Hooks.PostExit),
+			OnCrash:   hookToConfig(proc.Hooks.OnCrash),
+		},
+		Notify: fun.OptMap(proc.Notify, func(notify Notify) notifyScanDTO {
+			return notifyScanDTO{
+				Webhook:   notify.Webhook.Ptr(),
+				Command:   notify.Command,
+				Socket:    notify.Socket.Ptr(),
+				RateLimit: fun.Deref(formatDuration(notify.RateLimit)),
+			}
+		}).Ptr(),
+
+		Build: hookToConfig(proc.Build),
+
+		StopSignal:  fun.OptMap(proc.StopSignal, SignalName).Ptr(),
+		StopCommand: hookToConfig(proc.StopCommand),
+		StopSequence: fun.Map[[]string](func(step StopStep) []string {
+			if step.Timeout == 0 {
+				return []string{SignalName(step.Signal)}
+			}
+			return []string{SignalName(step.Signal), step.Timeout.String()}
+		}, proc.StopSequence...),
+
+		Listen: proc.Listen,
+		Reload: fun.OptMap(proc.Reload, func(reload Reload) reloadScanDTO {
+			return reloadScanDTO{
+				Strategy:     string(reload.Strategy),
+				Signal:       SignalName(reload.Signal),
+				Notify:       reload.Notify,
+				ReadyDelay:   reload.ReadyDelay.String(),
+				ReadyTimeout: reload.ReadyTimeout.String(),
+			}
+		}).Ptr(),
+
+		Lazy:        proc.Lazy,
+		IdleTimeout: formatDuration(proc.IdleTimeout),
+
+		Instances: instances,
+		Ports:     fun.Map[string](func(port Port) string { return port.Name }, proc.Ports...),
+	}
+}
+
+// procsToConfigs maps processes to config entries, replicas are collapsed
+// into single entry of their group
+func procsToConfigs(procs []Proc, dir string) []configScanDTO {
+	procs = slices.Clone(procs)
+	slices.SortFunc(procs, func(a, b Proc) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	instances := map[string]uint{}
+	groupByReplica := map[string]string{}
+	for _, proc := range procs {
+		if proc.Group != "" {
+			instances[proc.Group]++
+			groupByReplica[proc.Name] = proc.Group
+		}
+	}
+
+	configs := []configScanDTO{}
+	for _, proc := range procs {
+		// dependencies on replicas are dependencies on their group in config
+		proc.DependsOn = fun.Uniq(fun.Map[string](func(name string) string {
+			if group, ok := groupByReplica[name]; ok {
+				return group
+			}
+			return name
+		}, proc.DependsOn...)...)
+
+		if proc.Group != "" {
+			n, ok := instances[proc.Group]
+			if !ok {
+				continue // group is already exported
+			}
+			delete(instances, proc.Group)
+
+			configs = append(configs, procToConfig(proc, dir, n))
+			continue
+		}
+
+		configs = append(configs, procToConfig(proc, dir, 0))
+	}
+	return configs
+}
+
+// composeServiceDTO is service in docker compose file, only fields which
+// make sense for local processes are supported
+type composeServiceDTO struct {
+	Command     any    `json:"command,omitempty"`    // string or list of strings
+	Entrypoint  any    `json:"entrypoint,omitempty"` // string or list of strings
+	WorkingDir  string `json:"working_dir,omitempty"`
+	Environment any    `json:"environment,omitempty"` // map or list of KEY=VALUE
+	DependsOn   any    `json:"depends_on,omitempty"`  // list or map of service names
+}
+
+type composeDTO struct {
+	Services map[string]composeServiceDTO `json:"services"`
+}
+
+// droppedFields of config entry which are not in kept fields, to warn user
+// that they are lost in export
+func droppedFields(config configScanDTO, kept ...string) []string {
+	b, _ := json.Marshal(config)
+	var fields map[string]any
+	_ = json.Unmarshal(b, &fields)
+
+	dropped := []string{}
+	for field := range fields {
+		if !fun.Contains(field, kept...) {
+			dropped = append(dropped, field)
+		}
+	}
+	slices.Sort(dropped)
+	return dropped
+}
+
+func warnDropped(config configScanDTO, format ConfigFormat, kept ...string) {
+	if dropped := droppedFields(config, kept...); len(dropped) > 0 {
+		log.Warn().
+			Str("name", fun.Deref(config.Name)).
+			Strs("fields", dropped).
+			Msgf("fields are not supported by %s format, dropping them", format)
+	}
+}
+
+// ExportConfigs of processes in given format. Paths are made relative to
+// dir, where config is going to be written. Compose files are loaded by
+// ImportConfigs, other formats by LoadConfigs.
+func ExportConfigs(procs []Proc, dir string, format ConfigFormat) ([]byte, error) {
+	configs := procsToConfigs(procs, dir)
+
+	switch format {
+	case ConfigFormatJSON:
+		b, err := json.MarshalIndent(configs, "", "  ")
+		if err != nil {
+			return nil, errors.Wrapf(err, "marshal configs")
+		}
+		return append(b, '\n'), nil
+	case ConfigFormatJsonnet:
+		// NOTE: formatter keeps line breaks of input
+		b, err := json.MarshalIndent(configs, "", "  ")
+		if err != nil {
+			return nil, errors.Wrapf(err, "marshal configs")
+		}
+
+		res, err := formatter.Format("config.jsonnet", string(b), formatter.DefaultOptions())
+		if err != nil {
+			return nil, errors.Wrapf(err, "format jsonnet")
+		}
+		return []byte(res), nil
+	case ConfigFormatYAML:
+		b, err := json.Marshal(configs)
+		if err != nil {
+			return nil, errors.Wrapf(err, "marshal configs")
+		}
+
+		res, err := yaml.JSONToYAML(b)
+		return res, errors.Wrapf(err, "convert configs to yaml")
+	case ConfigFormatTOML:
+		// NOTE: toml document is table, so apps are in object form
+		b, err := json.Marshal(map[string]any{"apps": configs})
+		if err != nil {
+			return nil, errors.Wrapf(err, "marshal configs")
+		}
+
+		var v map[string]any
+		if err := json.Unmarshal(b, &v); err != nil {
+			return nil, errors.Wrapf(err, "unmarshal configs")
+		}
+
+		var buf bytes.Buffer
+		if err := toml.NewEncoder(&buf).Encode(v); err != nil {
+			return nil, errors.Wrapf(err, "encode toml")
+		}
+		return buf.Bytes(), nil
+	case ConfigFormatProcfile:
+		var buf bytes.Buffer
+		for _, config := range configs {
+			warnDropped(config, format, "name", "command", "args", "cwd")
+			if config.Cwd != nil {
+				log.Warn().
+					Str("name", fun.Deref(config.Name)).
+					Str("cwd", fun.Deref(config.Cwd)).
+					Msg("procfile processes are run in its directory, dropping cwd")
+			}
+
+			argv := append([]string{config.Command}, fun.Map[string](func(arg any) string { return fmt.Sprint(arg) }, config.Args...)...)
+			command := procfileCommand(argv).OrDefault(ShellJoin(argv...))
+			fmt.Fprintf(&buf, "%s: %s\n", fun.Deref(config.Name), command)
+		}
+		return buf.Bytes(), nil
+	case ConfigFormatCompose:
+		compose := composeDTO{
+			Services: make(map[string]composeServiceDTO, len(configs)),
+		}
+		for _, config := range configs {
+			warnDropped(config, format, "name", "command", "args", "cwd", "env", "depends_on")
+
+			args := fun.Map[string](func(arg any) string { return fmt.Sprint(arg) }, config.Args...)
+			service := composeServiceDTO{
+				Command:     append([]string{config.Command}, args...),
+				Entrypoint:  nil,
+				WorkingDir:  fun.Deref(config.Cwd),
+				Environment: nil,
+				DependsOn:   nil,
+			}
+			// NOTE: nil map or slice in interface field is not omitted
+			if len(config.Env) > 0 {
+				service.Environment = config.Env
+			}
+			if len(config.DependsOn) > 0 {
+				service.DependsOn = config.DependsOn
+			}
+			compose.Services[fun.Deref(config.Name)] = service
+		}
+
+		b, err := json.Marshal(compose)
+		if err != nil {
+			return nil, errors.Wrapf(err, "marshal compose")
+		}
+
+		res, err := yaml.JSONToYAML(b)
+		return res, errors.Wrapf(err, "convert compose to yaml")
+	default:
+		return nil, errors.Newf("unknown format %q, expected one of %q", format, ConfigFormats)
+	}
+}
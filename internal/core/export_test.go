@@ -0,0 +1,177 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/rprtr258/fun"
+	"github.com/shoenig/test"
+)
+
+func TestExportConfigsRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	hook := func(command string, onFailure HookFailure) Hook {
+		return Hook{
+			Command:   "/bin/sh",
+			Args:      []string{"-c", command},
+			OnFailure: onFailure,
+			Timeout:   time.Minute,
+		}
+	}
+	every := CronSchedule("")
+	every.Every = time.Hour
+	every.Timezone = "UTC"
+	every.Concurrency = CronConcurrencyReplace
+
+	// config which is expected to be loaded back, without config file
+	api := newRunConfig("api", filepath.Join(dir, "api"), []string{"./api", "--port", "8080"}, map[string]string{"LOG_LEVEL": "debug"}, nil)
+	api.Tags = []string{"web"}
+	api.Watch = fun.Valid(Watch{
+		Paths:        []string{filepath.Join(dir, "api", "src")},
+		Include:      []string{"*.go"},
+		Exclude:      []string{"vendor/"},
+		Gitignore:    false,
+		Debounce:     2 * time.Second,
+		Mode:         WatchModePoll,
+		PollInterval: 5 * time.Second,
+	})
+	api.Build = fun.Valid(hook("go build", HookFailureAbort))
+	api.Startup = true
+	api.StartDelay = time.Second
+	api.Hooks.PreStart = fun.Valid(hook("migrate", HookFailureAbort))
+	api.Hooks.OnCrash = fun.Valid(hook("report", HookFailureIgnore))
+	api.Notify = fun.Valid(Notify{
+		Webhook:   fun.Valid("http://localhost/hook"),
+		Command:   nil,
+		Socket:    fun.Invalid[string](),
+		RateLimit: time.Minute,
+	})
+	api.StopSignal = fun.Valid(syscall.SIGINT)
+	api.StopCommand = fun.Valid(hook("drain", HookFailureIgnore))
+	api.StopSequence = []StopStep{
+		{Signal: syscall.SIGINT, Timeout: 5 * time.Second},
+		{Signal: syscall.SIGKILL, Timeout: 0},
+	}
+	api.Listen = []string{":8080"}
+	api.Reload = fun.Valid(Reload{
+		Strategy:     ReloadBlueGreen,
+		Signal:       syscall.SIGHUP,
+		Notify:       true,
+		ReadyDelay:   time.Second,
+		ReadyTimeout: 10 * time.Second,
+	})
+	api.Lazy = true
+	api.IdleTimeout = time.Hour
+	api.Ports = []string{"http", "metrics"}
+
+	backup := newRunConfig("backup", "/var/backups", []string{"./backup.sh"}, nil, nil)
+	backup.Cron = fun.Valid(every)
+
+	worker := newRunConfig("worker", dir, []string{"./worker"}, nil, []string{"api"})
+	worker.Instances = 2
+
+	proxy := newRunConfig("proxy", dir, []string{"./proxy"}, nil, []string{"worker", "api"})
+
+	// processes run from these configs
+	toProc := func(config RunConfig) Proc {
+		var proc Proc
+		proc.ID = GenPMID()
+		proc.Name = config.Name
+		proc.Group = config.Group
+		proc.Instance = config.Instance
+		proc.Command = config.Command
+		proc.Args = config.Args
+		proc.Tags = append([]string{"all"}, config.Tags...)
+		proc.Cwd = config.Cwd
+		proc.Env = config.Env
+		proc.StdoutFile = filepath.Join("/logs", proc.ID.String()+".stdout")
+		proc.StderrFile = filepath.Join("/logs", proc.ID.String()+".stderr")
+		proc.Watch = config.Watch
+		proc.Build = config.Build
+		proc.Startup = config.Startup
+		proc.KillTimeout = 5 * time.Second
+		proc.DependsOn = config.DependsOn
+		proc.Cron = config.Cron
+		proc.StartDelay = config.StartDelay
+		proc.Hooks = config.Hooks
+		proc.Notify = config.Notify
+		proc.StopSignal = config.StopSignal
+		proc.StopCommand = config.StopCommand
+		proc.StopSequence = config.StopSequence
+		proc.Listen = config.Listen
+		proc.Reload = config.Reload
+		proc.Lazy = config.Lazy
+		proc.IdleTimeout = config.IdleTimeout
+		for i, name := range config.Ports {
+			proc.Ports = append(proc.Ports, Port{Name: name, Port: 20000 + i})
+		}
+		return proc
+	}
+	procs := fun.Map[Proc](toProc, Replicas(proxy, worker, backup, api)...)
+
+	for _, format := range []ConfigFormat{
+		ConfigFormatJsonnet,
+		ConfigFormatJSON,
+		ConfigFormatYAML,
+		ConfigFormatTOML,
+	} {
+		t.Run(string(format), func(t *testing.T) {
+			t.Parallel()
+
+			b, err := ExportConfigs(procs, dir, format)
+			test.NoError(t, err)
+
+			filename := filepath.Join(dir, "pm."+string(format))
+			test.NoError(t, os.WriteFile(filename, b, 0o644))
+
+			configs, err := LoadConfigs(filename)
+			test.NoError(t, err)
+
+			want := fun.Map[RunConfig](func(config RunConfig) RunConfig {
+				config.ConfigFile = filename
+				// NOTE: missing args are loaded as nil, missing stop sequence as empty one
+				config.Args = fun.IF(len(config.Args) == 0, nil, config.Args)
+				config.StopSequence = fun.IF(config.StopSequence == nil, []StopStep{}, config.StopSequence)
+				return config
+			}, api, backup, proxy, worker)
+			test.Eq(t, want, configs)
+		})
+	}
+}
+
+func TestExportProcfileRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	procfile := "web: bundle exec rails server -p $PORT\nworker: ./worker --queue 'high priority'\n"
+	filename := filepath.Join(dir, "Procfile")
+	test.NoError(t, os.WriteFile(filename, []byte(procfile), 0o644))
+
+	configs, err := ImportConfigs(filename, ConfigFormatProcfile)
+	test.NoError(t, err)
+
+	procs := fun.Map[Proc](func(config RunConfig) Proc {
+		var proc Proc
+		proc.Name = config.Name
+		proc.Command = config.Command
+		proc.Args = config.Args
+		proc.Cwd = config.Cwd
+		return proc
+	}, configs...)
+
+	b, err := ExportConfigs(procs, dir, ConfigFormatProcfile)
+	test.NoError(t, err)
+	test.EqOp(t, procfile, string(b))
+}
+
+func TestExportConfigsUnknownFormat(t *testing.T) {
+	t.Parallel()
+
+	_, err := ExportConfigs(nil, t.TempDir(), "xml")
+	test.EqError(t, err, `unknown format "xml", expected one of ["jsonnet" "json" "yaml" "toml" "procfile" "compose"]`)
+}
@@ -79,6 +79,7 @@ func FilterFunc(opts ...FilterOption) func(Proc) bool {
 
 	return func(proc Proc) bool {
 		return fun.Contains(proc.Name, _filter.Names...) ||
+			proc.Group != "" && fun.Contains(proc.Group, _filter.Names...) ||
 			fun.Any(func(elem string) bool {
 				return fun.Contains(elem, _filter.Tags...)
 			}, proc.Tags...) ||
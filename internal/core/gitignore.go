@@ -0,0 +1,156 @@
+package core
+
+import (
+	"bufio"
+	stdErrors "errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/rprtr258/pm/internal/errors"
+)
+
+// gitignoreRule is pattern line from .gitignore file
+type gitignoreRule struct {
+	re     *regexp.Regexp
+	negate bool
+}
+
+// readGitignore rules from file, missing file has no rules
+func readGitignore(filename string) ([]gitignoreRule, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		if stdErrors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "open %s", filename)
+	}
+	defer f.Close()
+
+	rules := []gitignoreRule{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " ")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		pattern, negate := strings.CutPrefix(line, "!")
+		re, err := CompileWatchPattern(pattern)
+		if err != nil {
+			log.Warn().Err(err).Str("file", filename).Str("line", line).Msg("skip invalid gitignore pattern")
+			continue
+		}
+
+		rules = append(rules, gitignoreRule{
+			re:     re,
+			negate: negate,
+		})
+	}
+	return rules, errors.Wrapf(scanner.Err(), "read %s", filename)
+}
+
+// Gitignore checks files against .gitignore files of git repository,
+// reading them lazily
+type Gitignore struct {
+	toplevel string // root of git repository
+
+	mu    sync.Mutex
+	rules map[string][]gitignoreRule // by directory of .gitignore file
+}
+
+// FindGitToplevel is closest directory containing .git directory
+func FindGitToplevel(dir string) (string, bool) {
+	for {
+		if stat, err := os.Stat(filepath.Join(dir, ".git")); err == nil && stat.IsDir() {
+			return dir, true
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", false
+		}
+		dir = parent
+	}
+}
+
+func NewGitignore(toplevel string) *Gitignore {
+	g := &Gitignore{
+		toplevel: toplevel,
+		mu:       sync.Mutex{},
+		rules:    map[string][]gitignoreRule{},
+	}
+
+	// repository wide excludes apply from toplevel
+	exclude, err := readGitignore(filepath.Join(toplevel, ".git", "info", "exclude"))
+	if err != nil {
+		log.Error().Err(err).Msg("read git excludes")
+	}
+	toplevelRules, err := readGitignore(filepath.Join(toplevel, ".gitignore"))
+	if err != nil {
+		log.Error().Err(err).Msg("read gitignore")
+	}
+	g.rules[toplevel] = append(exclude, toplevelRules...)
+	return g
+}
+
+// dirRules are rules of .gitignore file in dir
+func (g *Gitignore) dirRules(dir string) []gitignoreRule {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if rules, ok := g.rules[dir]; ok {
+		return rules
+	}
+
+	rules, err := readGitignore(filepath.Join(dir, ".gitignore"))
+	if err != nil {
+		log.Error().Err(err).Msg("read gitignore")
+	}
+	g.rules[dir] = rules
+	return rules
+}
+
+// matches checks absolute path against rules of .gitignore files in dirs.
+// Rules of deeper .gitignore files take precedence, last matching rule in
+// file wins.
+func (g *Gitignore) matches(dirs []string, path string) bool {
+	ignored := false
+	for _, dir := range dirs {
+		relToDir, err := filepath.Rel(dir, path)
+		if err != nil {
+			continue
+		}
+
+		for _, rule := range g.dirRules(dir) {
+			if rule.re.MatchString(filepath.ToSlash(relToDir)) {
+				ignored = !rule.negate
+			}
+		}
+	}
+	return ignored
+}
+
+// Ignored checks whether absolute path is ignored by git. As in git, file in
+// ignored directory can not be re-included by negated pattern.
+func (g *Gitignore) Ignored(path string) bool {
+	rel, err := filepath.Rel(g.toplevel, path)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return false
+	}
+
+	dirs := []string{g.toplevel}
+	for _, part := range strings.Split(rel, string(filepath.Separator)) {
+		current := filepath.Join(dirs[len(dirs)-1], part)
+		if g.matches(dirs, current) {
+			return true
+		}
+		dirs = append(dirs, current)
+	}
+	return false
+}
@@ -0,0 +1,308 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	stdErrors "errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+
+	"github.com/joho/godotenv"
+	"github.com/rprtr258/fun"
+	"github.com/rs/zerolog/log"
+	"sigs.k8s.io/yaml"
+
+	"github.com/rprtr258/pm/internal/errors"
+)
+
+// newRunConfig of plain process, with all other settings being default
+func newRunConfig(name, cwd string, argv []string, env map[string]string, dependsOn []string) RunConfig {
+	return RunConfig{
+		Name:        name,
+		Command:     argv[0],
+		Args:        argv[1:],
+		Tags:        nil,
+		Cwd:         cwd,
+		Env:         env,
+		Watch:       fun.Invalid[Watch](),
+		Build:       fun.Invalid[Hook](),
+		StdoutFile:  fun.Invalid[string](),
+		StderrFile:  fun.Invalid[string](),
+		KillTimeout: 0,
+		Autorestart: false,
+		MaxRestarts: 0,
+		Startup:     false,
+		DependsOn:   dependsOn,
+		Cron:        fun.Invalid[Cron](),
+		StartDelay:  0,
+		Hooks:       fun.Zero[Hooks](),
+		Notify:      fun.Invalid[Notify](),
+
+		StopSignal:   fun.Invalid[syscall.Signal](),
+		StopCommand:  fun.Invalid[Hook](),
+		StopSequence: nil,
+
+		Listen: nil,
+		Reload: fun.Invalid[Reload](),
+
+		Lazy:        false,
+		IdleTimeout: 0,
+
+		Ports: nil,
+
+		Instances: 0,
+		Group:     "",
+		Instance:  0,
+
+		Profile:    "",
+		ConfigFile: "",
+	}
+}
+
+// readDotenv file, missing file means no variables
+func readDotenv(filename string) (map[string]string, error) {
+	env, err := godotenv.Read(filename)
+	if err != nil {
+		if stdErrors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "read %s", filename)
+	}
+
+	return env, nil
+}
+
+// _procfileShell runs Procfile commands, so they can use variables like
+// $PORT and other shell syntax
+var _procfileShell = []string{"/bin/sh", "-c"}
+
+// procfileArgv runs Procfile command by shell. Shell is replaced by command
+// with exec, so signals are delivered to command itself, like foreman does.
+func procfileArgv(command string) []string {
+	return append(slices.Clone(_procfileShell), "exec "+command)
+}
+
+// procfileCommand is inverse of procfileArgv, invalid if argv is not shell
+// running command
+func procfileCommand(argv []string) fun.Option[string] {
+	if len(argv) != len(_procfileShell)+1 || !slices.Equal(argv[:len(_procfileShell)], _procfileShell) {
+		return fun.Invalid[string]()
+	}
+
+	command, ok := strings.CutPrefix(argv[len(_procfileShell)], "exec ")
+	return fun.Option[string]{Value: command, Valid: ok}
+}
+
+// procfileLines of Procfile with their numbers, lines ending with backslash
+// are joined with following ones
+func procfileLines(data []byte) ([]string, []int, error) {
+	lines, lineNos := []string{}, []int{}
+	continued := false
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if continued {
+			lines[len(lines)-1] += " " + line
+		} else {
+			lines, lineNos = append(lines, line), append(lineNos, lineNo)
+		}
+
+		last := &lines[len(lines)-1]
+		*last, continued = strings.CutSuffix(*last, "\\")
+		*last = strings.TrimSpace(*last)
+	}
+	return lines, lineNos, scanner.Err()
+}
+
+// parseProcfile with lines like "name: command args", processes are run by
+// shell in directory of Procfile with environment from .env file beside it,
+// like foreman does
+func parseProcfile(filename string, data []byte) ([]RunConfig, error) {
+	cwd, err := filepath.Abs(filepath.Dir(filename))
+	if err != nil {
+		return nil, errors.Wrapf(err, "get absolute dir of %q", filename)
+	}
+
+	env, err := readDotenv(filepath.Join(cwd, ".env"))
+	if err != nil {
+		return nil, err
+	}
+
+	lines, lineNos, err := procfileLines(data)
+	if err != nil {
+		return nil, errors.Wrapf(err, "read %s", filename)
+	}
+
+	configs := []RunConfig{}
+	for i, line := range lines {
+		lineNo := lineNos[i]
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		name, command, ok := strings.Cut(line, ":")
+		name, command = strings.TrimSpace(name), strings.TrimSpace(command)
+		if !ok || name == "" {
+			return nil, errors.Newf("%s:%d: expected \"name: command\", got %q", filename, lineNo, line)
+		}
+
+		// NOTE: command is run by shell, splitting only checks quotes are closed
+		argv, err := ShellSplit(command)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s:%d", filename, lineNo)
+		}
+		if len(argv) == 0 {
+			return nil, errors.Newf("%s:%d: empty command of %q", filename, lineNo, name)
+		}
+
+		configs = append(configs, newRunConfig(name, cwd, procfileArgv(command), maps.Clone(env), nil))
+	}
+	return configs, nil
+}
+
+// composeCommand is string, which is shell split, or list of words
+func composeCommand(command any) ([]string, error) {
+	switch c := command.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return ShellSplit(c)
+	case []any:
+		return fun.Map[string](func(word any) string { return fmt.Sprint(word) }, c...), nil
+	default:
+		return nil, errors.Newf("expected string or list, got %T", command)
+	}
+}
+
+// composeEnvironment is map or list of KEY=VALUE
+func composeEnvironment(environment any) (map[string]string, error) {
+	switch e := environment.(type) {
+	case nil:
+		return nil, nil
+	case map[string]any:
+		env := make(map[string]string, len(e))
+		for k, v := range e {
+			env[k] = fun.IF(v == nil, "", fmt.Sprint(v))
+		}
+		return env, nil
+	case []any:
+		env := make(map[string]string, len(e))
+		for _, kv := range e {
+			k, v, _ := strings.Cut(fmt.Sprint(kv), "=")
+			env[k] = v
+		}
+		return env, nil
+	default:
+		return nil, errors.Newf("expected map or list, got %T", environment)
+	}
+}
+
+// composeDependsOn is list of service names or map by service names
+func composeDependsOn(dependsOn any) ([]string, error) {
+	switch d := dependsOn.(type) {
+	case nil:
+		return nil, nil
+	case []any:
+		return fun.Map[string](func(name any) string { return fmt.Sprint(name) }, d...), nil
+	case map[string]any:
+		names := fun.Keys(d)
+		slices.Sort(names)
+		return names, nil
+	default:
+		return nil, errors.Newf("expected list or map, got %T", dependsOn)
+	}
+}
+
+// parseCompose services into processes. Services without command, e.g.
+// ones only running image, are skipped. Relative working_dir is relative to
+// compose file directory.
+func parseCompose(filename string, data []byte) ([]RunConfig, error) {
+	dir, err := filepath.Abs(filepath.Dir(filename))
+	if err != nil {
+		return nil, errors.Wrapf(err, "get absolute dir of %q", filename)
+	}
+
+	jsonData, err := yaml.YAMLToJSON(data)
+	if err != nil {
+		return nil, errors.Wrapf(err, "parse yaml")
+	}
+
+	var compose composeDTO
+	if err := json.Unmarshal(jsonData, &compose); err != nil {
+		return nil, errors.Wrapf(err, "unmarshal compose file")
+	}
+
+	names := []string{}
+	for name, service := range compose.Services {
+		if service.Command == nil && service.Entrypoint == nil {
+			log.Warn().Str("service", name).Msg("service has no command, skipping it")
+			continue
+		}
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return fun.MapErr[RunConfig](func(name string) (RunConfig, error) {
+		service := compose.Services[name]
+
+		entrypoint, err := composeCommand(service.Entrypoint)
+		if err != nil {
+			return fun.Zero[RunConfig](), errors.Wrapf(err, "service %q: invalid entrypoint", name)
+		}
+
+		command, err := composeCommand(service.Command)
+		if err != nil {
+			return fun.Zero[RunConfig](), errors.Wrapf(err, "service %q: invalid command", name)
+		}
+
+		argv := append(entrypoint, command...)
+		if len(argv) == 0 {
+			return fun.Zero[RunConfig](), errors.Newf("service %q: missing command", name)
+		}
+
+		env, err := composeEnvironment(service.Environment)
+		if err != nil {
+			return fun.Zero[RunConfig](), errors.Wrapf(err, "service %q: invalid environment", name)
+		}
+
+		dependsOn, err := composeDependsOn(service.DependsOn)
+		if err != nil {
+			return fun.Zero[RunConfig](), errors.Wrapf(err, "service %q: invalid depends_on", name)
+		}
+
+		cwd := dir
+		if service.WorkingDir != "" {
+			cwd = filepath.Join(dir, service.WorkingDir)
+			if filepath.IsAbs(service.WorkingDir) {
+				cwd = filepath.Clean(service.WorkingDir)
+			}
+		}
+
+		return newRunConfig(name, cwd, argv, env, dependsOn), nil
+	}, names...)
+}
+
+// ImportConfigs from Procfile or docker compose file
+func ImportConfigs(filename string, format ConfigFormat) ([]RunConfig, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, errors.Wrapf(err, "read %s", filename)
+	}
+
+	switch format {
+	case ConfigFormatProcfile:
+		return parseProcfile(filename, data)
+	case ConfigFormatCompose:
+		return parseCompose(filename, data)
+	case ConfigFormatJsonnet, ConfigFormatJSON, ConfigFormatYAML, ConfigFormatTOML:
+		return nil, errors.Newf("%s configs are loaded with pm run --config", format)
+	default:
+		return nil, errors.Newf("unknown format %q, expected one of %q", format, ConfigFormats)
+	}
+}
@@ -0,0 +1,35 @@
+package core
+
+import (
+	"net"
+	"strings"
+
+	"github.com/rprtr258/pm/internal/errors"
+)
+
+// ParseListenAddress like "tcp::8080", "tcp:127.0.0.1:8080", ":8080" or
+// "unix:/run/app.sock" into network and address to listen on.
+func ParseListenAddress(addr string) (network, address string, err error) {
+	network, address, ok := strings.Cut(addr, ":")
+	switch {
+	case !ok:
+		return "", "", errors.Newf("invalid listen address %q, expected network:address", addr)
+	case network == "": // ":8080"
+		network, address = "tcp", addr
+	}
+
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+		if _, _, err := net.SplitHostPort(address); err != nil {
+			return "", "", errors.Wrapf(err, "invalid listen address %q", addr)
+		}
+	case "unix":
+		if address == "" {
+			return "", "", errors.Newf("invalid listen address %q, missing socket path", addr)
+		}
+	default:
+		return "", "", errors.Newf("invalid listen address %q, unsupported network %q", addr, network)
+	}
+
+	return network, address, nil
+}
@@ -0,0 +1,40 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/shoenig/test"
+)
+
+func TestParseListenAddress(t *testing.T) {
+	t.Parallel()
+
+	for addr, tc := range map[string]struct {
+		network string
+		address string
+		err     string
+	}{
+		":8080":              {network: "tcp", address: ":8080"},
+		"tcp::8080":          {network: "tcp", address: ":8080"},
+		"tcp:127.0.0.1:8080": {network: "tcp", address: "127.0.0.1:8080"},
+		"tcp6:[::1]:8080":    {network: "tcp6", address: "[::1]:8080"},
+		"unix:/run/app.sock": {network: "unix", address: "/run/app.sock"},
+		"8080":               {err: `invalid listen address "8080", expected network:address`},
+		"tcp:localhost":      {err: `invalid listen address "tcp:localhost": address localhost: missing port in address`},
+		"unix:":              {err: `invalid listen address "unix:", missing socket path`},
+		"udp::53":            {err: `invalid listen address "udp::53", unsupported network "udp"`},
+	} {
+		t.Run(addr, func(t *testing.T) {
+			t.Parallel()
+
+			network, address, err := ParseListenAddress(addr)
+			if tc.err != "" {
+				test.EqError(t, err, tc.err)
+				return
+			}
+			test.NoError(t, err)
+			test.EqOp(t, tc.network, network)
+			test.EqOp(t, tc.address, address)
+		})
+	}
+}
@@ -0,0 +1,185 @@
+package core
+
+import (
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/google/go-jsonnet"
+	"github.com/google/go-jsonnet/ast"
+
+	"github.com/rprtr258/pm/internal/errors"
+)
+
+// stringArgs of native function call, checking their number and types
+func stringArgs(args []any, n int) ([]string, error) {
+	if len(args) != n {
+		return nil, errors.Newf("wrong number of arguments %d", len(args))
+	}
+
+	res := make([]string, n)
+	for i, arg := range args {
+		s, ok := arg.(string)
+		if !ok {
+			return nil, errors.Newf("argument #%d must be a string, but was %T", i, arg)
+		}
+		res[i] = s
+	}
+	return res, nil
+}
+
+// configPath relative to config file directory, if not absolute
+func configPath(dir, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
+
+// configNatives are native functions available to jsonnet configs as
+// std.native(name), paths are relative to config file directory
+func configNatives(dir string) []*jsonnet.NativeFunction {
+	return []*jsonnet.NativeFunction{
+		{
+			Name: "env",
+			Func: func(args []any) (any, error) {
+				if len(args) != 2 {
+					return nil, errors.Newf("wrong number of arguments %d", len(args))
+				}
+
+				name, ok := args[0].(string)
+				if !ok {
+					return nil, errors.Newf("name must be a string, but was %T", args[0])
+				}
+
+				if value, ok := os.LookupEnv(name); ok {
+					return value, nil
+				}
+				return args[1], nil
+			},
+			Params: ast.Identifiers{"name", "default"},
+		},
+		{
+			Name: "file_exists",
+			Func: func(args []any) (any, error) {
+				path, err := stringArgs(args, 1)
+				if err != nil {
+					return nil, err
+				}
+
+				_, err = os.Stat(configPath(dir, path[0]))
+				return err == nil, nil
+			},
+			Params: ast.Identifiers{"path"},
+		},
+		{
+			Name: "glob",
+			Func: func(args []any) (any, error) {
+				pattern, err := stringArgs(args, 1)
+				if err != nil {
+					return nil, err
+				}
+
+				matches, err := filepath.Glob(configPath(dir, pattern[0]))
+				if err != nil {
+					return nil, errors.Wrapf(err, "glob %q", pattern[0])
+				}
+
+				res := make([]any, len(matches))
+				for i, match := range matches {
+					res[i] = match
+					// relative patterns give paths relative to config file
+					if !filepath.IsAbs(pattern[0]) {
+						if rel, err := filepath.Rel(dir, match); err == nil {
+							res[i] = rel
+						}
+					}
+				}
+				return res, nil
+			},
+			Params: ast.Identifiers{"pattern"},
+		},
+		{
+			Name: "cpu_count",
+			Func: func(args []any) (any, error) {
+				if len(args) != 0 {
+					return nil, errors.Newf("wrong number of arguments %d", len(args))
+				}
+
+				return float64(runtime.NumCPU()), nil
+			},
+			Params: ast.Identifiers{},
+		},
+		{
+			Name: "hostname",
+			Func: func(args []any) (any, error) {
+				if len(args) != 0 {
+					return nil, errors.Newf("wrong number of arguments %d", len(args))
+				}
+
+				hostname, err := os.Hostname()
+				return hostname, errors.Wrapf(err, "get hostname")
+			},
+			Params: ast.Identifiers{},
+		},
+		{
+			Name: "free_port",
+			Func: func(args []any) (any, error) {
+				if len(args) != 0 {
+					return nil, errors.Newf("wrong number of arguments %d", len(args))
+				}
+
+				// NOTE: port is free at the moment only, use ports config
+				// to have port allocated to process
+				l, err := net.Listen("tcp", ":0")
+				if err != nil {
+					return nil, errors.Wrapf(err, "find free port")
+				}
+				defer l.Close()
+
+				return float64(l.Addr().(*net.TCPAddr).Port), nil //nolint:forcetypeassert // tcp listener
+			},
+			Params: ast.Identifiers{},
+		},
+		{
+			Name: "git_branch",
+			Func: func(args []any) (any, error) {
+				if len(args) != 0 {
+					return nil, errors.Newf("wrong number of arguments %d", len(args))
+				}
+
+				// not a git repo or detached head give empty branch
+				out, err := exec.Command("git", "-C", dir, "symbolic-ref", "--short", "-q", "HEAD").Output()
+				if err != nil {
+					return "", nil //nolint:nilerr // see above
+				}
+				return strings.TrimSpace(string(out)), nil
+			},
+			Params: ast.Identifiers{},
+		},
+		{
+			Name: "shell_split",
+			Func: func(args []any) (any, error) {
+				line, err := stringArgs(args, 1)
+				if err != nil {
+					return nil, err
+				}
+
+				words, err := ShellSplit(line[0])
+				if err != nil {
+					return nil, err
+				}
+
+				res := make([]any, len(words))
+				for i, word := range words {
+					res[i] = word
+				}
+				return res, nil
+			},
+			Params: ast.Identifiers{"line"},
+		},
+	}
+}
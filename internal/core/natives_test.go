@@ -0,0 +1,147 @@
+package core
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/google/go-jsonnet"
+	"github.com/shoenig/test"
+)
+
+// evaluateNatives evaluates jsonnet snippet with config natives of dir
+func evaluateNatives(t *testing.T, dir, snippet string) (any, error) {
+	t.Helper()
+
+	vm := jsonnet.MakeVM()
+	for _, f := range configNatives(dir) {
+		vm.NativeFunction(f)
+	}
+
+	res, err := vm.EvaluateAnonymousSnippet("test.jsonnet", snippet)
+	if err != nil {
+		return nil, err
+	}
+
+	var v any
+	test.NoError(t, json.Unmarshal([]byte(res), &v))
+	return v, nil
+}
+
+func TestConfigNatives(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"a.txt":     "",
+		"b.txt":     "",
+		"c.json":    "",
+		"sub/d.txt": "",
+	})
+	hostname, err := os.Hostname()
+	test.NoError(t, err)
+
+	for name, tc := range map[string]struct {
+		snippet string
+		want    any
+		err     string
+	}{
+		"env": {
+			snippet: `std.native("env")("PATH", "none")`,
+			want:    os.Getenv("PATH"),
+		},
+		"env default": {
+			snippet: `std.native("env")("PM_TEST_UNSET_VARIABLE", 1)`,
+			want:    1.0,
+		},
+		"env invalid name": {
+			snippet: `std.native("env")(1, "none")`,
+			err:     "name must be a string, but was float64",
+		},
+		"file exists": {
+			snippet: `[std.native("file_exists")(path) for path in ["a.txt", "sub", "missing.txt", "` + filepath.Join(dir, "c.json") + `"]]`,
+			want:    []any{true, true, false, true},
+		},
+		"glob": {
+			snippet: `std.native("glob")("*.txt")`,
+			want:    []any{"a.txt", "b.txt"},
+		},
+		"glob nested": {
+			snippet: `std.native("glob")("*/*.txt")`,
+			want:    []any{"sub/d.txt"},
+		},
+		"glob absolute": {
+			snippet: `std.native("glob")("` + filepath.Join(dir, "*.json") + `")`,
+			want:    []any{filepath.Join(dir, "c.json")},
+		},
+		"glob no matches": {
+			snippet: `std.native("glob")("*.go")`,
+			want:    []any{},
+		},
+		"glob invalid pattern": {
+			snippet: `std.native("glob")("[")`,
+			err:     `glob "[": syntax error in pattern`,
+		},
+		"glob invalid argument": {
+			snippet: `std.native("glob")(1)`,
+			err:     "argument #0 must be a string, but was float64",
+		},
+		"cpu count": {
+			snippet: `std.native("cpu_count")()`,
+			want:    float64(runtime.NumCPU()),
+		},
+		"hostname": {
+			snippet: `std.native("hostname")()`,
+			want:    hostname,
+		},
+		"free port": {
+			snippet: `local port = std.native("free_port")(); port > 0 && port < 65536`,
+			want:    true,
+		},
+		"git branch outside of repo": {
+			snippet: `std.native("git_branch")()`,
+			want:    "",
+		},
+		"shell split": {
+			snippet: `std.native("shell_split")("./app --name 'my app' \"$HOME\"")`,
+			want:    []any{"./app", "--name", "my app", "$HOME"},
+		},
+		"shell split unclosed quote": {
+			snippet: `std.native("shell_split")("echo 'oops")`,
+			err:     "unclosed quote",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := evaluateNatives(t, dir, tc.snippet)
+			if tc.err != "" {
+				test.ErrorContains(t, err, tc.err)
+				return
+			}
+			test.NoError(t, err)
+			test.Eq(t, tc.want, got)
+		})
+	}
+}
+
+func TestConfigNativesGitBranch(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	for _, args := range [][]string{
+		{"init", "-q", "-b", "feature/x"},
+		{"-c", "user.name=pm", "-c", "user.email=pm@localhost", "commit", "-q", "--allow-empty", "-m", "init"},
+	} {
+		cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+		out, err := cmd.CombinedOutput()
+		test.NoError(t, err, test.Sprint(string(out)))
+	}
+
+	got, err := evaluateNatives(t, dir, `std.native("git_branch")()`)
+	test.NoError(t, err)
+	test.Eq[any](t, "feature/x", got)
+}
@@ -2,11 +2,15 @@ package core
 
 import (
 	"bytes"
+	"cmp"
 	rand2 "crypto/rand"
 	"encoding/hex"
 	"fmt"
 	"io"
 	"math/rand"
+	"regexp"
+	"strings"
+	"syscall"
 	"text/template"
 	"time"
 
@@ -35,11 +39,120 @@ func GenPMID() PMID {
 	return PMID(hex.EncodeToString(b[:]))
 }
 
+// HookFailure is what shim does when hook fails
+type HookFailure string
+
+const (
+	HookFailureIgnore HookFailure = "ignore" // log failure and continue
+	HookFailureAbort  HookFailure = "abort"  // abort child start, only for pre_start and post_start hooks
+)
+
+// Hook is a command run by shim around child lifecycle
+type Hook struct {
+	Command   string        // Command - executable to run
+	Args      []string      // Args - arguments for executable
+	OnFailure HookFailure   // OnFailure - what to do on hook failure
+	Timeout   time.Duration // Timeout - time to wait for hook to finish, 0 means no limit
+}
+
+func (h Hook) String() string {
+	return strings.Join(append([]string{h.Command}, h.Args...), " ")
+}
+
+type Hooks struct {
+	PreStart  fun.Option[Hook] // PreStart - run before each child start
+	PostStart fun.Option[Hook] // PostStart - run after each child start
+	PreStop   fun.Option[Hook] // PreStop - run before child is stopped by pm
+	PostExit  fun.Option[Hook] // PostExit - run after child exited
+	OnCrash   fun.Option[Hook] // OnCrash - run after child exited with failure
+}
+
+// Defined hooks by their config names
+func (h Hooks) Defined() map[string]Hook {
+	res := map[string]Hook{}
+	for name, hook := range map[string]fun.Option[Hook]{
+		"pre_start":  h.PreStart,
+		"post_start": h.PostStart,
+		"pre_stop":   h.PreStop,
+		"post_exit":  h.PostExit,
+		"on_crash":   h.OnCrash,
+	} {
+		if hook, ok := hook.Unpack(); ok {
+			res[name] = hook
+		}
+	}
+	return res
+}
+
+// Notify describes where to send notifications about process failures
+type Notify struct {
+	Webhook   fun.Option[string] // Webhook - url to POST slack-compatible json payload to
+	Command   []string           // Command - command to run with message as last argument
+	Socket    fun.Option[string] // Socket - unix datagram socket to write json payload to
+	RateLimit time.Duration      // RateLimit - minimal interval between notifications
+}
+
+// StopStep is signal to send to process family and time to wait for it to die
+type StopStep struct {
+	Signal  syscall.Signal
+	Timeout time.Duration // 0 means go to next step immediately
+}
+
+func (s StopStep) String() string {
+	if s.Timeout == 0 {
+		return SignalName(s.Signal)
+	}
+	return SignalName(s.Signal) + " " + s.Timeout.String()
+}
+
+// Port allocated for process by pm
+type Port struct {
+	Name string
+	Port int
+}
+
+func (p Port) String() string {
+	return fmt.Sprintf("%s=%d", p.Name, p.Port)
+}
+
+var _nonAlnum = regexp.MustCompile(`[^A-Z0-9]+`)
+
+// PortEnv is environment variable name for port, like PORT_HTTP
+func PortEnv(name string) string {
+	return "PORT_" + _nonAlnum.ReplaceAllString(strings.ToUpper(name), "_")
+}
+
+// ReloadStrategy is how process is reloaded by `pm reload`
+type ReloadStrategy string
+
+const (
+	ReloadRestart   ReloadStrategy = "restart"    // stop process and start it again, dropping connections
+	ReloadSignal    ReloadStrategy = "signal"     // send reload signal to process, so it reloads itself
+	ReloadBlueGreen ReloadStrategy = "blue-green" // start new instance on the same sockets, then stop the old one
+)
+
+var ReloadStrategies = []ReloadStrategy{ReloadRestart, ReloadSignal, ReloadBlueGreen}
+
+// Reload describes how to reload process without downtime
+type Reload struct {
+	Strategy     ReloadStrategy
+	Signal       syscall.Signal // Signal - signal to send for signal strategy
+	Notify       bool           // Notify - new instance is ready when it sends READY=1 to NOTIFY_SOCKET
+	ReadyDelay   time.Duration  // ReadyDelay - new instance is ready if it is still alive after delay, if not Notify
+	ReadyTimeout time.Duration  // ReadyTimeout - time to wait for READY=1 from new instance, if Notify
+}
+
 type Proc struct {
 	ID   PMID
 	Name string
 	Tags []string
 
+	Group    string // Group - name of replicas group, empty if process is not replicated
+	Instance int    // Instance - index of replica in group, starting from 0
+
+	Profile    string // Profile - config profile process was run with, empty if none
+	ConfigFile string // ConfigFile - absolute path of config file process was run from, empty if none
+
 	Command    string            // Command - executable to run
 	Args       []string          // Args - arguments for executable, not including executable itself as first argument
 	Cwd        string            // Cwd - working directory, must be absolute
@@ -47,14 +160,68 @@ type Proc struct {
 	StdoutFile string
 	StderrFile string
 
-	Watch fun.Option[string]
+	Watch fun.Option[Watch] // Watch - files to watch and restart process on changes
+	Build fun.Option[Hook]  // Build - command to run before restart on watch trigger, restart is cancelled if it fails
 
 	Startup bool // Startup - run on OS startup
 
-	KillTimeout time.Duration      // time to wait before sending SIGKILL
+	KillTimeout time.Duration      // time to wait before sending SIGKILL, if StopSequence is not set
 	DependsOn   []string           // names of processes that must be started before this proc
 	MaxRestarts uint               // MaxRestarts - max number of times to restart process
-	Cron        fun.Option[string] // Cron - cron expression
+	Cron        fun.Option[Cron]   // Cron - schedule to run process as job on
+	StartDelay  time.Duration      // StartDelay - time to wait after shim start before starting process or scheduling job
+	Hooks       Hooks              // Hooks - commands run around child lifecycle
+	Notify      fun.Option[Notify] // Notify - notifications on failures
+
+	StopSignal   fun.Option[syscall.Signal] // StopSignal - signal to stop process with instead of SIGTERM
+	StopCommand  fun.Option[Hook]           // StopCommand - command to run to stop process, before sending signals
+	StopSequence []StopStep                 // StopSequence - signals to send to stop process, overrides StopSignal and KillTimeout
+
+	Listen []string           // Listen - addresses of sockets owned by shim and passed to process using LISTEN_FDS
+	Reload fun.Option[Reload] // Reload - how to reload process, restart if not set
+
+	Lazy        bool          // Lazy - start process on first connection to Listen sockets
+	IdleTimeout time.Duration // IdleTimeout - stop process after having no connections for this long, 0 means never
+
+	Ports []Port // Ports - allocated ports, first one is also passed as PORT
+}
+
+// StopSteps to perform to stop process
+func (p Proc) StopSteps() []StopStep {
+	if len(p.StopSequence) > 0 {
+		return p.StopSequence
+	}
+
+	return []StopStep{
+		{Signal: p.StopSignal.OrDefault(syscall.SIGTERM), Timeout: p.KillTimeout},
+		{Signal: syscall.SIGKILL, Timeout: 0},
+	}
+}
+
+// StopTimeout is maximum time to wait for shim to stop process.
+// Zero means stop time is not limited, due to hooks without timeouts.
+func (p Proc) StopTimeout() time.Duration {
+	var res time.Duration
+	for _, hook := range []fun.Option[Hook]{p.Hooks.PreStop, p.Hooks.PostExit} {
+		if hook, ok := hook.Unpack(); ok {
+			if hook.Timeout == 0 {
+				return 0
+			}
+			res += hook.Timeout
+		}
+	}
+	if stopCommand, ok := p.StopCommand.Unpack(); ok {
+		res += cmp.Or(stopCommand.Timeout, p.KillTimeout)
+	}
+	for _, step := range p.StopSteps() {
+		res += step.Timeout
+	}
+	return res
+}
+
+// InstanceName is name of replica with given index in group
+func InstanceName(group string, instance int) string {
+	return fmt.Sprintf("%s#%d", group, instance)
 }
 
 var _procStringTemplate = template.Must(template.New("proc").
@@ -1,18 +1,22 @@
 package core
 
 import (
+	"cmp"
 	"encoding/json"
+	stdErrors "errors"
 	"fmt"
 	"os"
 	"path/filepath"
-	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/google/go-jsonnet"
 	"github.com/google/go-jsonnet/ast"
 	"github.com/joho/godotenv"
 	"github.com/rprtr258/fun"
-	"github.com/rs/zerolog/log"
 
 	"github.com/rprtr258/pm/internal/core/namegen"
 	"github.com/rprtr258/pm/internal/errors"
@@ -20,35 +24,727 @@ import (
 
 // RunConfig - configuration of process to manage
 type RunConfig struct {
-	Env         map[string]string          //  environment variables
-	Watch       fun.Option[*regexp.Regexp] //  regexp for files to watch and restart on changes
-	Command     string                     //  process command, full path
-	Cwd         string                     //  working directory
-	StdoutFile  fun.Option[string]         //  file to write stdout to
-	StderrFile  fun.Option[string]         //  file to write stderr to
-	Args        []string                   //  arguments for process, not including executable itself as first argument
-	Tags        []string                   //  process tags, excluding `all` tag
-	Name        string                     // Name of a process if defined, otherwise generated
-	KillTimeout time.Duration              //  before sending SIGKILL after SIGINT
-	Autorestart bool                       //  restart process automatically after its death
-	MaxRestarts uint                       //  maximum number of restarts, 0 means no limit
-	Startup     bool                       //  run process on OS startup
-	DependsOn   []string                   // name of processes that must be started before this one
-	Cron        fun.Option[string]         // cron expression
+	Env         map[string]string  //  environment variables
+	Watch       fun.Option[Watch]  //  files to watch and restart on changes
+	Build       fun.Option[Hook]   //  command to build process before restart on changes
+	Command     string             //  process command, full path
+	Cwd         string             //  working directory
+	StdoutFile  fun.Option[string] //  file to write stdout to
+	StderrFile  fun.Option[string] //  file to write stderr to
+	Args        []string           //  arguments for process, not including executable itself as first argument
+	Tags        []string           //  process tags, excluding `all` tag
+	Name        string             // Name of a process if defined, otherwise generated
+	KillTimeout time.Duration      //  before sending SIGKILL after SIGINT
+	Autorestart bool               //  restart process automatically after its death
+	MaxRestarts uint               //  maximum number of restarts, 0 means no limit
+	Startup     bool               //  run process on OS startup
+	DependsOn   []string           // name of processes that must be started before this one
+	Cron        fun.Option[Cron]   // schedule to run process as job on
+	StartDelay  time.Duration      // time to wait before first start
+	Hooks       Hooks              // commands run around process lifecycle
+	Notify      fun.Option[Notify] // notifications on process failures
+
+	StopSignal   fun.Option[syscall.Signal] // signal to stop process with instead of SIGTERM
+	StopCommand  fun.Option[Hook]           // command to run to stop process, before sending signals
+	StopSequence []StopStep                 // signals to send to stop process one by one
+
+	Listen []string           // addresses of sockets passed to process
+	Reload fun.Option[Reload] // how to reload process
+
+	Lazy        bool          // start process on first connection
+	IdleTimeout time.Duration // stop process after having no connections for this long
+
+	Ports []string // names of ports to allocate
+
+	Instances uint   // number of replicas to run, 0 means process is not replicated
+	Group     string // name of replicas group, set for replicas only
+	Instance  int    // index of replica in group
+
+	Profile    string // config profile app was loaded with, empty if none
+	ConfigFile string // absolute path of config file app was loaded from, empty if none
+}
+
+// Replicas of process config, each named after group with instance index.
+// Dependencies on replicated configs are replaced with dependencies on all
+// their replicas. Configs without instances are returned as is.
+func Replicas(configs ...RunConfig) []RunConfig {
+	replicaNames := map[string][]string{}
+	for _, config := range configs {
+		for i := range int(config.Instances) { //nolint:gosec // number of instances is small
+			replicaNames[config.Name] = append(replicaNames[config.Name], InstanceName(config.Name, i))
+		}
+	}
+
+	res := []RunConfig{}
+	for _, config := range configs {
+		dependsOn := []string{}
+		for _, name := range config.DependsOn {
+			if names, ok := replicaNames[name]; ok {
+				dependsOn = append(dependsOn, names...)
+			} else {
+				dependsOn = append(dependsOn, name)
+			}
+		}
+		config.DependsOn = dependsOn
+
+		if config.Instances == 0 {
+			res = append(res, config)
+			continue
+		}
+
+		for i, name := range replicaNames[config.Name] {
+			replica := config
+			replica.Name = name
+			replica.Group = config.Name
+			replica.Instance = i
+			res = append(res, replica)
+		}
+	}
+	return res
+}
+
+// hookScanDTO is hook config, which is either shell command string,
+// array of command and args or full object
+type hookScanDTO struct {
+	Command   string   `json:"command"`
+	Args      []string `json:"args,omitempty"`
+	OnFailure string   `json:"on_failure,omitempty"`
+	Timeout   string   `json:"timeout,omitempty"`
+}
+
+func (h *hookScanDTO) UnmarshalJSON(data []byte) error {
+	var shell string
+	if err := json.Unmarshal(data, &shell); err == nil {
+		*h = hookScanDTO{
+			Command:   "/bin/sh",
+			Args:      []string{"-c", shell},
+			OnFailure: "",
+			Timeout:   "",
+		}
+		return nil
+	}
+
+	var argv []string
+	if err := json.Unmarshal(data, &argv); err == nil {
+		if len(argv) == 0 {
+			return errors.New("empty hook command")
+		}
+
+		*h = hookScanDTO{
+			Command:   argv[0],
+			Args:      argv[1:],
+			OnFailure: "",
+			Timeout:   "",
+		}
+		return nil
+	}
+
+	type hook hookScanDTO // NOTE: avoid recursion
+	return unmarshalStrict(data, (*hook)(h))
+}
+
+func (h *hookScanDTO) parse(name string, canAbort bool) (fun.Option[Hook], error) {
+	if h == nil {
+		return fun.Invalid[Hook](), nil
+	}
+
+	if h.Command == "" {
+		return fun.Invalid[Hook](), errors.Newf("hook %s: missing command", name)
+	}
+
+	onFailure := HookFailure(h.OnFailure)
+	switch onFailure {
+	case "":
+		onFailure = HookFailureIgnore
+	case HookFailureIgnore:
+	case HookFailureAbort:
+		if !canAbort {
+			return fun.Invalid[Hook](), errors.Newf("hook %s: on_failure=%q is allowed only for pre_start and post_start hooks", name, onFailure)
+		}
+	default:
+		return fun.Invalid[Hook](), errors.Newf("hook %s: unknown on_failure %q", name, onFailure)
+	}
+
+	var timeout time.Duration
+	if h.Timeout != "" {
+		var err error
+		timeout, err = time.ParseDuration(h.Timeout)
+		if err != nil {
+			return fun.Invalid[Hook](), errors.Wrapf(err, "hook %s: invalid timeout %q", name, h.Timeout)
+		}
+	}
+
+	return fun.Valid(Hook{
+		Command:   h.Command,
+		Args:      h.Args,
+		OnFailure: onFailure,
+		Timeout:   timeout,
+	}), nil
+}
+
+type notifyScanDTO struct {
+	Webhook   *string  `json:"webhook,omitempty"`
+	Command   []string `json:"command,omitempty"`
+	Socket    *string  `json:"socket,omitempty"`
+	RateLimit string   `json:"rate_limit,omitempty"`
 }
 
-func isConfigFile(arg string) bool {
-	stat, err := os.Stat(arg)
+func (n *notifyScanDTO) parse() (fun.Option[Notify], error) {
+	if n == nil {
+		return fun.Invalid[Notify](), nil
+	}
+
+	if n.Webhook == nil && len(n.Command) == 0 && n.Socket == nil {
+		return fun.Invalid[Notify](), errors.New("none of webhook, command, socket is specified")
+	}
+
+	var rateLimit time.Duration
+	if n.RateLimit != "" {
+		var err error
+		rateLimit, err = time.ParseDuration(n.RateLimit)
+		if err != nil {
+			return fun.Invalid[Notify](), errors.Wrapf(err, "invalid rate_limit %q", n.RateLimit)
+		}
+	}
+
+	return fun.Valid(Notify{
+		Webhook:   fun.FromPtr(n.Webhook),
+		Command:   n.Command,
+		Socket:    fun.FromPtr(n.Socket),
+		RateLimit: rateLimit,
+	}), nil
+}
+
+// watchScanDTO is watch config, which is either regex string or full object
+type watchScanDTO struct {
+	Paths     []string `json:"paths,omitempty"`
+	Include   []string `json:"include,omitempty"`
+	Exclude   []string `json:"exclude,omitempty"`
+	Gitignore *bool    `json:"gitignore,omitempty"`
+	Debounce  string   `json:"debounce,omitempty"`
+
+	regex *string
+}
+
+func (w *watchScanDTO) UnmarshalJSON(data []byte) error {
+	var pattern string
+	if err := json.Unmarshal(data, &pattern); err == nil {
+		*w = watchScanDTO{
+			Paths:     nil,
+			Include:   nil,
+			Exclude:   nil,
+			Gitignore: nil,
+			Debounce:  "",
+			regex:     &pattern,
+		}
+		return nil
+	}
+
+	type watch watchScanDTO // NOTE: avoid recursion
+	return unmarshalStrict(data, (*watch)(w))
+}
+
+// parseWatchMode and poll interval, which are set outside of watch config
+func parseWatchMode(mode, interval *string) (WatchMode, time.Duration, error) {
+	watchMode := WatchMode(fun.Deref(mode))
+	switch watchMode {
+	case "":
+		watchMode = WatchModeAuto
+	case WatchModeAuto, WatchModeNotify, WatchModePoll:
+	default:
+		return "", 0, errors.Newf("unknown watch_mode %q, expected one of %q", watchMode, WatchModes)
+	}
+
+	pollInterval := time.Second
+	if interval != nil {
+		var err error
+		pollInterval, err = time.ParseDuration(*interval)
+		if err != nil {
+			return "", 0, errors.Wrapf(err, "invalid watch_interval %q", *interval)
+		}
+		if pollInterval <= 0 {
+			return "", 0, errors.Newf("watch_interval must be positive, got %s", pollInterval)
+		}
+	}
+
+	return watchMode, pollInterval, nil
+}
+
+func (w *watchScanDTO) parse(cwd string, mode, interval *string) (fun.Option[Watch], error) {
+	if w == nil {
+		if mode != nil || interval != nil {
+			return fun.Invalid[Watch](), errors.New("watch_mode and watch_interval require watch")
+		}
+		return fun.Invalid[Watch](), nil
+	}
+
+	watchMode, pollInterval, err := parseWatchMode(mode, interval)
 	if err != nil {
-		return false
+		return fun.Invalid[Watch](), err
+	}
+
+	if w.regex != nil {
+		watch := WatchRegex(*w.regex)
+		if _, err := CompileWatchPattern(watch.Include[0]); err != nil {
+			return fun.Invalid[Watch](), errors.Wrapf(err, "invalid watch pattern")
+		}
+		watch.Mode, watch.PollInterval = watchMode, pollInterval
+		return fun.Valid(watch), nil
+	}
+
+	for _, pattern := range append(slices.Clone(w.Include), w.Exclude...) {
+		if _, err := CompileWatchPattern(pattern); err != nil {
+			return fun.Invalid[Watch](), errors.Wrapf(err, "invalid pattern")
+		}
+	}
+
+	debounce := time.Second
+	if w.Debounce != "" {
+		var err error
+		debounce, err = time.ParseDuration(w.Debounce)
+		if err != nil {
+			return fun.Invalid[Watch](), errors.Wrapf(err, "invalid debounce %q", w.Debounce)
+		}
+	}
+
+	return fun.Valid(Watch{
+		Paths: fun.Map[string](func(path string) string {
+			if filepath.IsAbs(path) {
+				return filepath.Clean(path)
+			}
+			return filepath.Join(cwd, path)
+		}, w.Paths...),
+		Include:      w.Include,
+		Exclude:      w.Exclude,
+		Gitignore:    fun.FromPtr(w.Gitignore).OrDefault(true),
+		Debounce:     debounce,
+		Mode:         watchMode,
+		PollInterval: pollInterval,
+	}), nil
+}
+
+// scheduleScanDTO is job schedule, which is either cron expression or object
+// with interval between runs or time of single run
+type scheduleScanDTO struct {
+	Every string `json:"every,omitempty"`
+	At    string `json:"at,omitempty"` // time or duration from now
+
+	expr string
+}
+
+func (s *scheduleScanDTO) UnmarshalJSON(data []byte) error {
+	var expr string
+	if err := json.Unmarshal(data, &expr); err == nil {
+		*s = scheduleScanDTO{
+			Every: "",
+			At:    "",
+			expr:  expr,
+		}
+		return nil
+	}
+
+	type schedule scheduleScanDTO // NOTE: avoid recursion
+	return unmarshalStrict(data, (*schedule)(s))
+}
+
+func (s scheduleScanDTO) MarshalJSON() ([]byte, error) {
+	if s.expr != "" {
+		return json.Marshal(s.expr)
+	}
+
+	type schedule scheduleScanDTO // NOTE: avoid recursion
+	return json.Marshal(schedule(s))
+}
+
+// _scheduleAtLayouts are accepted formats of time of single run
+var _scheduleAtLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	time.DateTime,
+	"2006-01-02 15:04",
+	time.DateOnly,
+}
+
+// parse schedule into cron config, times without timezone are in loc
+func (s scheduleScanDTO) parse(cron *Cron, loc *time.Location) error {
+	switch {
+	case s.expr != "" && (s.Every != "" || s.At != ""),
+		s.Every != "" && s.At != "":
+		return errors.New("only one of cron expression, every and at can be set")
+	case s.Every != "":
+		every, err := time.ParseDuration(s.Every)
+		if err != nil {
+			return errors.Wrapf(err, "invalid every %q", s.Every)
+		}
+		if every <= 0 {
+			return errors.Newf("every must be positive, got %s", every)
+		}
+		cron.Every = every
+	case s.At != "":
+		if delay, err := time.ParseDuration(s.At); err == nil {
+			cron.At = time.Now().Add(delay).Truncate(time.Second)
+			cron.AtDelay = delay
+			return nil
+		}
+
+		for _, layout := range _scheduleAtLayouts {
+			if at, err := time.ParseInLocation(layout, s.At, loc); err == nil {
+				cron.At = at
+				return nil
+			}
+		}
+		return errors.Newf("invalid at %q, expected time like %q or duration", s.At, "2006-01-02T15:04")
+	default:
+		cron.Schedule = s.expr
+	}
+	return nil
+}
+
+// cronScanDTO is cron config, which is either cron expression or full object
+type cronScanDTO struct {
+	Schedule    scheduleScanDTO `json:"schedule"`
+	Timezone    string          `json:"timezone,omitempty"`
+	Concurrency string          `json:"concurrency,omitempty"`
+	CatchUp     bool            `json:"catch_up,omitempty"`
+	Timeout     string          `json:"timeout,omitempty"`
+	RunOnStart  bool            `json:"run_on_start,omitempty"`
+
+	expr *string
+}
+
+func (c *cronScanDTO) UnmarshalJSON(data []byte) error {
+	var expr string
+	if err := json.Unmarshal(data, &expr); err == nil {
+		*c = cronScanDTO{
+			Schedule:    fun.Zero[scheduleScanDTO](),
+			Timezone:    "",
+			Concurrency: "",
+			CatchUp:     false,
+			Timeout:     "",
+			RunOnStart:  false,
+			expr:        &expr,
+		}
+		return nil
+	}
+
+	type cron cronScanDTO // NOTE: avoid recursion
+	return unmarshalStrict(data, (*cron)(c))
+}
+
+func (c *cronScanDTO) parse() (fun.Option[Cron], error) {
+	if c == nil {
+		return fun.Invalid[Cron](), nil
+	}
+
+	if c.expr != nil {
+		cron := CronSchedule(*c.expr)
+		if err := cron.Validate(); err != nil {
+			return fun.Invalid[Cron](), err
+		}
+		return fun.Valid(cron), nil
+	}
+
+	concurrency := CronConcurrency(c.Concurrency)
+	switch concurrency {
+	case "":
+		concurrency = CronConcurrencyForbid
+	case CronConcurrencyForbid, CronConcurrencyAllow, CronConcurrencyReplace:
+	default:
+		return fun.Invalid[Cron](), errors.Newf("unknown concurrency %q, expected one of %q", concurrency, CronConcurrencies)
+	}
+
+	var timeout time.Duration
+	if c.Timeout != "" {
+		var err error
+		timeout, err = time.ParseDuration(c.Timeout)
+		if err != nil {
+			return fun.Invalid[Cron](), errors.Wrapf(err, "invalid timeout %q", c.Timeout)
+		}
+	}
+
+	cron := Cron{
+		Schedule:    "",
+		Every:       0,
+		At:          time.Time{},
+		AtDelay:     0,
+		Timezone:    c.Timezone,
+		Concurrency: concurrency,
+		CatchUp:     c.CatchUp,
+		Timeout:     timeout,
+		RunOnStart:  c.RunOnStart,
+	}
+	loc, err := cron.Location()
+	if err != nil {
+		return fun.Invalid[Cron](), err
+	}
+	if err := c.Schedule.parse(&cron, loc); err != nil {
+		return fun.Invalid[Cron](), errors.Wrapf(err, "invalid schedule")
+	}
+	if err := cron.Validate(); err != nil {
+		return fun.Invalid[Cron](), err
+	}
+	return fun.Valid(cron), nil
+}
+
+type reloadScanDTO struct {
+	Strategy     string `json:"strategy,omitempty"`
+	Signal       string `json:"signal,omitempty"`
+	Notify       bool   `json:"notify,omitempty"`
+	ReadyDelay   string `json:"ready_delay,omitempty"`
+	ReadyTimeout string `json:"ready_timeout,omitempty"`
+}
+
+func (r *reloadScanDTO) parse(listen []string) (fun.Option[Reload], error) {
+	if r == nil {
+		return fun.Invalid[Reload](), nil
+	}
+
+	strategy := ReloadStrategy(r.Strategy)
+	switch strategy {
+	case "":
+		strategy = ReloadSignal
+	case ReloadRestart, ReloadSignal:
+	case ReloadBlueGreen:
+		if len(listen) == 0 {
+			return fun.Invalid[Reload](), errors.Newf("strategy %q requires listen sockets", strategy)
+		}
+	default:
+		return fun.Invalid[Reload](), errors.Newf("unknown strategy %q, expected one of %q", strategy, ReloadStrategies)
+	}
+
+	signal := syscall.SIGHUP
+	if r.Signal != "" {
+		var err error
+		signal, err = ParseSignal(r.Signal)
+		if err != nil {
+			return fun.Invalid[Reload](), errors.Wrapf(err, "invalid signal")
+		}
+	}
+
+	readyDelay := time.Second
+	if r.ReadyDelay != "" {
+		var err error
+		readyDelay, err = time.ParseDuration(r.ReadyDelay)
+		if err != nil {
+			return fun.Invalid[Reload](), errors.Wrapf(err, "invalid ready_delay %q", r.ReadyDelay)
+		}
+	}
+
+	readyTimeout := 30 * time.Second
+	if r.ReadyTimeout != "" {
+		var err error
+		readyTimeout, err = time.ParseDuration(r.ReadyTimeout)
+		if err != nil {
+			return fun.Invalid[Reload](), errors.Wrapf(err, "invalid ready_timeout %q", r.ReadyTimeout)
+		}
+	}
+
+	return fun.Valid(Reload{
+		Strategy:     strategy,
+		Signal:       signal,
+		Notify:       r.Notify,
+		ReadyDelay:   readyDelay,
+		ReadyTimeout: readyTimeout,
+	}), nil
+}
+
+// parseStopSequence like [["SIGINT", "5s"], ["SIGTERM", "10s"], ["SIGKILL"]]
+func parseStopSequence(steps [][]string) ([]StopStep, error) {
+	return fun.MapErr[StopStep](func(step []string, i int) (StopStep, error) {
+		if len(step) != 1 && len(step) != 2 {
+			return fun.Zero[StopStep](), errors.Newf("step #%d: expected [signal] or [signal, timeout], got %q", i, step)
+		}
+
+		signal, err := ParseSignal(step[0])
+		if err != nil {
+			return fun.Zero[StopStep](), errors.Wrapf(err, "step #%d", i)
+		}
+
+		var timeout time.Duration
+		if len(step) == 2 {
+			timeout, err = time.ParseDuration(step[1])
+			if err != nil {
+				return fun.Zero[StopStep](), errors.Wrapf(err, "step #%d: invalid timeout %q", i, step[1])
+			}
+		}
+
+		return StopStep{
+			Signal:  signal,
+			Timeout: timeout,
+		}, nil
+	}, steps...)
+}
+
+type hooksScanDTO struct {
+	PreStart  *hookScanDTO `json:"pre_start,omitempty"`
+	PostStart *hookScanDTO `json:"post_start,omitempty"`
+	PreStop   *hookScanDTO `json:"pre_stop,omitempty"`
+	PostExit  *hookScanDTO `json:"post_exit,omitempty"`
+	OnCrash   *hookScanDTO `json:"on_crash,omitempty"`
+}
+
+func (h hooksScanDTO) parse() (Hooks, error) {
+	preStart, errPreStart := h.PreStart.parse("pre_start", true)
+	postStart, errPostStart := h.PostStart.parse("post_start", true)
+	preStop, errPreStop := h.PreStop.parse("pre_stop", false)
+	postExit, errPostExit := h.PostExit.parse("post_exit", false)
+	onCrash, errOnCrash := h.OnCrash.parse("on_crash", false)
+	if err := errors.Combine(errPreStart, errPostStart, errPreStop, errPostExit, errOnCrash); err != nil {
+		return fun.Zero[Hooks](), err
+	}
+
+	return Hooks{
+		PreStart:  preStart,
+		PostStart: postStart,
+		PreStop:   preStop,
+		PostExit:  postExit,
+		OnCrash:   onCrash,
+	}, nil
+}
+
+// configScanDTO is process config as written in config file
+type configScanDTO struct {
+	Name          *string           `json:"name"`
+	Cwd           *string           `json:"cwd,omitempty"`
+	Env           map[string]string `json:"env,omitempty"`
+	Command       string            `json:"command"`
+	Args          []any             `json:"args,omitempty"`
+	Tags          []string          `json:"tags,omitempty"`
+	Watch         *watchScanDTO     `json:"watch,omitempty"`
+	WatchMode     *string           `json:"watch_mode,omitempty"`
+	WatchInterval *string           `json:"watch_interval,omitempty"`
+	Startup       bool              `json:"startup,omitempty"`
+	DependsOn     []string          `json:"depends_on,omitempty"`
+	Cron          *cronScanDTO      `json:"cron,omitempty"`
+	StartDelay    *string           `json:"start_delay,omitempty"`
+	Hooks         hooksScanDTO      `json:"hooks,omitzero"`
+	Notify        *notifyScanDTO    `json:"notify,omitempty"`
+
+	Build *hookScanDTO `json:"build,omitempty"`
+
+	StopSignal   *string      `json:"stop_signal,omitempty"`
+	StopCommand  *hookScanDTO `json:"stop_command,omitempty"`
+	StopSequence [][]string   `json:"stop_sequence,omitempty"`
+
+	Listen []string       `json:"listen,omitempty"`
+	Reload *reloadScanDTO `json:"reload,omitempty"`
+
+	Lazy        bool    `json:"lazy,omitempty"`
+	IdleTimeout *string `json:"idle_timeout,omitempty"`
+
+	Instances uint     `json:"instances,omitempty"`
+	Ports     []string `json:"ports,omitempty"`
+}
+
+type loadConfig struct {
+	lookupPort func(proc, port string) (int, error)
+	procNames  fun.Option[[]string]
+	profile    string
+	extStrs    map[string]string
+	extCodes   map[string]string
+	jpath      []string // jsonnet import dirs, last has highest priority
+	dir        string   // directory of config file
+}
+
+type LoadOption func(*loadConfig)
+
+// WithPortLookup provides ports of processes to configs using
+// std.native("port")(proc, port) function
+func WithPortLookup(lookup func(proc, port string) (int, error)) LoadOption {
+	return func(cfg *loadConfig) {
+		cfg.lookupPort = lookup
+	}
+}
+
+// WithExtStr sets jsonnet ext var to string value
+func WithExtStr(name, value string) LoadOption {
+	return func(cfg *loadConfig) {
+		if cfg.extStrs == nil {
+			cfg.extStrs = map[string]string{}
+		}
+		cfg.extStrs[name] = value
+	}
+}
+
+// WithExtCode sets jsonnet ext var to value of jsonnet code
+func WithExtCode(name, code string) LoadOption {
+	return func(cfg *loadConfig) {
+		if cfg.extCodes == nil {
+			cfg.extCodes = map[string]string{}
+		}
+		cfg.extCodes[name] = code
+	}
+}
+
+// WithJPath adds dirs to look up jsonnet imports in, after directory of
+// importing file. Dirs added later take precedence, dirs from JSONNET_PATH
+// environment variable are looked up last.
+func WithJPath(dirs ...string) LoadOption {
+	return func(cfg *loadConfig) {
+		cfg.jpath = append(cfg.jpath, dirs...)
 	}
+}
 
-	return !stat.IsDir()
+// WithProfile selects profile of config file: its overlays are applied to
+// apps and it is passed to jsonnet as std.extVar("profile")
+func WithProfile(profile string) LoadOption {
+	return func(cfg *loadConfig) {
+		cfg.profile = profile
+	}
 }
 
-func newVM() *jsonnet.VM {
+// WithProcNames enables check of depends_on, which must refer to apps of
+// config file or to processes with given names
+func WithProcNames(names ...string) LoadOption {
+	return func(cfg *loadConfig) {
+		cfg.procNames = fun.Valid(names)
+	}
+}
+
+func newVM(cfg loadConfig) *jsonnet.VM {
 	vm := jsonnet.MakeVM()
+	// JSONNET_PATH is in order of precedence, unlike JPaths
+	jpath := filepath.SplitList(os.Getenv("JSONNET_PATH"))
+	slices.Reverse(jpath)
+	vm.Importer(&jsonnet.FileImporter{JPaths: append(jpath, cfg.jpath...)})
+	vm.NativeFunction(&jsonnet.NativeFunction{
+		Name: "port",
+		Func: func(args []any) (any, error) {
+			if len(args) != 2 {
+				return nil, errors.Newf("wrong number of arguments %d", len(args))
+			}
+
+			proc, okProc := args[0].(string)
+			port, okPort := args[1].(string)
+			if !okProc || !okPort {
+				return nil, errors.Newf("proc and port must be strings, but were %T and %T", args[0], args[1])
+			}
+
+			if cfg.lookupPort == nil {
+				return nil, errors.New("ports lookup is not available")
+			}
+
+			res, err := cfg.lookupPort(proc, port)
+			if err != nil {
+				return nil, errors.Wrapf(err, "lookup port %q of %q", port, proc)
+			}
+
+			return float64(res), nil
+		},
+		Params: ast.Identifiers{"proc", "port"},
+	})
 	vm.ExtVar("now", time.Now().Format("15:04:05"))
+	vm.ExtVar("profile", cfg.profile)
+	for name, value := range cfg.extStrs {
+		vm.ExtVar(name, value)
+	}
+	for name, code := range cfg.extCodes {
+		vm.ExtCode(name, code)
+	}
+	for _, f := range configNatives(cfg.dir) {
+		vm.NativeFunction(f)
+	}
 	vm.NativeFunction(&jsonnet.NativeFunction{
 		Name: "dotenv",
 		Func: func(args []any) (any, error) {
@@ -78,95 +774,430 @@ func newVM() *jsonnet.VM {
 	return vm
 }
 
-func LoadConfigs(filename string) ([]RunConfig, error) {
-	if !isConfigFile(filename) {
-		return nil, errors.Newf("invalid config file %q", filename)
+// LoadConfigs from config file or from all config files in directory, parser
+// is chosen by file extension: jsonnet is default, json, yaml, toml and
+// Procfile are supported too
+func LoadConfigs(path string, opts ...LoadOption) ([]RunConfig, error) {
+	configs, errs, err := loadConfigFiles(path, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(errs) > 0 {
+		return nil, errors.Combine(fun.Map[error](func(err *ConfigError) error { return err }, errs...)...)
 	}
 
-	jsonText, err := newVM().EvaluateFile(filename)
+	return configs, nil
+}
+
+// ValidateConfigs of config file or directory, returning all problems found
+// in apps. Error is returned if config file can't be read or evaluated at all.
+func ValidateConfigs(path string, opts ...LoadOption) ([]*ConfigError, error) {
+	_, errs, err := loadConfigFiles(path, opts...)
+	return errs, err
+}
+
+// ConfigFilenames of config path: path itself if it is a file, or config
+// files in it, sorted by name, if it is a directory. Hidden files, jsonnet
+// libraries, Procfiles and docker compose files in directory are skipped, so
+// there might be no config files in it.
+func ConfigFilenames(path string) ([]string, error) {
+	stat, err := os.Stat(path)
 	if err != nil {
-		return nil, errors.Wrapf(err, "evaluate jsonnet file")
+		return nil, errors.Wrapf(err, "invalid config file %q", path)
 	}
 
-	type configScanDTO struct {
-		Name      *string           `json:"name"`
-		Cwd       *string           `json:"cwd"`
-		Env       map[string]string `json:"env"`
-		Command   string            `json:"command"`
-		Args      []any             `json:"args"`
-		Tags      []string          `json:"tags"`
-		Watch     *string           `json:"watch"`
-		Startup   bool              `json:"startup"`
-		DependsOn []string          `json:"depends_on"`
-		Cron      *string           `json:"cron"`
+	if !stat.IsDir() {
+		return []string{path}, nil
 	}
-	var scannedConfigs []configScanDTO
-	if err := json.Unmarshal([]byte(jsonText), &scannedConfigs); err != nil {
-		return nil, errors.Wrapf(err, "unmarshal configs json")
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "read config dir %q", path)
 	}
 
-	// validate configs
-	errValidation := errors.Combine(fun.Map[error](func(config configScanDTO) error {
-		if config.Command == "" {
-			return errors.Newf("missing command in config %#v", config)
+	filenames := []string{}
+	for _, entry := range entries { // NOTE: entries are sorted by name
+		name := entry.Name()
+		if entry.IsDir() || strings.HasPrefix(name, ".") || filepath.Ext(name) == ".libsonnet" {
+			continue
+		}
+
+		switch DetectConfigFormat(name).OrDefault(ConfigFormatProcfile) {
+		case ConfigFormatJsonnet, ConfigFormatJSON, ConfigFormatYAML, ConfigFormatTOML:
+			filenames = append(filenames, filepath.Join(path, name))
+		case ConfigFormatProcfile, ConfigFormatCompose:
 		}
+	}
+
+	return filenames, nil
+}
 
+// configFile is config file decoded into app entries, which are parsed after
+// checks involving apps of all config files
+type configFile struct {
+	filename string
+	format   ConfigFormat
+	names    []string
+	scanned  []configScanDTO
+	decoded  []bool // whether entry is decoded without errors
+	profiles []string
+	errs     []*ConfigError
+}
+
+func (f *configFile) addError(entry int, name string, err error) {
+	var errKey keyError
+	_ = stdErrors.As(err, &errKey)
+	f.errs = append(f.errs, &ConfigError{
+		Filename: f.filename,
+		Line:     0,
+		Column:   0,
+		Entry:    entry,
+		Name:     name,
+		Key:      errKey.key,
+		Err:      err,
+	})
+}
+
+// withPositions of errors, positions are looked up only if there are errors
+func (f *configFile) withPositions() []*ConfigError {
+	if len(f.errs) == 0 {
 		return nil
-	}, scannedConfigs...)...)
-	if errValidation != nil {
-		return nil, errValidation
 	}
 
-	return fun.MapErr[RunConfig](func(config configScanDTO) (RunConfig, error) {
-		watch := fun.Zero[fun.Option[*regexp.Regexp]]()
-		if config.Watch != nil {
-			re, err := regexp.Compile(*config.Watch)
+	source := locateConfigSource(f.filename, f.format)
+	for _, err := range f.errs {
+		pos := source.lookup(err.Entry, err.Key)
+		err.Line, err.Column = pos.line, pos.column
+	}
+	slices.SortStableFunc(f.errs, func(a, b *ConfigError) int {
+		return cmp.Or(a.Entry-b.Entry, a.Line-b.Line)
+	})
+	return f.errs
+}
+
+// decodeConfigFile into app entries
+func decodeConfigFile(filename string, cfg loadConfig) (*configFile, error) {
+	cfg.dir = filepath.Dir(filename)
+	if dir, err := filepath.Abs(cfg.dir); err == nil {
+		cfg.dir = dir
+	}
+
+	file := &configFile{
+		filename: filename,
+		format:   DetectConfigFormat(filename).OrDefault(ConfigFormatJsonnet),
+		names:    nil,
+		scanned:  nil,
+		decoded:  nil,
+		profiles: nil,
+		errs:     nil,
+	}
+
+	jsonText, err := evaluateConfigFile(filename, file.format, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, profiles, err := decodeConfigEntries(jsonText, cfg.profile)
+	if err != nil {
+		file.addError(-1, "", err)
+		return file, nil
+	}
+
+	file.profiles = profiles
+	file.names = fun.Map[string](configEntryName, entries...)
+	file.scanned = make([]configScanDTO, len(entries))
+	file.decoded = make([]bool, len(entries))
+	for i, entry := range entries {
+		entry, entryProfiles, err := applyEntryProfile(entry, cfg.profile)
+		if err != nil {
+			file.addError(i, file.names[i], err)
+			continue
+		}
+		file.profiles = append(file.profiles, entryProfiles...)
+
+		var entryErrs []error
+		file.scanned[i], entryErrs = decodeConfigEntry(entry)
+		for _, err := range entryErrs {
+			file.addError(i, file.names[i], err)
+		}
+		file.decoded[i] = len(entryErrs) == 0
+	}
+
+	return file, nil
+}
+
+// loadConfigFiles returning configs of all config files of path if there are
+// no problems in apps
+//
+//nolint:funlen // no
+func loadConfigFiles(path string, opts ...LoadOption) ([]RunConfig, []*ConfigError, error) {
+	filenames, err := ConfigFilenames(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(filenames) == 0 {
+		return nil, nil, errors.Newf("no config files in dir %q", path)
+	}
+
+	if len(filenames) == 1 && DetectConfigFormat(filenames[0]).OrDefault(ConfigFormatJsonnet) == ConfigFormatProcfile {
+		configs, err := ImportConfigs(filenames[0], ConfigFormatProcfile)
+		return configs, nil, err
+	}
+
+	var cfg loadConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	// imports of config files in directory are also looked up from directory,
+	// before other import dirs
+	if len(filenames) > 1 || filenames[0] != path {
+		cfg.jpath = append(cfg.jpath, path)
+	}
+
+	files := make([]*configFile, len(filenames))
+	for i, filename := range filenames {
+		files[i], err = decodeConfigFile(filename, cfg)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "load %s", filename)
+		}
+	}
+
+	// jsonnet configs might use profile by std.extVar("profile") only
+	if cfg.profile != "" && slices.ContainsFunc(files, func(f *configFile) bool {
+		return f.format != ConfigFormatJsonnet
+	}) {
+		profiles := []string{}
+		for _, file := range files {
+			profiles = append(profiles, file.profiles...)
+		}
+		if !fun.Contains(cfg.profile, profiles...) {
+			profiles = fun.Uniq(profiles...)
+			slices.Sort(profiles)
+			for _, file := range files {
+				if file.format != ConfigFormatJsonnet {
+					file.addError(-1, "", errors.Newf("unknown profile %q, defined profiles are %q", cfg.profile, profiles))
+				}
+			}
+		}
+	}
+
+	// names must be unique among all config files
+	type appRef struct {
+		file  *configFile
+		entry int
+	}
+	firstEntry := map[string]appRef{}
+	for _, file := range files {
+		for i, name := range file.names {
+			if name == "" {
+				continue
+			}
+
+			if first, ok := firstEntry[name]; ok {
+				usedBy := fmt.Sprintf("apps[%d]", first.entry)
+				if first.file != file {
+					usedBy += " of " + first.file.filename
+				}
+				file.addError(i, name, withKey("name", errors.Newf("duplicate name %q, already used by %s", name, usedBy)))
+				continue
+			}
+			firstEntry[name] = appRef{file, i}
+		}
+	}
+
+	if procNames, ok := cfg.procNames.Unpack(); ok {
+		for _, file := range files {
+			for i, config := range file.scanned {
+				for _, dependency := range config.DependsOn {
+					if _, ok := firstEntry[dependency]; !ok && !fun.Contains(dependency, procNames...) {
+						file.addError(i, file.names[i], withKey("depends_on", errors.Newf("depends on unknown process %q", dependency)))
+					}
+				}
+			}
+		}
+	}
+
+	configs := []RunConfig{}
+	errs := []*ConfigError{}
+	for _, file := range files {
+		for i, config := range file.scanned {
+			if !file.decoded[i] {
+				continue
+			}
+
+			runConfig, err := config.parse(file.filename, cfg.profile)
 			if err != nil {
-				return fun.Zero[RunConfig](), errors.Wrapf(err, "invalid watch pattern %q", *config.Watch)
+				file.addError(i, file.names[i], err)
+				continue
 			}
-			watch = fun.Valid(re)
+			configs = append(configs, runConfig)
 		}
+		errs = append(errs, file.withPositions()...)
+	}
+
+	if len(errs) > 0 {
+		return nil, errs, nil
+	}
+
+	return configs, nil, nil
+}
+
+// parse config entry of config file
+//
+//nolint:funlen // no
+func (config configScanDTO) parse(filename, profile string) (RunConfig, error) {
+	hooks, err := config.Hooks.parse()
+	if err != nil {
+		return fun.Zero[RunConfig](), withKey("hooks", errors.Wrapf(err, "invalid hooks"))
+	}
 
-		relativeCwd := filepath.Join(filepath.Dir(filename), fun.Deref(config.Cwd))
-		cwd, err := filepath.Abs(relativeCwd)
+	notify, err := config.Notify.parse()
+	if err != nil {
+		return fun.Zero[RunConfig](), withKey("notify", errors.Wrapf(err, "invalid notify"))
+	}
+
+	stopSignal := fun.Invalid[syscall.Signal]()
+	if config.StopSignal != nil {
+		signal, err := ParseSignal(*config.StopSignal)
 		if err != nil {
-			return fun.Zero[RunConfig](), errors.Wrapf(err, "get absolute cwd, relative is %q", relativeCwd)
-		}
-
-		return RunConfig{
-			Name:    fun.FromPtr(config.Name).OrDefault(namegen.New()),
-			Command: config.Command,
-			Args: fun.Map[string](func(arg any, i int) string {
-				switch a := arg.(type) {
-				case fmt.Stringer:
-					return a.String()
-				case int, int8, int16, int32, int64,
-					uint, uint8, uint16, uint32, uint64,
-					float32, float64, bool, string:
-					return fmt.Sprint(arg)
-				default:
-					argStr := fmt.Sprintf("%v", arg)
-					log.Error().
-						Str("arg", argStr).
-						Int("i", i).
-						Any("config", config).
-						Msg("unknown arg type")
-
-					return argStr
-				}
-			}, config.Args...),
-			Tags:        config.Tags,
-			Cwd:         cwd,
-			Env:         config.Env,
-			Watch:       watch,
-			StdoutFile:  fun.Zero[fun.Option[string]](),
-			StderrFile:  fun.Zero[fun.Option[string]](),
-			KillTimeout: 0,
-			Autorestart: false,
-			MaxRestarts: 0,
-			Startup:     config.Startup,
-			DependsOn:   config.DependsOn,
-			Cron:        fun.FromPtr(config.Cron),
-		}, nil
-	}, scannedConfigs...)
+			return fun.Zero[RunConfig](), withKey("stop_signal", errors.Wrapf(err, "invalid stop_signal"))
+		}
+		stopSignal = fun.Valid(signal)
+	}
+
+	if config.Build != nil && config.Build.OnFailure == "" {
+		// failed build cancels restart by default
+		config.Build.OnFailure = string(HookFailureAbort)
+	}
+	build, err := config.Build.parse("build", true)
+	if err != nil {
+		return fun.Zero[RunConfig](), withKey("build", errors.Wrapf(err, "invalid build"))
+	}
+	if build.Valid && config.Watch == nil {
+		return fun.Zero[RunConfig](), withKey("build", errors.New("build requires watch"))
+	}
+
+	stopCommand, err := config.StopCommand.parse("stop_command", false)
+	if err != nil {
+		return fun.Zero[RunConfig](), withKey("stop_command", errors.Wrapf(err, "invalid stop_command"))
+	}
+
+	stopSequence, err := parseStopSequence(config.StopSequence)
+	if err != nil {
+		return fun.Zero[RunConfig](), withKey("stop_sequence", errors.Wrapf(err, "invalid stop_sequence"))
+	}
+
+	for _, addr := range config.Listen {
+		if _, _, err := ParseListenAddress(addr); err != nil {
+			return fun.Zero[RunConfig](), withKey("listen", errors.Wrapf(err, "invalid listen"))
+		}
+	}
+
+	reload, err := config.Reload.parse(config.Listen)
+	if err != nil {
+		return fun.Zero[RunConfig](), withKey("reload", errors.Wrapf(err, "invalid reload"))
+	}
+
+	var idleTimeout time.Duration
+	if config.IdleTimeout != nil {
+		idleTimeout, err = time.ParseDuration(*config.IdleTimeout)
+		if err != nil {
+			return fun.Zero[RunConfig](), withKey("idle_timeout", errors.Wrapf(err, "invalid idle_timeout %q", *config.IdleTimeout))
+		}
+	}
+
+	if (config.Lazy || idleTimeout > 0) && len(config.Listen) == 0 {
+		return fun.Zero[RunConfig](), withKey(fun.IF(config.Lazy, "lazy", "idle_timeout"), errors.New("lazy and idle_timeout require listen sockets"))
+	}
+
+	for i, port := range config.Ports {
+		if port == "" {
+			return fun.Zero[RunConfig](), withKey("ports", errors.Newf("invalid ports: empty port name #%d", i))
+		}
+		if slices.Index(config.Ports, port) != i {
+			return fun.Zero[RunConfig](), withKey("ports", errors.Newf("invalid ports: duplicate port %q", port))
+		}
+	}
+
+	configFile, err := filepath.Abs(filename)
+	if err != nil {
+		return fun.Zero[RunConfig](), errors.Wrapf(err, "get absolute config file path, relative is %q", filename)
+	}
+
+	// NOTE: absolute cwd is kept as is, e.g. one outside of exported config dir
+	relativeCwd := fun.Deref(config.Cwd)
+	if !filepath.IsAbs(relativeCwd) {
+		relativeCwd = filepath.Join(filepath.Dir(filename), relativeCwd)
+	}
+	cwd, err := filepath.Abs(relativeCwd)
+	if err != nil {
+		return fun.Zero[RunConfig](), withKey("cwd", errors.Wrapf(err, "get absolute cwd, relative is %q", relativeCwd))
+	}
+
+	watch, err := config.Watch.parse(cwd, config.WatchMode, config.WatchInterval)
+	if err != nil {
+		return fun.Zero[RunConfig](), withKey("watch", errors.Wrapf(err, "invalid watch"))
+	}
+
+	cron, err := config.Cron.parse()
+	if err != nil {
+		return fun.Zero[RunConfig](), withKey("cron", errors.Wrapf(err, "invalid cron"))
+	}
+
+	var startDelay time.Duration
+	if config.StartDelay != nil {
+		startDelay, err = time.ParseDuration(*config.StartDelay)
+		if err != nil {
+			return fun.Zero[RunConfig](), withKey("start_delay", errors.Wrapf(err, "invalid start_delay %q", *config.StartDelay))
+		}
+	}
+
+	return RunConfig{
+		Name:    fun.FromPtr(config.Name).OrDefault(namegen.New()),
+		Command: config.Command,
+		Args: fun.Map[string](func(arg any) string {
+			// NOTE: types of args are checked on decoding
+			if number, ok := arg.(float64); ok {
+				return strconv.FormatFloat(number, 'f', -1, 64)
+			}
+			return fmt.Sprint(arg)
+		}, config.Args...),
+		Tags:        config.Tags,
+		Cwd:         cwd,
+		Env:         config.Env,
+		Watch:       watch,
+		Build:       build,
+		StdoutFile:  fun.Zero[fun.Option[string]](),
+		StderrFile:  fun.Zero[fun.Option[string]](),
+		KillTimeout: 0,
+		Autorestart: false,
+		MaxRestarts: 0,
+		Startup:     config.Startup,
+		DependsOn:   config.DependsOn,
+		Cron:        cron,
+		StartDelay:  startDelay,
+		Hooks:       hooks,
+		Notify:      notify,
+
+		StopSignal:   stopSignal,
+		StopCommand:  stopCommand,
+		StopSequence: stopSequence,
+
+		Listen: config.Listen,
+		Reload: reload,
+
+		Lazy:        config.Lazy,
+		IdleTimeout: idleTimeout,
+
+		Ports: config.Ports,
+
+		Instances: config.Instances,
+		Group:     "",
+		Instance:  0,
+
+		Profile:    profile,
+		ConfigFile: configFile,
+	}, nil
 }
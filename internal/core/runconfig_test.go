@@ -0,0 +1,584 @@
+package core
+
+import (
+	"encoding/json"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/rprtr258/fun"
+	"github.com/shoenig/test"
+)
+
+func TestWatchScanDTOParse(t *testing.T) {
+	t.Parallel()
+
+	for name, tc := range map[string]struct {
+		json string
+		want fun.Option[Watch]
+		err  string
+	}{
+		"regex": {
+			json: `"\\.go$"`,
+			want: fun.Valid(WatchRegex(`\.go$`)),
+		},
+		"invalid regex": {
+			json: `"("`,
+			err:  "invalid watch pattern: compile regex \"(\": error parsing regexp: missing closing ): `(`",
+		},
+		"object": {
+			json: `{"paths": ["src", "/abs/dir/"], "include": ["*.go"], "exclude": ["vendor/"], "gitignore": false, "debounce": "100ms"}`,
+			want: fun.Valid(Watch{
+				Paths:        []string{"/cwd/src", "/abs/dir"},
+				Include:      []string{"*.go"},
+				Exclude:      []string{"vendor/"},
+				Gitignore:    false,
+				Debounce:     100 * time.Millisecond,
+				Mode:         WatchModeAuto,
+				PollInterval: time.Second,
+			}),
+		},
+		"gitignore by default": {
+			json: `{}`,
+			want: fun.Valid(Watch{
+				Paths:        nil,
+				Include:      nil,
+				Exclude:      nil,
+				Gitignore:    true,
+				Debounce:     time.Second,
+				Mode:         WatchModeAuto,
+				PollInterval: time.Second,
+			}),
+		},
+		"invalid pattern": {
+			json: `{"exclude": ["[abc"]}`,
+			err:  `invalid pattern: unclosed character class in glob "[abc"`,
+		},
+		"invalid debounce": {
+			json: `{"debounce": "soon"}`,
+			err:  `invalid debounce "soon": time: invalid duration "soon"`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var dto watchScanDTO
+			test.NoError(t, json.Unmarshal([]byte(tc.json), &dto))
+
+			got, err := dto.parse("/cwd", nil, nil)
+			if tc.err != "" {
+				test.EqError(t, err, tc.err)
+				return
+			}
+			test.NoError(t, err)
+			test.Eq(t, tc.want, got)
+		})
+	}
+}
+
+func TestParseWatchMode(t *testing.T) {
+	t.Parallel()
+
+	for name, tc := range map[string]struct {
+		mode         *string
+		interval     *string
+		wantMode     WatchMode
+		wantInterval time.Duration
+		err          string
+	}{
+		"defaults": {
+			wantMode:     WatchModeAuto,
+			wantInterval: time.Second,
+		},
+		"poll": {
+			mode:         fun.Ptr(string(WatchModePoll)),
+			interval:     fun.Ptr("5s"),
+			wantMode:     WatchModePoll,
+			wantInterval: 5 * time.Second,
+		},
+		"unknown mode": {
+			mode: fun.Ptr("inotify"),
+			err:  `unknown watch_mode "inotify", expected one of ["auto" "notify" "poll"]`,
+		},
+		"invalid interval": {
+			interval: fun.Ptr("often"),
+			err:      `invalid watch_interval "often": time: invalid duration "often"`,
+		},
+		"non positive interval": {
+			interval: fun.Ptr("0s"),
+			err:      "watch_interval must be positive, got 0s",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			mode, interval, err := parseWatchMode(tc.mode, tc.interval)
+			if tc.err != "" {
+				test.EqError(t, err, tc.err)
+				return
+			}
+			test.NoError(t, err)
+			test.EqOp(t, tc.wantMode, mode)
+			test.EqOp(t, tc.wantInterval, interval)
+		})
+	}
+}
+
+func TestWatchScanDTOParseMode(t *testing.T) {
+	t.Parallel()
+
+	var dto *watchScanDTO
+	got, err := dto.parse("/cwd", nil, nil)
+	test.NoError(t, err)
+	test.False(t, got.Valid)
+
+	_, err = dto.parse("/cwd", fun.Ptr(string(WatchModePoll)), nil)
+	test.EqError(t, err, "watch_mode and watch_interval require watch")
+
+	dto = &watchScanDTO{
+		Paths:     nil,
+		Include:   nil,
+		Exclude:   nil,
+		Gitignore: nil,
+		Debounce:  "",
+		regex:     fun.Ptr("src"),
+	}
+	got, err = dto.parse("/cwd", fun.Ptr(string(WatchModePoll)), fun.Ptr("3s"))
+	test.NoError(t, err)
+	watch := got.OrDefault(fun.Zero[Watch]())
+	test.EqOp(t, WatchModePoll, watch.Mode)
+	test.EqOp(t, 3*time.Second, watch.PollInterval)
+}
+
+// parseCronJSON like it is read from config
+func parseCronJSON(t *testing.T, data string) (fun.Option[Cron], error) {
+	t.Helper()
+
+	var dto cronScanDTO
+	test.NoError(t, json.Unmarshal([]byte(data), &dto))
+	return dto.parse()
+}
+
+func TestCronScanDTOParse(t *testing.T) {
+	t.Parallel()
+
+	for name, tc := range map[string]struct {
+		json string
+		want fun.Option[Cron]
+		err  string
+	}{
+		"expression": {
+			json: `"*/5 * * * *"`,
+			want: fun.Valid(CronSchedule("*/5 * * * *")),
+		},
+		"invalid expression": {
+			json: `"every minute"`,
+			err:  `invalid cron expression: "every minute"`,
+		},
+		"object": {
+			json: `{"schedule": "0 3 * * *", "timezone": "UTC", "concurrency": "replace", "catch_up": true, "timeout": "1m", "run_on_start": true}`,
+			want: fun.Valid(Cron{
+				Schedule:    "0 3 * * *",
+				Every:       0,
+				At:          time.Time{},
+				AtDelay:     0,
+				Timezone:    "UTC",
+				Concurrency: CronConcurrencyReplace,
+				CatchUp:     true,
+				Timeout:     time.Minute,
+				RunOnStart:  true,
+			}),
+		},
+		"forbid by default": {
+			json: `{"schedule": "* * * * *"}`,
+			want: fun.Valid(Cron{
+				Schedule:    "* * * * *",
+				Every:       0,
+				At:          time.Time{},
+				AtDelay:     0,
+				Timezone:    "",
+				Concurrency: CronConcurrencyForbid,
+				CatchUp:     false,
+				Timeout:     0,
+				RunOnStart:  false,
+			}),
+		},
+		"unknown concurrency": {
+			json: `{"schedule": "* * * * *", "concurrency": "queue"}`,
+			err:  `unknown concurrency "queue", expected one of ["forbid" "allow" "replace"]`,
+		},
+		"invalid timeout": {
+			json: `{"schedule": "* * * * *", "timeout": "long"}`,
+			err:  `invalid timeout "long": time: invalid duration "long"`,
+		},
+		"unknown timezone": {
+			json: `{"schedule": "* * * * *", "timezone": "Mars/Olympus"}`,
+			err:  `load timezone "Mars/Olympus": unknown time zone Mars/Olympus`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := parseCronJSON(t, tc.json)
+			if tc.err != "" {
+				test.EqError(t, err, tc.err)
+				return
+			}
+			test.NoError(t, err)
+			test.Eq(t, tc.want, got)
+		})
+	}
+}
+
+func TestCronScanDTOUnknownKey(t *testing.T) {
+	t.Parallel()
+
+	var dto cronScanDTO
+	test.Error(t, json.Unmarshal([]byte(`{"schedule": "* * * * *", "timeot": "1m"}`), &dto))
+}
+
+func TestScheduleScanDTOParse(t *testing.T) {
+	t.Parallel()
+
+	for name, tc := range map[string]struct {
+		json string
+		want Cron
+		err  string
+	}{
+		"every": {
+			json: `{"schedule": {"every": "90s"}}`,
+			want: Cron{
+				Schedule:    "",
+				Every:       90 * time.Second,
+				At:          time.Time{},
+				AtDelay:     0,
+				Timezone:    "",
+				Concurrency: CronConcurrencyForbid,
+				CatchUp:     false,
+				Timeout:     0,
+				RunOnStart:  false,
+			},
+		},
+		"at in timezone": {
+			json: `{"schedule": {"at": "2030-01-02T03:04"}, "timezone": "UTC"}`,
+			want: Cron{
+				Schedule:    "",
+				Every:       0,
+				At:          time.Date(2030, 1, 2, 3, 4, 0, 0, time.UTC),
+				AtDelay:     0,
+				Timezone:    "UTC",
+				Concurrency: CronConcurrencyForbid,
+				CatchUp:     false,
+				Timeout:     0,
+				RunOnStart:  false,
+			},
+		},
+		"at date": {
+			json: `{"schedule": {"at": "2030-01-02"}, "timezone": "UTC"}`,
+			want: Cron{
+				Schedule:    "",
+				Every:       0,
+				At:          time.Date(2030, 1, 2, 0, 0, 0, 0, time.UTC),
+				AtDelay:     0,
+				Timezone:    "UTC",
+				Concurrency: CronConcurrencyForbid,
+				CatchUp:     false,
+				Timeout:     0,
+				RunOnStart:  false,
+			},
+		},
+		"negative every": {
+			json: `{"schedule": {"every": "-1s"}}`,
+			err:  "invalid schedule: every must be positive, got -1s",
+		},
+		"invalid every": {
+			json: `{"schedule": {"every": "often"}}`,
+			err:  `invalid schedule: invalid every "often": time: invalid duration "often"`,
+		},
+		"invalid at": {
+			json: `{"schedule": {"at": "tomorrow"}}`,
+			err:  `invalid schedule: invalid at "tomorrow", expected time like "2006-01-02T15:04" or duration`,
+		},
+		"every and at": {
+			json: `{"schedule": {"every": "1m", "at": "1m"}}`,
+			err:  "invalid schedule: only one of cron expression, every and at can be set",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := parseCronJSON(t, tc.json)
+			if tc.err != "" {
+				test.EqError(t, err, tc.err)
+				return
+			}
+			test.NoError(t, err)
+			test.Eq(t, fun.Valid(tc.want), got)
+		})
+	}
+}
+
+func TestScheduleScanDTOParseAtDelay(t *testing.T) {
+	t.Parallel()
+
+	before := time.Now().Truncate(time.Second)
+	got, err := parseCronJSON(t, `{"schedule": {"at": "1h"}}`)
+	test.NoError(t, err)
+
+	cron, ok := got.Unpack()
+	test.True(t, ok)
+	test.EqOp(t, time.Hour, cron.AtDelay)
+	test.False(t, cron.At.Before(before.Add(time.Hour)))
+}
+
+func TestUnknownKeyError(t *testing.T) {
+	t.Parallel()
+
+	for key, want := range map[string]string{
+		"comand":      `unknown key "comand", did you mean "command"?`,
+		"wach":        `unknown key "wach", did you mean "watch"?`,
+		"cwdd":        `unknown key "cwdd", did you mean "cwd"?`,
+		"environment": `unknown key "environment"`,
+	} {
+		t.Run(key, func(t *testing.T) {
+			t.Parallel()
+
+			test.EqOp(t, want, unknownKeyError(key).Error())
+		})
+	}
+}
+
+func TestClosestKey(t *testing.T) {
+	t.Parallel()
+
+	for key, want := range map[string]fun.Option[string]{
+		"ab":   fun.Valid("ab"),
+		"abc":  fun.Valid("ab"),
+		"abcd": fun.Valid("abcde"),
+		"xyz":  fun.Invalid[string](),
+	} {
+		t.Run(key, func(t *testing.T) {
+			t.Parallel()
+
+			test.Eq(t, want, closestKey(key, "ab", "abcde", "zzzzzzz"))
+		})
+	}
+}
+
+func TestHooksScanDTOParse(t *testing.T) {
+	t.Parallel()
+
+	for name, tc := range map[string]struct {
+		json string
+		want Hooks
+		err  string
+	}{
+		"none": {
+			json: `{}`,
+			want: Hooks{
+				PreStart:  fun.Invalid[Hook](),
+				PostStart: fun.Invalid[Hook](),
+				PreStop:   fun.Invalid[Hook](),
+				PostExit:  fun.Invalid[Hook](),
+				OnCrash:   fun.Invalid[Hook](),
+			},
+		},
+		"shell, argv and object": {
+			json: `{
+				"pre_start": "make build",
+				"pre_stop": ["curl", "-X", "POST", "localhost/drain"],
+				"post_start": {"command": "./check", "args": ["--ready"], "on_failure": "abort", "timeout": "5s"},
+				"on_crash": {"command": "./report", "on_failure": "ignore"}
+			}`,
+			want: Hooks{
+				PreStart: fun.Valid(Hook{
+					Command:   "/bin/sh",
+					Args:      []string{"-c", "make build"},
+					OnFailure: HookFailureIgnore,
+					Timeout:   0,
+				}),
+				PostStart: fun.Valid(Hook{
+					Command:   "./check",
+					Args:      []string{"--ready"},
+					OnFailure: HookFailureAbort,
+					Timeout:   5 * time.Second,
+				}),
+				PreStop: fun.Valid(Hook{
+					Command:   "curl",
+					Args:      []string{"-X", "POST", "localhost/drain"},
+					OnFailure: HookFailureIgnore,
+					Timeout:   0,
+				}),
+				PostExit: fun.Invalid[Hook](),
+				OnCrash: fun.Valid(Hook{
+					Command:   "./report",
+					Args:      nil,
+					OnFailure: HookFailureIgnore,
+					Timeout:   0,
+				}),
+			},
+		},
+		"empty argv": {
+			json: `{"pre_start": []}`,
+			err:  "empty hook command",
+		},
+		"missing command": {
+			json: `{"post_exit": {"timeout": "1s"}}`,
+			err:  "hook post_exit: missing command",
+		},
+		"abort not allowed": {
+			json: `{"pre_stop": {"command": "true", "on_failure": "abort"}}`,
+			err:  `hook pre_stop: on_failure="abort" is allowed only for pre_start and post_start hooks`,
+		},
+		"unknown on_failure": {
+			json: `{"pre_start": {"command": "true", "on_failure": "retry"}}`,
+			err:  `hook pre_start: unknown on_failure "retry"`,
+		},
+		"invalid timeout": {
+			json: `{"pre_start": {"command": "true", "timeout": "long"}}`,
+			err:  `hook pre_start: invalid timeout "long": time: invalid duration "long"`,
+		},
+		"all errors": {
+			json: `{"pre_start": {"command": "true", "timeout": "long"}, "on_crash": {"command": "true", "on_failure": "abort"}}`,
+			err: `hook pre_start: invalid timeout "long": time: invalid duration "long"; ` +
+				`hook on_crash: on_failure="abort" is allowed only for pre_start and post_start hooks`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var dto hooksScanDTO
+			err := json.Unmarshal([]byte(tc.json), &dto)
+			if err == nil {
+				var got Hooks
+				got, err = dto.parse()
+				if tc.err == "" {
+					test.NoError(t, err)
+					test.Eq(t, tc.want, got)
+					return
+				}
+			}
+			test.EqError(t, err, tc.err)
+		})
+	}
+}
+
+func TestReloadScanDTOParse(t *testing.T) {
+	t.Parallel()
+
+	for name, tc := range map[string]struct {
+		json   string
+		listen []string
+		want   Reload
+		err    string
+	}{
+		"defaults": {
+			json: `{}`,
+			want: Reload{
+				Strategy:     ReloadSignal,
+				Signal:       syscall.SIGHUP,
+				Notify:       false,
+				ReadyDelay:   time.Second,
+				ReadyTimeout: 30 * time.Second,
+			},
+		},
+		"signal": {
+			json: `{"strategy": "signal", "signal": "USR2"}`,
+			want: Reload{
+				Strategy:     ReloadSignal,
+				Signal:       syscall.SIGUSR2,
+				Notify:       false,
+				ReadyDelay:   time.Second,
+				ReadyTimeout: 30 * time.Second,
+			},
+		},
+		"restart": {
+			json: `{"strategy": "restart"}`,
+			want: Reload{
+				Strategy:     ReloadRestart,
+				Signal:       syscall.SIGHUP,
+				Notify:       false,
+				ReadyDelay:   time.Second,
+				ReadyTimeout: 30 * time.Second,
+			},
+		},
+		"blue-green": {
+			json:   `{"strategy": "blue-green", "notify": true, "ready_delay": "2s", "ready_timeout": "1m"}`,
+			listen: []string{":8080"},
+			want: Reload{
+				Strategy:     ReloadBlueGreen,
+				Signal:       syscall.SIGHUP,
+				Notify:       true,
+				ReadyDelay:   2 * time.Second,
+				ReadyTimeout: time.Minute,
+			},
+		},
+		"blue-green without sockets": {
+			json: `{"strategy": "blue-green"}`,
+			err:  `strategy "blue-green" requires listen sockets`,
+		},
+		"unknown strategy": {
+			json: `{"strategy": "rolling"}`,
+			err:  `unknown strategy "rolling", expected one of ["restart" "signal" "blue-green"]`,
+		},
+		"unknown signal": {
+			json: `{"signal": "SIGNOPE"}`,
+			err:  `invalid signal: unknown signal: "SIGNOPE"`,
+		},
+		"invalid ready_delay": {
+			json: `{"ready_delay": "soon"}`,
+			err:  `invalid ready_delay "soon": time: invalid duration "soon"`,
+		},
+		"invalid ready_timeout": {
+			json: `{"ready_timeout": "never"}`,
+			err:  `invalid ready_timeout "never": time: invalid duration "never"`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var dto reloadScanDTO
+			test.NoError(t, json.Unmarshal([]byte(tc.json), &dto))
+
+			got, err := dto.parse(tc.listen)
+			if tc.err != "" {
+				test.EqError(t, err, tc.err)
+				return
+			}
+			test.NoError(t, err)
+			test.Eq(t, fun.Valid(tc.want), got)
+		})
+	}
+}
+
+func TestReplicas(t *testing.T) {
+	t.Parallel()
+
+	config := func(name string, instances uint, dependsOn ...string) RunConfig {
+		var config RunConfig
+		config.Name = name
+		config.Command = "./" + name
+		config.Instances = instances
+		config.DependsOn = dependsOn
+		return config
+	}
+	replica := func(group string, instance int, dependsOn ...string) RunConfig {
+		res := config(group, 2, dependsOn...)
+		res.Name = InstanceName(group, instance)
+		res.Group = group
+		res.Instance = instance
+		return res
+	}
+
+	got := Replicas(
+		config("db", 0),
+		config("web", 2, "db"),
+		config("proxy", 0, "web", "cache"),
+	)
+	test.Eq(t, []RunConfig{
+		config("db", 0, []string{}...),
+		replica("web", 0, "db"),
+		replica("web", 1, "db"),
+		config("proxy", 0, "web#0", "web#1", "cache"),
+	}, got)
+}
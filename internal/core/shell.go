@@ -0,0 +1,93 @@
+package core
+
+import (
+	"strings"
+
+	"github.com/rprtr258/pm/internal/errors"
+)
+
+// ShellSplit command line into words like POSIX shell does, handling single
+// and double quotes and backslash escapes. Expansions and operators are not
+// supported and are kept as is.
+func ShellSplit(line string) ([]string, error) {
+	words := []string{}
+	var (
+		word   strings.Builder
+		inWord bool
+		quote  rune // quote char if inside quotes
+		escape bool // previous char is backslash
+	)
+	for _, c := range line {
+		switch {
+		case escape:
+			escape = false
+			// inside double quotes backslash escapes only special chars
+			if quote == '"' && !strings.ContainsRune("$`\"\\\n", c) {
+				word.WriteRune('\\')
+			}
+			// escaped newline is line continuation, not part of word
+			if c != '\n' {
+				word.WriteRune(c)
+				inWord = true
+			}
+		case quote == '\'':
+			if c == '\'' {
+				quote = 0
+			} else {
+				word.WriteRune(c)
+			}
+		case c == '\\':
+			escape = true
+		case quote == '"':
+			if c == '"' {
+				quote = 0
+			} else {
+				word.WriteRune(c)
+			}
+		case c == '\'' || c == '"':
+			quote, inWord = c, true
+		case c == ' ' || c == '\t' || c == '\n':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(c)
+			inWord = true
+		}
+	}
+
+	switch {
+	case escape:
+		return nil, errors.Newf("unfinished escape in %q", line)
+	case quote != 0:
+		return nil, errors.Newf("unclosed quote %c in %q", quote, line)
+	}
+
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
+
+// ShellQuote word, so that shell reads it as is
+func ShellQuote(word string) string {
+	if word != "" && strings.IndexFunc(word, func(c rune) bool {
+		return !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' ||
+			strings.ContainsRune("-_./:=,+@%", c))
+	}) == -1 {
+		return word
+	}
+
+	return "'" + strings.ReplaceAll(word, "'", `'\''`) + "'"
+}
+
+// ShellJoin words into command line, inverse of ShellSplit
+func ShellJoin(words ...string) string {
+	quoted := make([]string, len(words))
+	for i, word := range words {
+		quoted[i] = ShellQuote(word)
+	}
+	return strings.Join(quoted, " ")
+}
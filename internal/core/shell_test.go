@@ -0,0 +1,137 @@
+package core
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/shoenig/test"
+)
+
+func TestShellSplit(t *testing.T) {
+	t.Parallel()
+
+	for line, want := range map[string][]string{
+		"":                               {},
+		"  \t ":                          {},
+		"npm run dev":                    {"npm", "run", "dev"},
+		"  a   b  ":                      {"a", "b"},
+		`echo 'a b' "c d"`:               {"echo", "a b", "c d"},
+		`echo ''`:                        {"echo", ""},
+		`echo a""b`:                      {"echo", "ab"},
+		`echo 'it'\''s'`:                 {"echo", "it's"},
+		`echo 'a\nb'`:                    {"echo", `a\nb`},
+		`echo a\ b`:                      {"echo", "a b"},
+		`echo \'x\'`:                     {"echo", "'x'"},
+		`echo "a \"b\" \$c \\ \d"`:       {"echo", `a "b" $c \ \d`},
+		`echo "it's"`:                    {"echo", "it's"},
+		"echo a \\\n b":                  {"echo", "a", "b"},
+		"echo a\\\nb":                    {"echo", "ab"},
+		`sh -c "exec $CMD --port=$PORT"`: {"sh", "-c", "exec $CMD --port=$PORT"},
+		"a | b > c":                      {"a", "|", "b", ">", "c"},
+	} {
+		got, err := ShellSplit(line)
+		test.NoError(t, err, test.Sprint(line))
+		test.Eq(t, want, got, test.Sprint(line))
+	}
+
+	for _, line := range []string{
+		`echo 'a`,
+		`echo "a`,
+		`echo a\`,
+		`echo "a\"`,
+	} {
+		_, err := ShellSplit(line)
+		test.Error(t, err, test.Sprint(line))
+	}
+}
+
+func TestShellJoin(t *testing.T) {
+	t.Parallel()
+
+	for _, words := range [][]string{
+		{"npm", "run", "dev"},
+		{"echo", "a b", ""},
+		{"echo", "it's", `"quoted"`, `back\slash`},
+		{"sh", "-c", "exec $CMD > /dev/null"},
+		{"--port=8080", "user@host:/path,x+y%"},
+	} {
+		got, err := ShellSplit(ShellJoin(words...))
+		test.NoError(t, err)
+		test.Eq(t, words, got)
+	}
+
+	test.EqOp(t, "--port=8080", ShellQuote("--port=8080"))
+	test.EqOp(t, "''", ShellQuote(""))
+	test.EqOp(t, `'it'\''s'`, ShellQuote("it's"))
+}
+
+func TestParseProcfile(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	test.NoError(t, os.WriteFile(filepath.Join(dir, ".env"), []byte("PORT=5000\n# comment\nNAME='a b'\n"), 0o644))
+
+	filename := filepath.Join(dir, "Procfile")
+	configs, err := parseProcfile(filename, []byte(`# processes
+web: bundle exec rails s -p "$PORT"
+
+worker:   sidekiq -q 'default, 2' \
+    --verbose \
+    -c 5
+release: ./migrate.sh && echo done
+`))
+	test.NoError(t, err)
+	test.EqOp(t, 3, len(configs))
+
+	test.EqOp(t, "web", configs[0].Name)
+	test.EqOp(t, "/bin/sh", configs[0].Command)
+	test.Eq(t, []string{"-c", `exec bundle exec rails s -p "$PORT"`}, configs[0].Args)
+	test.EqOp(t, dir, configs[0].Cwd)
+	test.Eq(t, map[string]string{"PORT": "5000", "NAME": "a b"}, configs[0].Env)
+
+	test.EqOp(t, "worker", configs[1].Name)
+	test.Eq(t, []string{"-c", "exec sidekiq -q 'default, 2' --verbose -c 5"}, configs[1].Args)
+
+	test.EqOp(t, "release", configs[2].Name)
+	test.Eq(t, []string{"-c", "exec ./migrate.sh && echo done"}, configs[2].Args)
+}
+
+func TestParseProcfileRunsShell(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	test.NoError(t, os.WriteFile(filepath.Join(dir, ".env"), []byte("PORT=5000\n"), 0o644))
+
+	configs, err := parseProcfile(filepath.Join(dir, "Procfile"), []byte(`web: echo "port=$PORT" \
+  "${NAME:-anon}"`))
+	test.NoError(t, err)
+	test.EqOp(t, 1, len(configs))
+
+	cmd := exec.Command(configs[0].Command, configs[0].Args...)
+	cmd.Env = append(os.Environ(), "PORT="+configs[0].Env["PORT"])
+	out, err := cmd.Output()
+	test.NoError(t, err)
+	test.EqOp(t, "port=5000 anon\n", string(out))
+}
+
+func TestParseProcfileErrors(t *testing.T) {
+	t.Parallel()
+
+	for data, want := range map[string]string{
+		"web bundle exec rails s": `Procfile:1: expected "name: command", got "web bundle exec rails s"`,
+		": rails s":               `Procfile:1: expected "name: command", got ": rails s"`,
+		"# web\nweb:":             `Procfile:2: empty command of "web"`,
+		"web: echo 'a":            `Procfile:1: unclosed quote`,
+		"web: echo \\\n'a":        `Procfile:1: unclosed quote`,
+	} {
+		t.Run(data, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := parseProcfile("Procfile", []byte(data))
+			test.Error(t, err)
+			test.StrContains(t, err.Error(), want)
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package core
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/rprtr258/fun"
+
+	"github.com/rprtr258/pm/internal/errors"
+)
+
+var (
+	_signalsByName = map[string]syscall.Signal{
+		"SIGHUP":  syscall.SIGHUP,
+		"SIGINT":  syscall.SIGINT,
+		"SIGQUIT": syscall.SIGQUIT,
+		"SIGKILL": syscall.SIGKILL,
+		"SIGUSR1": syscall.SIGUSR1,
+		"SIGUSR2": syscall.SIGUSR2,
+		"SIGTERM": syscall.SIGTERM,
+		"SIGCONT": syscall.SIGCONT,
+		"SIGSTOP": syscall.SIGSTOP,
+	}
+	// Signals by names, with and without SIG prefix
+	Signals = func() map[string]syscall.Signal {
+		res := make(map[string]syscall.Signal, 2*len(_signalsByName))
+		for name, sig := range _signalsByName {
+			res[name] = sig
+			res[strings.TrimPrefix(name, "SIG")] = sig
+		}
+		return res
+	}()
+	SignalNames = func() []string {
+		res := fun.Keys(Signals)
+		sort.Strings(res)
+		return res
+	}()
+)
+
+// ParseSignal from its name, e.g. SIGTERM or TERM, or number
+func ParseSignal(signal string) (syscall.Signal, error) {
+	if sig, ok := Signals[strings.ToUpper(signal)]; ok {
+		return sig, nil
+	}
+
+	if x, err := strconv.Atoi(signal); err == nil {
+		return syscall.Signal(x), nil
+	}
+
+	return 0, errors.Newf("unknown signal: %q", signal)
+}
+
+// SignalName returns name of signal, e.g. SIGTERM
+func SignalName(sig syscall.Signal) string {
+	for name, s := range _signalsByName {
+		if s == sig {
+			return name
+		}
+	}
+	return strconv.Itoa(int(sig))
+}
@@ -0,0 +1,178 @@
+package core
+
+import (
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/rprtr258/fun"
+	"github.com/shoenig/test"
+)
+
+func TestParseSignal(t *testing.T) {
+	t.Parallel()
+
+	for signal, want := range map[string]syscall.Signal{
+		"SIGTERM": syscall.SIGTERM,
+		"TERM":    syscall.SIGTERM,
+		"sigint":  syscall.SIGINT,
+		"usr1":    syscall.SIGUSR1,
+		"9":       syscall.SIGKILL,
+	} {
+		t.Run(signal, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := ParseSignal(signal)
+			test.NoError(t, err)
+			test.EqOp(t, want, got)
+		})
+	}
+
+	_, err := ParseSignal("SIGNOPE")
+	test.EqError(t, err, `unknown signal: "SIGNOPE"`)
+}
+
+func TestSignalName(t *testing.T) {
+	t.Parallel()
+
+	test.EqOp(t, "SIGTERM", SignalName(syscall.SIGTERM))
+	test.EqOp(t, "SIGUSR2", SignalName(syscall.SIGUSR2))
+	test.EqOp(t, "31", SignalName(syscall.Signal(31)))
+}
+
+func TestParseStopSequence(t *testing.T) {
+	t.Parallel()
+
+	for name, tc := range map[string]struct {
+		steps [][]string
+		want  []StopStep
+		err   string
+	}{
+		"empty": {
+			steps: nil,
+			want:  []StopStep{},
+		},
+		"signals with timeouts": {
+			steps: [][]string{{"SIGINT", "5s"}, {"TERM", "10s"}, {"SIGKILL"}},
+			want: []StopStep{
+				{Signal: syscall.SIGINT, Timeout: 5 * time.Second},
+				{Signal: syscall.SIGTERM, Timeout: 10 * time.Second},
+				{Signal: syscall.SIGKILL, Timeout: 0},
+			},
+		},
+		"empty step": {
+			steps: [][]string{{"SIGINT", "5s"}, {}},
+			err:   `step #1: expected [signal] or [signal, timeout], got []`,
+		},
+		"too long step": {
+			steps: [][]string{{"SIGINT", "5s", "10s"}},
+			err:   `step #0: expected [signal] or [signal, timeout], got ["SIGINT" "5s" "10s"]`,
+		},
+		"unknown signal": {
+			steps: [][]string{{"SIGNOPE"}},
+			err:   `step #0: unknown signal: "SIGNOPE"`,
+		},
+		"invalid timeout": {
+			steps: [][]string{{"SIGINT", "soon"}},
+			err:   `step #0: invalid timeout "soon": time: invalid duration "soon"`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := parseStopSequence(tc.steps)
+			if tc.err != "" {
+				test.EqError(t, err, tc.err)
+				return
+			}
+			test.NoError(t, err)
+			test.Eq(t, tc.want, got)
+		})
+	}
+}
+
+func TestStopSteps(t *testing.T) {
+	t.Parallel()
+
+	hook := func(timeout time.Duration) fun.Option[Hook] {
+		return fun.Valid(Hook{
+			Command:   "true",
+			Args:      nil,
+			OnFailure: HookFailureIgnore,
+			Timeout:   timeout,
+		})
+	}
+
+	for name, tc := range map[string]struct {
+		proc        func(*Proc)
+		wantSteps   []StopStep
+		wantTimeout time.Duration
+	}{
+		"default": {
+			proc: func(*Proc) {},
+			wantSteps: []StopStep{
+				{Signal: syscall.SIGTERM, Timeout: 5 * time.Second},
+				{Signal: syscall.SIGKILL, Timeout: 0},
+			},
+			wantTimeout: 5 * time.Second,
+		},
+		"stop signal": {
+			proc: func(p *Proc) {
+				p.StopSignal = fun.Valid(syscall.SIGQUIT)
+			},
+			wantSteps: []StopStep{
+				{Signal: syscall.SIGQUIT, Timeout: 5 * time.Second},
+				{Signal: syscall.SIGKILL, Timeout: 0},
+			},
+			wantTimeout: 5 * time.Second,
+		},
+		"stop sequence overrides stop signal": {
+			proc: func(p *Proc) {
+				p.StopSignal = fun.Valid(syscall.SIGQUIT)
+				p.StopSequence = []StopStep{
+					{Signal: syscall.SIGINT, Timeout: 2 * time.Second},
+					{Signal: syscall.SIGTERM, Timeout: 3 * time.Second},
+				}
+			},
+			wantSteps: []StopStep{
+				{Signal: syscall.SIGINT, Timeout: 2 * time.Second},
+				{Signal: syscall.SIGTERM, Timeout: 3 * time.Second},
+			},
+			wantTimeout: 5 * time.Second,
+		},
+		"hooks and stop command": {
+			proc: func(p *Proc) {
+				p.Hooks.PreStop = hook(time.Second)
+				p.Hooks.PostExit = hook(2 * time.Second)
+				p.StopCommand = hook(0)
+			},
+			wantSteps: []StopStep{
+				{Signal: syscall.SIGTERM, Timeout: 5 * time.Second},
+				{Signal: syscall.SIGKILL, Timeout: 0},
+			},
+			// stop command without timeout is limited by kill timeout
+			wantTimeout: 13 * time.Second,
+		},
+		"hook without timeout": {
+			proc: func(p *Proc) {
+				p.Hooks.PreStop = hook(0)
+			},
+			wantSteps: []StopStep{
+				{Signal: syscall.SIGTERM, Timeout: 5 * time.Second},
+				{Signal: syscall.SIGKILL, Timeout: 0},
+			},
+			wantTimeout: 0,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var proc Proc
+			proc.KillTimeout = 5 * time.Second
+			tc.proc(&proc)
+
+			test.Eq(t, tc.wantSteps, proc.StopSteps())
+			test.EqOp(t, tc.wantTimeout, proc.StopTimeout())
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package core
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/rprtr258/pm/internal/errors"
+)
+
+// _watchRegexPrefix marks watch pattern as regex instead of glob
+const _watchRegexPrefix = "re:"
+
+// WatchMode is how changes of watched files are detected
+type WatchMode string
+
+const (
+	WatchModeAuto   WatchMode = "auto"   // use inotify, fall back to polling if inotify watches limit is reached
+	WatchModeNotify WatchMode = "notify" // use inotify only
+	WatchModePoll   WatchMode = "poll"   // periodically scan files, works on network filesystems
+)
+
+var WatchModes = []WatchMode{WatchModeAuto, WatchModeNotify, WatchModePoll}
+
+// Watch describes files to watch and restart process on changes
+type Watch struct {
+	Paths        []string      // Paths - absolute directories to watch, Cwd if empty
+	Include      []string      // Include - patterns of files to restart on, any file if empty
+	Exclude      []string      // Exclude - patterns of files to ignore
+	Gitignore    bool          // Gitignore - ignore files ignored by git
+	Debounce     time.Duration // Debounce - time to gather changes before restart
+	Mode         WatchMode     // Mode - how changes are detected
+	PollInterval time.Duration // PollInterval - time between scans when polling
+}
+
+func (w Watch) String() string {
+	var b strings.Builder
+	if len(w.Paths) > 0 {
+		fmt.Fprintf(&b, "paths=%q ", w.Paths)
+	}
+	if len(w.Include) > 0 {
+		fmt.Fprintf(&b, "include=%q ", w.Include)
+	}
+	if len(w.Exclude) > 0 {
+		fmt.Fprintf(&b, "exclude=%q ", w.Exclude)
+	}
+	if w.Gitignore {
+		b.WriteString("gitignore ")
+	}
+	fmt.Fprintf(&b, "debounce=%s", w.Debounce)
+	if w.Mode != "" && w.Mode != WatchModeAuto {
+		fmt.Fprintf(&b, " mode=%s", w.Mode)
+	}
+	if w.Mode != WatchModeNotify {
+		fmt.Fprintf(&b, " poll_interval=%s", w.PollInterval)
+	}
+	return b.String()
+}
+
+// WatchRegex is watch config for legacy regex watch pattern
+func WatchRegex(pattern string) Watch {
+	return Watch{
+		Paths:        nil,
+		Include:      []string{_watchRegexPrefix + pattern},
+		Exclude:      nil,
+		Gitignore:    false,
+		Debounce:     time.Second,
+		Mode:         WatchModeAuto,
+		PollInterval: time.Second,
+	}
+}
+
+// CompileWatchPattern into regex matching slash separated paths relative to
+// watched directory. Patterns prefixed with "re:" are regexes, others are
+// globs like "*.go", "src/**/*.ts" or "node_modules". Globs without slash
+// match file or directory name at any depth, globs matching directory match
+// everything inside it.
+func CompileWatchPattern(pattern string) (*regexp.Regexp, error) {
+	if re, ok := strings.CutPrefix(pattern, _watchRegexPrefix); ok {
+		res, err := regexp.Compile(re)
+		return res, errors.Wrapf(err, "compile regex %q", re)
+	}
+
+	glob := strings.TrimSuffix(pattern, "/")
+	if glob == "" {
+		return nil, errors.Newf("empty glob %q", pattern)
+	}
+
+	var b strings.Builder
+	if strings.Contains(glob, "/") {
+		glob = strings.TrimPrefix(glob, "/")
+		b.WriteString("^")
+	} else {
+		b.WriteString("^(.*/)?")
+	}
+
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; {
+		case strings.HasPrefix(glob[i:], "**/"):
+			b.WriteString("(.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(glob[i:], ']')
+			if end == -1 {
+				return nil, errors.Newf("unclosed character class in glob %q", pattern)
+			}
+			class := glob[i+1 : i+end]
+			if negated, ok := strings.CutPrefix(class, "!"); ok {
+				class = "^" + negated
+			}
+			b.WriteString("[" + class + "]")
+			i += end
+		case c == '\\' && i+1 < len(glob):
+			b.WriteString(regexp.QuoteMeta(glob[i+1 : i+2]))
+			i++
+		default:
+			b.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		}
+	}
+	b.WriteString("(/.*)?$")
+
+	res, err := regexp.Compile(b.String())
+	return res, errors.Wrapf(err, "compile glob %q", pattern)
+}
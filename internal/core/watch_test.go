@@ -0,0 +1,159 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/shoenig/test"
+)
+
+func TestCompileWatchPattern(t *testing.T) {
+	t.Parallel()
+
+	for pattern, tc := range map[string]struct {
+		matches    []string
+		notMatches []string
+	}{
+		"*.go": {
+			matches:    []string{"main.go", "internal/core/watch.go"},
+			notMatches: []string{"main.gox", "go.mod"},
+		},
+		"/build": {
+			matches:    []string{"build", "build/out.bin"},
+			notMatches: []string{"src/build", "builds"},
+		},
+		"node_modules/": {
+			matches:    []string{"node_modules", "web/node_modules/react/index.js"},
+			notMatches: []string{"node_modules2"},
+		},
+		"src/**/*.ts": {
+			matches:    []string{"src/main.ts", "src/a/b/c.ts"},
+			notMatches: []string{"lib/src/main.ts", "src/main.tsx"},
+		},
+		"docs/**": {
+			matches:    []string{"docs/readme.md", "docs/a/b"},
+			notMatches: []string{"doc/readme.md"},
+		},
+		"file?.txt": {
+			matches:    []string{"file1.txt", "a/fileX.txt"},
+			notMatches: []string{"file.txt", "file12.txt"},
+		},
+		"[!a]*.log": {
+			matches:    []string{"b.log", "dir/xyz.log"},
+			notMatches: []string{"a.log", "abc.log"},
+		},
+		"[ab].txt": {
+			matches:    []string{"a.txt", "b.txt"},
+			notMatches: []string{"c.txt"},
+		},
+		`\*.txt`: {
+			matches:    []string{"*.txt"},
+			notMatches: []string{"a.txt"},
+		},
+		"a.b": {
+			matches:    []string{"a.b"},
+			notMatches: []string{"axb"},
+		},
+		`re:\.go$`: {
+			matches:    []string{"main.go", "a/b.go"},
+			notMatches: []string{"main.gox"},
+		},
+	} {
+		re, err := CompileWatchPattern(pattern)
+		test.NoError(t, err, test.Sprint(pattern))
+		for _, path := range tc.matches {
+			test.True(t, re.MatchString(path), test.Sprintf("%q must match %q", pattern, path))
+		}
+		for _, path := range tc.notMatches {
+			test.False(t, re.MatchString(path), test.Sprintf("%q must not match %q", pattern, path))
+		}
+	}
+}
+
+func TestCompileWatchPatternErrors(t *testing.T) {
+	t.Parallel()
+
+	for _, pattern := range []string{
+		"",
+		"/",
+		"[abc",
+		"re:(",
+	} {
+		_, err := CompileWatchPattern(pattern)
+		test.Error(t, err, test.Sprint(pattern))
+	}
+}
+
+// writeFiles under dir, creating parent directories
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+
+	for name, data := range files {
+		filename := filepath.Join(dir, name)
+		test.NoError(t, os.MkdirAll(filepath.Dir(filename), 0o755))
+		test.NoError(t, os.WriteFile(filename, []byte(data), 0o644))
+	}
+}
+
+func TestGitignore(t *testing.T) {
+	t.Parallel()
+
+	toplevel := t.TempDir()
+	writeFiles(t, toplevel, map[string]string{
+		".git/info/exclude": "*.swp\n",
+		".gitignore": "# comment\n" +
+			"*.log\n" +
+			"!keep.log\n" +
+			"/build/\n" +
+			"!build/keep\n" +
+			"**/tmp/**\n" +
+			"vendor/\n",
+		"web/.gitignore": "dist\n" +
+			"!important.log\n" +
+			"*.gen.ts\n",
+		"web/nested/.gitignore": "!*.gen.ts\n",
+	})
+
+	gi := NewGitignore(toplevel)
+	for path, ignored := range map[string]bool{
+		".":                       false,
+		"main.go":                 false,
+		"main.go.swp":             true,
+		"server.log":              true,
+		"logs/server.log":         true,
+		"keep.log":                false,
+		"logs/keep.log":           false,
+		"build":                   true,
+		"build/out":               true,
+		"build/keep":              true, // file in excluded directory can't be re-included
+		"src/build":               false,
+		"tmp/a":                   true,
+		"a/b/tmp/c":               true,
+		"vendor/lib/lib.go":       true,
+		"web/dist/index.js":       true,
+		"web/src/dist":            true,
+		"web/important.log":       false,
+		"web/other.log":           true,
+		"web/api.gen.ts":          true,
+		"web/nested/api.gen.ts":   false,
+		"web/nested/dist/main.js": true,
+		"../outside.log":          false,
+	} {
+		test.EqOp(t, ignored, gi.Ignored(filepath.Join(toplevel, path)), test.Sprint(path))
+	}
+}
+
+func TestFindGitToplevel(t *testing.T) {
+	t.Parallel()
+
+	toplevel := t.TempDir()
+	writeFiles(t, toplevel, map[string]string{
+		".git/HEAD": "ref: refs/heads/master\n",
+		"a/b/c.txt": "",
+	})
+
+	got, ok := FindGitToplevel(filepath.Join(toplevel, "a", "b"))
+	test.True(t, ok)
+	test.EqOp(t, toplevel, got)
+}
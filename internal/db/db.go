@@ -1,13 +1,18 @@
 package db
 
 import (
+	"cmp"
 	"encoding/json"
+	stdErrors "errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/rprtr258/fun"
+	"github.com/rs/zerolog/log"
 	"github.com/spf13/afero"
 
 	"github.com/rprtr258/pm/internal/core"
@@ -15,12 +20,266 @@ import (
 	"github.com/rprtr258/pm/internal/linuxprocess"
 )
 
+// hookData - db representation of core.Hook
+type hookData struct {
+	Command   string           `json:"command"`
+	Args      []string         `json:"args"`
+	OnFailure core.HookFailure `json:"on_failure"`
+	Timeout   time.Duration    `json:"timeout"`
+}
+
+type hooksData struct {
+	PreStart  *hookData `json:"pre_start,omitempty"`
+	PostStart *hookData `json:"post_start,omitempty"`
+	PreStop   *hookData `json:"pre_stop,omitempty"`
+	PostExit  *hookData `json:"post_exit,omitempty"`
+	OnCrash   *hookData `json:"on_crash,omitempty"`
+}
+
+func mapHookToRepo(hook fun.Option[core.Hook]) *hookData {
+	return fun.OptMap(hook, func(hook core.Hook) hookData {
+		return hookData{
+			Command:   hook.Command,
+			Args:      hook.Args,
+			OnFailure: hook.OnFailure,
+			Timeout:   hook.Timeout,
+		}
+	}).Ptr()
+}
+
+func mapHookFromRepo(hook *hookData) fun.Option[core.Hook] {
+	return fun.OptMap(fun.FromPtr(hook), func(hook hookData) core.Hook {
+		return core.Hook{
+			Command:   hook.Command,
+			Args:      hook.Args,
+			OnFailure: hook.OnFailure,
+			Timeout:   hook.Timeout,
+		}
+	})
+}
+
+func mapHooksToRepo(hooks core.Hooks) hooksData {
+	return hooksData{
+		PreStart:  mapHookToRepo(hooks.PreStart),
+		PostStart: mapHookToRepo(hooks.PostStart),
+		PreStop:   mapHookToRepo(hooks.PreStop),
+		PostExit:  mapHookToRepo(hooks.PostExit),
+		OnCrash:   mapHookToRepo(hooks.OnCrash),
+	}
+}
+
+func mapHooksFromRepo(hooks hooksData) core.Hooks {
+	return core.Hooks{
+		PreStart:  mapHookFromRepo(hooks.PreStart),
+		PostStart: mapHookFromRepo(hooks.PostStart),
+		PreStop:   mapHookFromRepo(hooks.PreStop),
+		PostExit:  mapHookFromRepo(hooks.PostExit),
+		OnCrash:   mapHookFromRepo(hooks.OnCrash),
+	}
+}
+
+// notifyData - db representation of core.Notify
+type notifyData struct {
+	Webhook   *string       `json:"webhook,omitempty"`
+	Command   []string      `json:"command,omitempty"`
+	Socket    *string       `json:"socket,omitempty"`
+	RateLimit time.Duration `json:"rate_limit"`
+}
+
+func mapNotifyToRepo(notify fun.Option[core.Notify]) *notifyData {
+	return fun.OptMap(notify, func(notify core.Notify) notifyData {
+		return notifyData{
+			Webhook:   notify.Webhook.Ptr(),
+			Command:   notify.Command,
+			Socket:    notify.Socket.Ptr(),
+			RateLimit: notify.RateLimit,
+		}
+	}).Ptr()
+}
+
+func mapNotifyFromRepo(notify *notifyData) fun.Option[core.Notify] {
+	return fun.OptMap(fun.FromPtr(notify), func(notify notifyData) core.Notify {
+		return core.Notify{
+			Webhook:   fun.FromPtr(notify.Webhook),
+			Command:   notify.Command,
+			Socket:    fun.FromPtr(notify.Socket),
+			RateLimit: notify.RateLimit,
+		}
+	})
+}
+
+// stopStepData - db representation of core.StopStep
+type stopStepData struct {
+	Signal  syscall.Signal `json:"signal"`
+	Timeout time.Duration  `json:"timeout"`
+}
+
+func mapStopSequenceToRepo(steps []core.StopStep) []stopStepData {
+	return fun.Map[stopStepData](func(step core.StopStep) stopStepData {
+		return stopStepData{
+			Signal:  step.Signal,
+			Timeout: step.Timeout,
+		}
+	}, steps...)
+}
+
+func mapStopSequenceFromRepo(steps []stopStepData) []core.StopStep {
+	return fun.Map[core.StopStep](func(step stopStepData) core.StopStep {
+		return core.StopStep{
+			Signal:  step.Signal,
+			Timeout: step.Timeout,
+		}
+	}, steps...)
+}
+
+// reloadData - db representation of core.Reload
+type reloadData struct {
+	Strategy     core.ReloadStrategy `json:"strategy"`
+	Signal       syscall.Signal      `json:"signal"`
+	Notify       bool                `json:"notify"`
+	ReadyDelay   time.Duration       `json:"ready_delay"`
+	ReadyTimeout time.Duration       `json:"ready_timeout"`
+}
+
+func mapReloadToRepo(reload fun.Option[core.Reload]) *reloadData {
+	return fun.OptMap(reload, func(reload core.Reload) reloadData {
+		return reloadData{
+			Strategy:     reload.Strategy,
+			Signal:       reload.Signal,
+			Notify:       reload.Notify,
+			ReadyDelay:   reload.ReadyDelay,
+			ReadyTimeout: reload.ReadyTimeout,
+		}
+	}).Ptr()
+}
+
+func mapReloadFromRepo(reload *reloadData) fun.Option[core.Reload] {
+	return fun.OptMap(fun.FromPtr(reload), func(reload reloadData) core.Reload {
+		return core.Reload{
+			Strategy:     reload.Strategy,
+			Signal:       reload.Signal,
+			Notify:       reload.Notify,
+			ReadyDelay:   reload.ReadyDelay,
+			ReadyTimeout: reload.ReadyTimeout,
+		}
+	})
+}
+
+// watchData - db representation of core.Watch
+type watchData struct {
+	Paths        []string       `json:"paths,omitempty"`
+	Include      []string       `json:"include,omitempty"`
+	Exclude      []string       `json:"exclude,omitempty"`
+	Gitignore    bool           `json:"gitignore"`
+	Debounce     time.Duration  `json:"debounce"`
+	Mode         core.WatchMode `json:"mode,omitempty"`
+	PollInterval time.Duration  `json:"poll_interval,omitempty"`
+}
+
+func mapWatchToRepo(watch core.Watch) watchData {
+	return watchData{
+		Paths:        watch.Paths,
+		Include:      watch.Include,
+		Exclude:      watch.Exclude,
+		Gitignore:    watch.Gitignore,
+		Debounce:     watch.Debounce,
+		Mode:         watch.Mode,
+		PollInterval: watch.PollInterval,
+	}
+}
+
+func mapWatchFromRepo(watch watchData) core.Watch {
+	return core.Watch{
+		Paths:        watch.Paths,
+		Include:      watch.Include,
+		Exclude:      watch.Exclude,
+		Gitignore:    watch.Gitignore,
+		Debounce:     watch.Debounce,
+		Mode:         cmp.Or(watch.Mode, core.WatchModeAuto),
+		PollInterval: cmp.Or(watch.PollInterval, time.Second),
+	}
+}
+
+// cronData - db representation of core.Cron
+type cronData struct {
+	Schedule    string               `json:"schedule,omitempty"`
+	Every       time.Duration        `json:"every,omitempty"`
+	At          time.Time            `json:"at,omitzero"`
+	AtDelay     time.Duration        `json:"at_delay,omitempty"`
+	Timezone    string               `json:"timezone,omitempty"`
+	Concurrency core.CronConcurrency `json:"concurrency,omitempty"`
+	CatchUp     bool                 `json:"catch_up,omitempty"`
+	Timeout     time.Duration        `json:"timeout,omitempty"`
+	RunOnStart  bool                 `json:"run_on_start,omitempty"`
+}
+
+func mapCronToRepo(cron core.Cron) cronData {
+	return cronData{
+		Schedule:    cron.Schedule,
+		Every:       cron.Every,
+		At:          cron.At,
+		AtDelay:     cron.AtDelay,
+		Timezone:    cron.Timezone,
+		Concurrency: cron.Concurrency,
+		CatchUp:     cron.CatchUp,
+		Timeout:     cron.Timeout,
+		RunOnStart:  cron.RunOnStart,
+	}
+}
+
+func mapCronFromRepo(cron cronData) core.Cron {
+	return core.Cron{
+		Schedule:    cron.Schedule,
+		Every:       cron.Every,
+		At:          cron.At,
+		AtDelay:     cron.AtDelay,
+		Timezone:    cron.Timezone,
+		Concurrency: cmp.Or(cron.Concurrency, core.CronConcurrencyForbid),
+		CatchUp:     cron.CatchUp,
+		Timeout:     cron.Timeout,
+		RunOnStart:  cron.RunOnStart,
+	}
+}
+
+// portData - db representation of core.Port
+type portData struct {
+	Name string `json:"name"`
+	Port int    `json:"port"`
+}
+
+func mapPortsToRepo(ports []core.Port) []portData {
+	return fun.Map[portData](func(port core.Port) portData {
+		return portData{
+			Name: port.Name,
+			Port: port.Port,
+		}
+	}, ports...)
+}
+
+func mapPortsFromRepo(ports []portData) []core.Port {
+	return fun.Map[core.Port](func(port portData) core.Port {
+		return core.Port{
+			Name: port.Name,
+			Port: port.Port,
+		}
+	}, ports...)
+}
+
 // procData - db representation of core.ProcData
 type procData struct {
+	// SchemaVersion - version of record format, see SchemaVersion
+	SchemaVersion int `json:"schema_version"`
+
 	ProcID core.PMID `json:"id"`
 	Name   string    `json:"name"`
 	Tags   []string  `json:"tags"`
 
+	Group    string `json:"group,omitempty"`
+	Instance int    `json:"instance,omitempty"`
+
+	Profile    string `json:"profile,omitempty"`
+	ConfigFile string `json:"config_file,omitempty"`
+
 	// Command - executable to run
 	Command string `json:"command"`
 	// Args - arguments for executable,
@@ -32,13 +291,29 @@ type procData struct {
 	StdoutFile string            `json:"stdout_file"`
 	StderrFile string            `json:"stderr_file"`
 
-	Watch *string `json:"watch"`
+	Watch *watchData `json:"watch"`
+	Build *hookData  `json:"build,omitempty"`
 
 	Startup     bool          `json:"startup"`
 	KillTimeout time.Duration `json:"kill_timeout"`
 	DependsOn   []string      `json:"depends_on"`
 	MaxRestarts uint          `json:"max_restarts"`
-	Cron        *string       `json:"cron"`
+	Cron        *cronData     `json:"cron"`
+	StartDelay  time.Duration `json:"start_delay,omitempty"`
+	Hooks       hooksData     `json:"hooks"`
+	Notify      *notifyData   `json:"notify"`
+
+	StopSignal   *syscall.Signal `json:"stop_signal"`
+	StopCommand  *hookData       `json:"stop_command"`
+	StopSequence []stopStepData  `json:"stop_sequence"`
+
+	Listen []string    `json:"listen"`
+	Reload *reloadData `json:"reload"`
+
+	Lazy        bool          `json:"lazy"`
+	IdleTimeout time.Duration `json:"idle_timeout"`
+
+	Ports []portData `json:"ports,omitempty"`
 }
 
 func (p procData) ID() string {
@@ -48,12 +323,17 @@ func (p procData) ID() string {
 func mapFromRepo(proc procData) core.Proc {
 	return core.Proc{
 		ID:          proc.ProcID,
+		Group:       proc.Group,
+		Instance:    proc.Instance,
+		Profile:     proc.Profile,
+		ConfigFile:  proc.ConfigFile,
 		Command:     proc.Command,
 		Cwd:         proc.Cwd,
 		Name:        proc.Name,
 		Args:        proc.Args,
 		Tags:        proc.Tags,
-		Watch:       fun.FromPtr(proc.Watch),
+		Watch:       fun.OptMap(fun.FromPtr(proc.Watch), mapWatchFromRepo),
+		Build:       mapHookFromRepo(proc.Build),
 		Env:         proc.Env,
 		StdoutFile:  proc.StdoutFile,
 		StderrFile:  proc.StderrFile,
@@ -61,7 +341,22 @@ func mapFromRepo(proc procData) core.Proc {
 		KillTimeout: proc.KillTimeout,
 		DependsOn:   proc.DependsOn,
 		MaxRestarts: proc.MaxRestarts,
-		Cron:        fun.FromPtr(proc.Cron),
+		Cron:        fun.OptMap(fun.FromPtr(proc.Cron), mapCronFromRepo),
+		StartDelay:  proc.StartDelay,
+		Hooks:       mapHooksFromRepo(proc.Hooks),
+		Notify:      mapNotifyFromRepo(proc.Notify),
+
+		StopSignal:   fun.FromPtr(proc.StopSignal),
+		StopCommand:  mapHookFromRepo(proc.StopCommand),
+		StopSequence: mapStopSequenceFromRepo(proc.StopSequence),
+
+		Listen: proc.Listen,
+		Reload: mapReloadFromRepo(proc.Reload),
+
+		Lazy:        proc.Lazy,
+		IdleTimeout: proc.IdleTimeout,
+
+		Ports: mapPortsFromRepo(proc.Ports),
 	}
 }
 
@@ -98,6 +393,12 @@ type CreateQuery struct {
 	Name string   // Name of the process
 	Tags []string // Tags - process tags
 
+	Group    string // Group - name of replicas group, empty if process is not replicated
+	Instance int    // Instance - index of replica in group
+
+	Profile    string // Profile - config profile process is run with
+	ConfigFile string // ConfigFile - config file process is run from
+
 	Command    string            // Command - executable to run
 	Args       []string          // Args - arguments for executable, not including executable itself as first argument
 	Cwd        string            // Cwd - working directory
@@ -105,51 +406,166 @@ type CreateQuery struct {
 	StdoutFile fun.Option[string]
 	StderrFile fun.Option[string]
 
-	Watch fun.Option[string] // Watch - regex pattern for file watching
+	Watch fun.Option[core.Watch] // Watch - files to watch for changes
+	Build fun.Option[core.Hook]  // Build - command to run before restart on changes
 
 	Startup     bool // Startup - should process be started on startup
 	KillTimeout time.Duration
 	DependsOn   []string
 	MaxRestarts uint
-	Cron        fun.Option[string]
+	Cron        fun.Option[core.Cron]
+	StartDelay  time.Duration
+	Hooks       core.Hooks
+	Notify      fun.Option[core.Notify]
+
+	StopSignal   fun.Option[syscall.Signal]
+	StopCommand  fun.Option[core.Hook]
+	StopSequence []core.StopStep
+
+	Listen []string
+	Reload fun.Option[core.Reload]
+
+	Lazy        bool
+	IdleTimeout time.Duration
+
+	Ports []core.Port
+}
+
+// _lockFile is advisory lock file serializing read-modify-write sequences
+const _lockFile = ".lock"
+
+// _quarantineDir is directory unreadable records are moved to
+const _quarantineDir = ".quarantine"
+
+// isRecord reports whether db directory entry is process record, not lock,
+// temporary file or quarantine directory
+func isRecord(name string) bool {
+	return !strings.HasPrefix(name, ".")
+}
+
+// Lock db for read-modify-write sequence, like checking that name is not
+// taken and adding process. Lock is advisory and is not reentrant, it is held
+// until returned unlock func is called.
+func (h Handle) Lock() (func(), error) {
+	basePathFs, ok := h.dir.(*afero.BasePathFs)
+	if !ok {
+		// not a real directory, no other processes can access it
+		return func() {}, nil
+	}
+
+	filename, err := basePathFs.RealPath(_lockFile)
+	if err != nil {
+		return nil, errors.Wrapf(err, "get lock file path")
+	}
+
+	f, err := os.OpenFile(filename, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, errors.Wrapf(err, "open lock file")
+	}
+
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+		_ = f.Close()
+		return nil, errors.Wrapf(err, "lock %s", filename)
+	}
+
+	return func() {
+		if err := syscall.Flock(int(f.Fd()), syscall.LOCK_UN); err != nil {
+			log.Error().Err(err).Str("file", filename).Msg("unlock db")
+		}
+		_ = f.Close()
+	}, nil
 }
 
+// writeProc atomically: record is written to temporary file, which then
+// replaces old record, so that record is never left half-written
 func (h Handle) writeProc(proc procData) error {
-	f, err := h.dir.OpenFile(proc.ProcID.String(), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	proc.SchemaVersion = SchemaVersion
+	b, err := json.Marshal(proc)
 	if err != nil {
-		return err
+		return errors.Wrapf(err, "marshal proc")
+	}
+
+	tmp := fmt.Sprintf(".%s.%d.tmp", proc.ProcID, os.Getpid())
+	if err := func() error {
+		f, err := h.dir.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		if _, err := f.Write(append(b, '\n')); err != nil {
+			return err
+		}
+		return f.Sync()
+	}(); err != nil {
+		_ = h.dir.Remove(tmp)
+		return errors.Wrapf(err, "write %s", tmp)
 	}
-	defer f.Close()
 
-	return json.NewEncoder(f).Encode(proc)
+	if err := h.dir.Rename(tmp, proc.ProcID.String()); err != nil {
+		_ = h.dir.Remove(tmp)
+		return errors.Wrapf(err, "rename %s", tmp)
+	}
+
+	// make rename durable
+	if dir, err := h.dir.Open("."); err == nil {
+		_ = dir.Sync()
+		_ = dir.Close()
+	}
+
+	return nil
 }
 
+// readProc record, migrating it to current schema version in memory
 func (h Handle) readProc(id core.PMID) (procData, error) {
-	f, err := h.dir.Open(id.String())
+	rec, err := h.readRecord(id)
 	if err != nil {
 		return procData{}, err
 	}
-	defer f.Close()
+
+	if err := rec.migrate(); err != nil {
+		return procData{}, errors.Wrapf(err, "record %s", id)
+	}
+
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return procData{}, errors.Wrapf(err, "marshal record %s", id)
+	}
 
 	var proc procData
-	if err := json.NewDecoder(f).Decode(&proc); err != nil {
-		return procData{}, err
+	if err := json.Unmarshal(b, &proc); err != nil {
+		return procData{}, CorruptRecordError{id, err}
 	}
 
 	return proc, nil
 }
 
+// quarantine unreadable record, so that it does not break listing and can
+// be inspected or fixed by hand
+func (h Handle) quarantine(id core.PMID) error {
+	if err := h.dir.MkdirAll(_quarantineDir, 0o755); err != nil {
+		return errors.Wrapf(err, "create quarantine directory")
+	}
+
+	return errors.Wrapf(h.dir.Rename(id.String(), filepath.Join(_quarantineDir, id.String())), "move record")
+}
+
 func (h Handle) AddProc(query CreateQuery, logsDir string) (core.PMID, error) {
 	id := core.GenPMID()
 	if err := h.writeProc(procData{
-		ProcID:  id,
-		Command: query.Command,
-		Cwd:     query.Cwd,
-		Name:    query.Name,
-		Args:    query.Args,
-		Tags:    query.Tags,
-		Watch:   query.Watch.Ptr(),
-		Env:     query.Env,
+		ProcID:     id,
+		Group:      query.Group,
+		Instance:   query.Instance,
+		Profile:    query.Profile,
+		ConfigFile: query.ConfigFile,
+		Command:    query.Command,
+		Cwd:        query.Cwd,
+		Name:       query.Name,
+		Args:       query.Args,
+		Tags:       query.Tags,
+		Watch:      fun.OptMap(query.Watch, mapWatchToRepo).Ptr(),
+		Build:      mapHookToRepo(query.Build),
+		Env:        query.Env,
 		StdoutFile: query.StdoutFile.
 			OrDefault(filepath.Join(logsDir, fmt.Sprintf("%s.stdout", id))),
 		StderrFile: query.StderrFile.
@@ -158,22 +574,42 @@ func (h Handle) AddProc(query CreateQuery, logsDir string) (core.PMID, error) {
 		KillTimeout: query.KillTimeout,
 		DependsOn:   query.DependsOn,
 		MaxRestarts: query.MaxRestarts,
-		Cron:        query.Cron.Ptr(),
+		Cron:        fun.OptMap(query.Cron, mapCronToRepo).Ptr(),
+		StartDelay:  query.StartDelay,
+		Hooks:       mapHooksToRepo(query.Hooks),
+		Notify:      mapNotifyToRepo(query.Notify),
+
+		StopSignal:   query.StopSignal.Ptr(),
+		StopCommand:  mapHookToRepo(query.StopCommand),
+		StopSequence: mapStopSequenceToRepo(query.StopSequence),
+
+		Listen: query.Listen,
+		Reload: mapReloadToRepo(query.Reload),
+
+		Lazy:        query.Lazy,
+		IdleTimeout: query.IdleTimeout,
+
+		Ports: mapPortsToRepo(query.Ports),
 	}); err != nil {
 		return "", err
 	}
 	return id, nil
 }
 
-func (h Handle) UpdateProc(proc core.Proc) error {
-	if err := h.writeProc(procData{
+func mapToRepo(proc core.Proc) procData {
+	return procData{
 		ProcID:      proc.ID,
+		Group:       proc.Group,
+		Instance:    proc.Instance,
+		Profile:     proc.Profile,
+		ConfigFile:  proc.ConfigFile,
 		Command:     proc.Command,
 		Cwd:         proc.Cwd,
 		Name:        proc.Name,
 		Args:        proc.Args,
 		Tags:        proc.Tags,
-		Watch:       proc.Watch.Ptr(),
+		Watch:       fun.OptMap(proc.Watch, mapWatchToRepo).Ptr(),
+		Build:       mapHookToRepo(proc.Build),
 		Env:         proc.Env,
 		StdoutFile:  proc.StdoutFile,
 		StderrFile:  proc.StderrFile,
@@ -181,14 +617,49 @@ func (h Handle) UpdateProc(proc core.Proc) error {
 		KillTimeout: proc.KillTimeout,
 		DependsOn:   proc.DependsOn,
 		MaxRestarts: proc.MaxRestarts,
-		Cron:        proc.Cron.Ptr(),
-	}); err != nil {
+		Cron:        fun.OptMap(proc.Cron, mapCronToRepo).Ptr(),
+		StartDelay:  proc.StartDelay,
+		Hooks:       mapHooksToRepo(proc.Hooks),
+		Notify:      mapNotifyToRepo(proc.Notify),
+
+		StopSignal:   proc.StopSignal.Ptr(),
+		StopCommand:  mapHookToRepo(proc.StopCommand),
+		StopSequence: mapStopSequenceToRepo(proc.StopSequence),
+
+		Listen: proc.Listen,
+		Reload: mapReloadToRepo(proc.Reload),
+
+		Lazy:        proc.Lazy,
+		IdleTimeout: proc.IdleTimeout,
+
+		Ports: mapPortsToRepo(proc.Ports),
+	}
+}
+
+func (h Handle) UpdateProc(proc core.Proc) error {
+	if err := h.writeProc(mapToRepo(proc)); err != nil {
 		return FlushError{err}
 	}
 
 	return nil
 }
 
+// AddReplica of process as given instance of its group, replica has its own
+// id and log files
+func (h Handle) AddReplica(proc core.Proc, instance int, logsDir string) (core.PMID, error) {
+	id := core.GenPMID()
+	replica := mapToRepo(proc)
+	replica.ProcID = id
+	replica.Name = core.InstanceName(proc.Group, instance)
+	replica.Instance = instance
+	replica.StdoutFile = filepath.Join(logsDir, fmt.Sprintf("%s.stdout", id))
+	replica.StderrFile = filepath.Join(logsDir, fmt.Sprintf("%s.stderr", id))
+	if err := h.writeProc(replica); err != nil {
+		return "", FlushError{err}
+	}
+	return id, nil
+}
+
 func (h Handle) GetProc(id core.PMID) (core.Proc, bool) {
 	proc, err := h.readProc(id)
 	if err != nil {
@@ -198,6 +669,28 @@ func (h Handle) GetProc(id core.PMID) (core.Proc, bool) {
 	return mapFromRepo(proc), true
 }
 
+// IDs of all process records, including ones which cannot be read and ones
+// moved to quarantine
+func (h Handle) IDs() ([]core.PMID, error) {
+	entries, err := afero.ReadDir(h.dir, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	quarantined, err := afero.ReadDir(h.dir, _quarantineDir)
+	if err != nil && !stdErrors.Is(err, os.ErrNotExist) {
+		return nil, errors.Wrapf(err, "read quarantine dir")
+	}
+
+	ids := []core.PMID{}
+	for _, entry := range append(entries, quarantined...) {
+		if isRecord(entry.Name()) {
+			ids = append(ids, core.PMID(entry.Name()))
+		}
+	}
+	return ids, nil
+}
+
 func (h Handle) List(filterOpts ...core.FilterOption) (map[core.PMID]core.Proc, error) {
 	entries, err := afero.ReadDir(h.dir, ".")
 	if err != nil {
@@ -206,9 +699,30 @@ func (h Handle) List(filterOpts ...core.FilterOption) (map[core.PMID]core.Proc,
 
 	procs := map[core.PMID]core.Proc{}
 	for _, entry := range entries {
-		proc, err := h.readProc(core.PMID(entry.Name()))
+		if !isRecord(entry.Name()) {
+			continue
+		}
+
+		id := core.PMID(entry.Name())
+		proc, err := h.readProc(id)
 		if err != nil {
-			return nil, err
+			var errCorrupt CorruptRecordError
+			switch {
+			case os.IsNotExist(err):
+				// deleted concurrently
+			case stdErrors.As(err, &errCorrupt):
+				log.Warn().
+					Err(err).
+					Str("id", id.String()).
+					Str("dir", _quarantineDir).
+					Msg("moving unreadable process record to quarantine")
+				if errQuarantine := h.quarantine(id); errQuarantine != nil {
+					log.Error().Err(errQuarantine).Str("id", id.String()).Msg("quarantine record")
+				}
+			default:
+				log.Error().Err(err).Str("id", id.String()).Msg("read process record, skipping")
+			}
+			continue
 		}
 
 		procs[proc.ProcID] = mapFromRepo(proc)
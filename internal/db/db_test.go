@@ -0,0 +1,182 @@
+package db
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/rprtr258/fun"
+	"github.com/shoenig/test"
+	"github.com/spf13/afero"
+
+	"github.com/rprtr258/pm/internal/core"
+)
+
+func TestQuarantineKeepsID(t *testing.T) {
+	t.Parallel()
+
+	fs := afero.NewMemMapFs()
+	h := New(fs)
+
+	id := core.GenPMID()
+	writeRecord(t, fs, id, map[string]any{
+		"id":      id,
+		"name":    "ok",
+		"command": "/bin/true",
+	})
+	corruptID := core.GenPMID()
+	test.NoError(t, afero.WriteFile(fs, corruptID.String(), []byte(`{"id": `), 0o644))
+
+	procs, err := h.List(core.WithAllIfNoFilters)
+	test.NoError(t, err)
+	test.EqOp(t, 1, len(procs))
+
+	exists, err := afero.Exists(fs, filepath.Join(_quarantineDir, corruptID.String()))
+	test.NoError(t, err)
+	test.True(t, exists)
+
+	// logs of quarantined records must not be pruned
+	ids, err := h.IDs()
+	test.NoError(t, err)
+	slices.Sort(ids)
+	want := []core.PMID{id, corruptID}
+	slices.Sort(want)
+	test.Eq(t, want, ids)
+}
+
+func TestAddReplica(t *testing.T) {
+	t.Parallel()
+
+	h := New(afero.NewMemMapFs())
+
+	var query CreateQuery
+	query.Name = core.InstanceName("web", 0)
+	query.Group = "web"
+	query.Instance = 0
+	query.Command = "./web"
+	query.Args = []string{"--port", "8080"}
+	query.Tags = []string{"frontend"}
+	query.StdoutFile = fun.Valid("/var/log/web.log")
+	id, err := h.AddProc(query, "/logs")
+	test.NoError(t, err)
+
+	proc, ok := h.GetProc(id)
+	test.True(t, ok)
+
+	replicaID, err := h.AddReplica(proc, 1, "/logs")
+	test.NoError(t, err)
+	test.NotEq(t, id, replicaID)
+
+	replica, ok := h.GetProc(replicaID)
+	test.True(t, ok)
+
+	// replica has its own name, index and log files, rest is copied
+	want := proc
+	want.ID = replicaID
+	want.Name = "web#1"
+	want.Instance = 1
+	want.StdoutFile = filepath.Join("/logs", replicaID.String()+".stdout")
+	want.StderrFile = filepath.Join("/logs", replicaID.String()+".stderr")
+	test.Eq(t, want, replica)
+
+	// original is left as is
+	original, ok := h.GetProc(id)
+	test.True(t, ok)
+	test.Eq(t, proc, original)
+	test.EqOp(t, "/var/log/web.log", original.StdoutFile)
+}
+
+func TestWriteProcAtomic(t *testing.T) {
+	t.Parallel()
+
+	fs, err := InitRealDir(filepath.Join(t.TempDir(), "db"))
+	test.NoError(t, err)
+	h := New(fs)
+
+	var query CreateQuery
+	query.Name = "app"
+	query.Command = "./app"
+	id, err := h.AddProc(query, "/logs")
+	test.NoError(t, err)
+
+	proc, ok := h.GetProc(id)
+	test.True(t, ok)
+	proc.Args = []string{"--updated"}
+	test.NoError(t, h.UpdateProc(proc))
+
+	// no temporary files are left
+	entries, err := afero.ReadDir(fs, ".")
+	test.NoError(t, err)
+	test.Eq(t, []string{id.String()}, fun.Map[string](func(entry os.FileInfo) string {
+		return entry.Name()
+	}, entries...))
+
+	got, ok := h.GetProc(id)
+	test.True(t, ok)
+	test.Eq(t, proc, got)
+
+	// temporary files left by crashed writers and lock file are not records
+	test.NoError(t, afero.WriteFile(fs, "."+id.String()+".1234.tmp", []byte(`{"id": `), 0o644))
+	unlock, err := h.Lock()
+	test.NoError(t, err)
+	unlock()
+
+	procs, err := h.List(core.WithAllIfNoFilters)
+	test.NoError(t, err)
+	test.Eq(t, map[core.PMID]core.Proc{id: proc}, procs)
+
+	exists, err := afero.DirExists(fs, _quarantineDir)
+	test.NoError(t, err)
+	test.False(t, exists)
+}
+
+func TestLock(t *testing.T) {
+	t.Parallel()
+
+	dir := filepath.Join(t.TempDir(), "db")
+	fs1, err := InitRealDir(dir)
+	test.NoError(t, err)
+	fs2, err := InitRealDir(dir)
+	test.NoError(t, err)
+
+	unlock, err := New(fs1).Lock()
+	test.NoError(t, err)
+
+	// other handle, like other pm process, waits for lock
+	locked := make(chan struct{})
+	go func() {
+		unlock, err := New(fs2).Lock()
+		test.NoError(t, err)
+		close(locked)
+		unlock()
+	}()
+
+	select {
+	case <-locked:
+		t.Fatal("lock is taken twice")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	unlock()
+
+	select {
+	case <-locked:
+	case <-time.After(5 * time.Second):
+		t.Fatal("lock is not released")
+	}
+}
+
+func TestLockInMemory(t *testing.T) {
+	t.Parallel()
+
+	// in-memory db is not shared with other processes, so it is not locked
+	h := New(afero.NewMemMapFs())
+	unlock1, err := h.Lock()
+	test.NoError(t, err)
+	unlock2, err := h.Lock()
+	test.NoError(t, err)
+	unlock2()
+	unlock1()
+}
@@ -17,3 +17,17 @@ type FlushError struct{ Err error }
 func (err FlushError) Error() string {
 	return fmt.Sprintf("db flush: %s", err.Err.Error())
 }
+
+// CorruptRecordError is returned when process record cannot be decoded
+type CorruptRecordError struct {
+	ProcID core.PMID
+	Err    error
+}
+
+func (err CorruptRecordError) Error() string {
+	return fmt.Sprintf("corrupt record of proc #%s: %s", err.ProcID, err.Err.Error())
+}
+
+func (err CorruptRecordError) Unwrap() error {
+	return err.Err
+}
@@ -0,0 +1,183 @@
+package db
+
+import (
+	"encoding/json"
+	"path/filepath"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/afero"
+
+	"github.com/rprtr258/pm/internal/core"
+	"github.com/rprtr258/pm/internal/errors"
+)
+
+// SchemaVersion is version of process record format, it is increased on
+// incompatible changes and old records are upgraded by _migrations
+const SchemaVersion = 1
+
+// record is raw process record, migrations work on it
+type record map[string]json.RawMessage
+
+// _migrations[i] upgrades record from schema version i to i+1
+var _migrations = []func(record) error{
+	// 0 -> 1: legacy cron expression and watch regex strings become objects
+	func(rec record) error {
+		if cron, ok := rec.string("cron"); ok {
+			b, err := json.Marshal(mapCronToRepo(core.CronSchedule(cron)))
+			if err != nil {
+				return errors.Wrapf(err, "marshal cron")
+			}
+			rec["cron"] = b
+		}
+
+		if watch, ok := rec.string("watch"); ok {
+			b, err := json.Marshal(mapWatchToRepo(core.WatchRegex(watch)))
+			if err != nil {
+				return errors.Wrapf(err, "marshal watch")
+			}
+			rec["watch"] = b
+		}
+
+		return nil
+	},
+}
+
+// string field value, if field is string
+func (rec record) string(key string) (string, bool) {
+	var value any
+	if err := json.Unmarshal(rec[key], &value); err != nil {
+		return "", false
+	}
+
+	s, ok := value.(string)
+	return s, ok
+}
+
+func (rec record) schemaVersion() (int, error) {
+	raw, ok := rec["schema_version"]
+	if !ok {
+		return 0, nil
+	}
+
+	var version int
+	err := json.Unmarshal(raw, &version)
+	return version, errors.Wrapf(err, "parse schema version")
+}
+
+// migrate record to current schema version
+func (rec record) migrate() error {
+	version, err := rec.schemaVersion()
+	if err != nil {
+		return err
+	}
+
+	if version > SchemaVersion {
+		return errors.Newf("record schema version %d is newer than supported %d, upgrade pm", version, SchemaVersion)
+	}
+
+	for v := version; v < SchemaVersion; v++ {
+		if err := _migrations[v](rec); err != nil {
+			return errors.Wrapf(err, "migrate from schema version %d", v)
+		}
+	}
+
+	rec["schema_version"], _ = json.Marshal(SchemaVersion)
+	return nil
+}
+
+func (h Handle) readRecord(id core.PMID) (record, error) {
+	b, err := afero.ReadFile(h.dir, id.String())
+	if err != nil {
+		return nil, err
+	}
+
+	var rec record
+	if err := json.Unmarshal(b, &rec); err != nil {
+		return nil, CorruptRecordError{id, err}
+	}
+
+	return rec, nil
+}
+
+// backup copies process records to backupDir on backupFs
+func (h Handle) backup(backupFs afero.Fs, backupDir string, ids []core.PMID) error {
+	if err := backupFs.MkdirAll(backupDir, 0o755); err != nil {
+		return errors.Wrapf(err, "create backup dir %q", backupDir)
+	}
+
+	for _, id := range ids {
+		b, err := afero.ReadFile(h.dir, id.String())
+		if err != nil {
+			return errors.Wrapf(err, "read record %s", id)
+		}
+
+		if err := afero.WriteFile(backupFs, filepath.Join(backupDir, id.String()), b, 0o644); err != nil {
+			return errors.Wrapf(err, "backup record %s", id)
+		}
+	}
+
+	return nil
+}
+
+// Migrate records written by older pm versions to current schema version.
+// Records are copied to backupDir on backupFs before being changed. Returns
+// number of migrated records.
+func (h Handle) Migrate(backupFs afero.Fs, backupDir string) (int, error) {
+	unlock, err := h.Lock()
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	entries, err := afero.ReadDir(h.dir, ".")
+	if err != nil {
+		return 0, errors.Wrapf(err, "read db dir")
+	}
+
+	outdated := []core.PMID{}
+	for _, entry := range entries {
+		if !isRecord(entry.Name()) {
+			continue
+		}
+
+		id := core.PMID(entry.Name())
+		rec, err := h.readRecord(id)
+		if err != nil {
+			// unreadable records are handled on listing
+			continue
+		}
+
+		version, err := rec.schemaVersion()
+		if err != nil || version >= SchemaVersion {
+			continue
+		}
+
+		outdated = append(outdated, id)
+	}
+
+	if len(outdated) == 0 {
+		return 0, nil
+	}
+
+	if err := h.backup(backupFs, backupDir, outdated); err != nil {
+		return 0, errors.Wrapf(err, "backup db")
+	}
+
+	for _, id := range outdated {
+		proc, err := h.readProc(id)
+		if err != nil {
+			return 0, errors.Wrapf(err, "read record %s", id)
+		}
+
+		if err := h.writeProc(proc); err != nil {
+			return 0, errors.Wrapf(err, "write record %s", id)
+		}
+	}
+
+	log.Info().
+		Int("records", len(outdated)).
+		Int("schema_version", SchemaVersion).
+		Str("backup", backupDir).
+		Msg("migrated db")
+	return len(outdated), nil
+}
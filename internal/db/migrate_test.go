@@ -0,0 +1,133 @@
+package db
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/rprtr258/fun"
+	"github.com/shoenig/test"
+	"github.com/spf13/afero"
+
+	"github.com/rprtr258/pm/internal/core"
+)
+
+// writeRecord to db dir as is
+func writeRecord(t *testing.T, fs afero.Fs, id core.PMID, rec map[string]any) {
+	t.Helper()
+
+	b, err := json.Marshal(rec)
+	test.NoError(t, err)
+	test.NoError(t, afero.WriteFile(fs, id.String(), b, 0o644))
+}
+
+func TestMigrateLegacyRecords(t *testing.T) {
+	t.Parallel()
+
+	objectCron := mapCronToRepo(core.Cron{
+		Schedule:    "",
+		Every:       90_000_000_000,
+		At:          fun.Zero[core.Cron]().At,
+		AtDelay:     0,
+		Timezone:    "UTC",
+		Concurrency: core.CronConcurrencyReplace,
+		CatchUp:     true,
+		Timeout:     0,
+		RunOnStart:  false,
+	})
+
+	for name, tc := range map[string]struct {
+		cron      any
+		watch     any
+		wantCron  fun.Option[core.Cron]
+		wantWatch fun.Option[core.Watch]
+	}{
+		"legacy strings": {
+			cron:      "*/5 * * * *",
+			watch:     `\.go$`,
+			wantCron:  fun.Valid(core.CronSchedule("*/5 * * * *")),
+			wantWatch: fun.Valid(core.WatchRegex(`\.go$`)),
+		},
+		"empty legacy strings": {
+			cron:      "",
+			watch:     "",
+			wantCron:  fun.Valid(core.CronSchedule("")),
+			wantWatch: fun.Valid(core.WatchRegex("")),
+		},
+		"absent": {
+			cron:      nil,
+			watch:     nil,
+			wantCron:  fun.Invalid[core.Cron](),
+			wantWatch: fun.Invalid[core.Watch](),
+		},
+		"objects": {
+			cron:      objectCron,
+			watch:     mapWatchToRepo(core.WatchRegex("src")),
+			wantCron:  fun.Valid(mapCronFromRepo(objectCron)),
+			wantWatch: fun.Valid(core.WatchRegex("src")),
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			fs := afero.NewMemMapFs()
+			h := New(fs)
+
+			id := core.GenPMID()
+			writeRecord(t, fs, id, map[string]any{
+				"id":      id,
+				"name":    "job",
+				"command": "/bin/true",
+				"cron":    tc.cron,
+				"watch":   tc.watch,
+			})
+
+			backupFs := afero.NewMemMapFs()
+			migrated, err := h.Migrate(backupFs, "backup")
+			test.NoError(t, err)
+			test.EqOp(t, 1, migrated)
+			exists, err := afero.Exists(backupFs, filepath.Join("backup", id.String()))
+			test.NoError(t, err)
+			test.True(t, exists)
+
+			procs, err := h.List(core.WithAllIfNoFilters)
+			test.NoError(t, err)
+			proc := procs[id]
+			test.EqOp(t, "job", proc.Name)
+			test.Eq(t, tc.wantCron, proc.Cron)
+			test.Eq(t, tc.wantWatch, proc.Watch)
+
+			// records are migrated only once
+			migrated, err = h.Migrate(backupFs, "backup")
+			test.NoError(t, err)
+			test.EqOp(t, 0, migrated)
+		})
+	}
+}
+
+func TestMigrateNewerRecord(t *testing.T) {
+	t.Parallel()
+
+	fs := afero.NewMemMapFs()
+	h := New(fs)
+
+	id := core.GenPMID()
+	writeRecord(t, fs, id, map[string]any{
+		"schema_version": SchemaVersion + 1,
+		"id":             id,
+		"name":           "future",
+		"command":        "/bin/true",
+	})
+
+	backupFs := afero.NewMemMapFs()
+	migrated, err := h.Migrate(backupFs, "backup")
+	test.NoError(t, err)
+	test.EqOp(t, 0, migrated)
+
+	// record is kept as is, but is not readable
+	_, err = h.readProc(id)
+	test.Error(t, err)
+	exists, err := afero.Exists(backupFs, "backup")
+	test.NoError(t, err)
+	test.False(t, exists)
+}
@@ -0,0 +1,173 @@
+package db
+
+import (
+	"encoding/json"
+	stdErrors "errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/rprtr258/fun"
+
+	"github.com/rprtr258/pm/internal/core"
+	"github.com/rprtr258/pm/internal/errors"
+)
+
+// DefaultSnapshot is name of snapshot used when none is given
+const DefaultSnapshot = "default"
+
+// Snapshot of set of running processes with their definitions
+type Snapshot struct {
+	Name      string
+	CreatedAt time.Time
+	Procs     []core.Proc
+}
+
+// snapshotData - file representation of Snapshot, processes are stored as
+// db records, so they are migrated the same way
+type snapshotData struct {
+	CreatedAt time.Time `json:"created_at"`
+	Procs     []record  `json:"procs"`
+}
+
+func snapshotFile(dir, name string) string {
+	return filepath.Join(dir, name+".json")
+}
+
+// ValidateSnapshotName so that it can be used as file name
+func ValidateSnapshotName(name string) error {
+	if name == "" || strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, ".") {
+		return errors.Newf("invalid snapshot name %q", name)
+	}
+
+	return nil
+}
+
+// SaveSnapshot to dir, replacing snapshot with the same name
+func SaveSnapshot(dir string, snapshot Snapshot) error {
+	procs, err := fun.MapErr[record](func(proc core.Proc) (record, error) {
+		data := mapToRepo(proc)
+		data.SchemaVersion = SchemaVersion
+
+		b, err := json.Marshal(data)
+		if err != nil {
+			return nil, errors.Wrapf(err, "marshal proc %s", proc.Name)
+		}
+
+		var rec record
+		err = json.Unmarshal(b, &rec)
+		return rec, errors.Wrapf(err, "unmarshal proc %s", proc.Name)
+	}, snapshot.Procs...)
+	if err != nil {
+		return err
+	}
+
+	b, err := json.MarshalIndent(snapshotData{
+		CreatedAt: snapshot.CreatedAt,
+		Procs:     procs,
+	}, "", "  ")
+	if err != nil {
+		return errors.Wrapf(err, "marshal snapshot")
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return errors.Wrapf(err, "create snapshots dir %q", dir)
+	}
+
+	// NOTE: write to temporary file and rename, so that snapshot is never partially written
+	filename := snapshotFile(dir, snapshot.Name)
+	tmp := filename + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return errors.Wrapf(err, "write %s", tmp)
+	}
+	return errors.Wrapf(os.Rename(tmp, filename), "rename %s", tmp)
+}
+
+// LoadSnapshot from dir by name
+func LoadSnapshot(dir, name string) (Snapshot, error) {
+	filename := snapshotFile(dir, name)
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		if stdErrors.Is(err, fs.ErrNotExist) {
+			return Snapshot{}, errors.Newf("snapshot %q not found", name)
+		}
+		return Snapshot{}, errors.Wrapf(err, "read %s", filename)
+	}
+
+	var data snapshotData
+	if err := json.Unmarshal(b, &data); err != nil {
+		return Snapshot{}, errors.Wrapf(err, "unmarshal %s", filename)
+	}
+
+	procs, err := fun.MapErr[core.Proc](func(rec record) (core.Proc, error) {
+		if err := rec.migrate(); err != nil {
+			return core.Proc{}, err
+		}
+
+		b, err := json.Marshal(rec)
+		if err != nil {
+			return core.Proc{}, errors.Wrapf(err, "marshal record")
+		}
+
+		var proc procData
+		if err := json.Unmarshal(b, &proc); err != nil {
+			return core.Proc{}, errors.Wrapf(err, "unmarshal record")
+		}
+
+		return mapFromRepo(proc), nil
+	}, data.Procs...)
+	if err != nil {
+		return Snapshot{}, errors.Wrapf(err, "snapshot %q", name)
+	}
+
+	return Snapshot{
+		Name:      name,
+		CreatedAt: data.CreatedAt,
+		Procs:     procs,
+	}, nil
+}
+
+// ListSnapshots in dir, sorted by name
+func ListSnapshots(dir string) ([]Snapshot, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if stdErrors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "read snapshots dir %q", dir)
+	}
+
+	snapshots := []Snapshot{}
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), ".json")
+		if !ok || entry.IsDir() {
+			continue
+		}
+
+		snapshot, err := LoadSnapshot(dir, name)
+		if err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+
+	slices.SortFunc(snapshots, func(a, b Snapshot) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return snapshots, nil
+}
+
+// DeleteSnapshot from dir by name
+func DeleteSnapshot(dir, name string) error {
+	if err := os.Remove(snapshotFile(dir, name)); err != nil {
+		if stdErrors.Is(err, fs.ErrNotExist) {
+			return errors.Newf("snapshot %q not found", name)
+		}
+		return errors.Wrapf(err, "remove snapshot %q", name)
+	}
+
+	return nil
+}
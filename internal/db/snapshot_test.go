@@ -0,0 +1,150 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/rprtr258/fun"
+	"github.com/shoenig/test"
+
+	"github.com/rprtr258/pm/internal/core"
+)
+
+func TestValidateSnapshotName(t *testing.T) {
+	t.Parallel()
+
+	for name, valid := range map[string]bool{
+		"default":    true,
+		"before-fix": true,
+		"v1.2":       true,
+		"":           false,
+		".hidden":    false,
+		"a/b":        false,
+		`a\b`:        false,
+		"..":         false,
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			err := ValidateSnapshotName(name)
+			if valid {
+				test.NoError(t, err)
+			} else {
+				test.EqError(t, err, fmt.Sprintf("invalid snapshot name %q", name))
+			}
+		})
+	}
+}
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	dir := filepath.Join(t.TempDir(), "snapshots")
+
+	var api core.Proc
+	api.ID = core.GenPMID()
+	api.Name = "api"
+	api.Tags = []string{"web"}
+	api.Command = "./api"
+	api.Args = []string{"--port", "8080"}
+	api.Cwd = "/srv/api"
+	api.Env = map[string]string{"LOG_LEVEL": "debug"}
+	api.StdoutFile = "/logs/api.stdout"
+	api.StderrFile = "/logs/api.stderr"
+	api.KillTimeout = 5 * time.Second
+	api.StopSignal = fun.Valid(syscall.SIGINT)
+	api.Ports = []core.Port{{Name: "http", Port: 20000}}
+	api.Cron = fun.Valid(core.CronSchedule("0 * * * *"))
+
+	worker := api
+	worker.ID = core.GenPMID()
+	worker.Name = "worker"
+	worker.Group = "worker"
+	worker.Instance = 1
+	worker.Cron = fun.Invalid[core.Cron]()
+	worker.Ports = nil
+
+	snapshot := Snapshot{
+		Name:      "before-deploy",
+		CreatedAt: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		Procs:     []core.Proc{api, worker},
+	}
+	test.NoError(t, SaveSnapshot(dir, snapshot))
+
+	got, err := LoadSnapshot(dir, "before-deploy")
+	test.NoError(t, err)
+	test.Eq(t, snapshot, got)
+
+	// saving under the same name replaces snapshot
+	snapshot.Procs = []core.Proc{worker}
+	test.NoError(t, SaveSnapshot(dir, snapshot))
+
+	got, err = LoadSnapshot(dir, "before-deploy")
+	test.NoError(t, err)
+	test.Eq(t, snapshot, got)
+
+	test.FileNotExists(t, snapshotFile(dir, "before-deploy")+".tmp")
+}
+
+func TestLoadLegacySnapshot(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	id := core.GenPMID()
+	// records saved before schema versions had cron as string
+	b, err := json.Marshal(map[string]any{
+		"created_at": time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		"procs": []map[string]any{{
+			"id":      id,
+			"name":    "job",
+			"command": "/bin/true",
+			"cron":    "*/5 * * * *",
+		}},
+	})
+	test.NoError(t, err)
+	test.NoError(t, os.WriteFile(snapshotFile(dir, "old"), b, 0o644))
+
+	snapshot, err := LoadSnapshot(dir, "old")
+	test.NoError(t, err)
+	test.SliceLen(t, 1, snapshot.Procs)
+	test.EqOp(t, id, snapshot.Procs[0].ID)
+	test.Eq(t, fun.Valid(core.CronSchedule("*/5 * * * *")), snapshot.Procs[0].Cron)
+}
+
+func TestListDeleteSnapshots(t *testing.T) {
+	t.Parallel()
+
+	dir := filepath.Join(t.TempDir(), "snapshots")
+
+	// missing dir has no snapshots
+	snapshots, err := ListSnapshots(dir)
+	test.NoError(t, err)
+	test.SliceEmpty(t, snapshots)
+
+	for _, name := range []string{"b", "a", "c"} {
+		test.NoError(t, SaveSnapshot(dir, Snapshot{
+			Name:      name,
+			CreatedAt: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+			Procs:     []core.Proc{},
+		}))
+	}
+	// other files are skipped
+	test.NoError(t, os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("hi"), 0o644))
+	test.NoError(t, os.Mkdir(filepath.Join(dir, "dir.json"), 0o755))
+
+	test.NoError(t, DeleteSnapshot(dir, "b"))
+	test.EqError(t, DeleteSnapshot(dir, "b"), `snapshot "b" not found`)
+	_, err = LoadSnapshot(dir, "b")
+	test.EqError(t, err, `snapshot "b" not found`)
+
+	snapshots, err = ListSnapshots(dir)
+	test.NoError(t, err)
+	test.Eq(t, []string{"a", "c"}, fun.Map[string](func(s Snapshot) string {
+		return s.Name
+	}, snapshots...))
+}
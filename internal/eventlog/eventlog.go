@@ -0,0 +1,244 @@
+// Package eventlog implements append-only log of process lifecycle events.
+// Log is a file with single json encoded core.Event per line. It is written
+// by shims and cli concurrently, each event is written using single write
+// call to file opened with O_APPEND, so lines are never interleaved. When log
+// grows over MaxSize, it is renamed to <log>.1, replacing previous one.
+package eventlog
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	stdErrors "errors"
+	"io"
+	"io/fs"
+	"os"
+	"syscall"
+
+	"github.com/nxadm/tail"
+	"github.com/rs/zerolog/log"
+
+	"github.com/rprtr258/pm/internal/core"
+	"github.com/rprtr258/pm/internal/errors"
+)
+
+// MaxSize of events log, after which it is rotated
+const MaxSize = 10 << 20
+
+// rotatedFilename is name of previous events log
+func rotatedFilename(filename string) string {
+	return filename + ".1"
+}
+
+// rotate events log f opened by filename, if it is too big
+func rotate(filename string, f *os.File) error {
+	// NOTE: lock, so that concurrent writers don't rotate log twice
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+		return errors.Wrapf(err, "lock events log %q", filename)
+	}
+	defer func() {
+		_ = syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+	}()
+
+	stat, errStat := f.Stat()
+	if errStat != nil {
+		return errors.Wrapf(errStat, "stat events log %q", filename)
+	}
+
+	// log might be rotated already by other writer
+	if current, err := os.Stat(filename); err != nil || !os.SameFile(stat, current) || stat.Size() < MaxSize {
+		return nil //nolint:nilerr // nothing to rotate
+	}
+
+	return errors.Wrapf(os.Rename(filename, rotatedFilename(filename)), "rotate events log %q", filename)
+}
+
+// Rotate events log, if it is too big
+func Rotate(filename string) error {
+	f, errOpen := os.Open(filename)
+	if errOpen != nil {
+		if stdErrors.Is(errOpen, fs.ErrNotExist) {
+			return nil
+		}
+
+		return errors.Wrapf(errOpen, "open events log %q", filename)
+	}
+	defer f.Close()
+
+	return rotate(filename, f)
+}
+
+// Append event to events log
+func Append(filename string, event core.Event) error {
+	line, errMarshal := json.Marshal(event)
+	if errMarshal != nil {
+		return errors.Wrapf(errMarshal, "marshal event")
+	}
+	line = append(line, '\n')
+
+	f, errOpen := os.OpenFile(filename, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0o640)
+	if errOpen != nil {
+		return errors.Wrapf(errOpen, "open events log %q", filename)
+	}
+	defer f.Close()
+
+	if _, errWrite := f.Write(line); errWrite != nil {
+		return errors.Wrapf(errWrite, "write events log %q", filename)
+	}
+
+	return rotate(filename, f)
+}
+
+// Emit appends event to default events log, logging error if any.
+// Events are not critical, so failing to write them must not break anything.
+func Emit(event core.Event) {
+	if err := Append(core.FileEvents, event); err != nil {
+		log.Error().
+			Err(err).
+			Str("type", string(event.Type)).
+			Stringer("id", event.ProcID).
+			Msg("emit event")
+	}
+}
+
+func parse(line []byte) (core.Event, bool) {
+	var event core.Event
+	if err := json.Unmarshal(line, &event); err != nil {
+		log.Warn().Err(err).Bytes("line", line).Msg("skipping invalid event line")
+		return event, false
+	}
+	return event, true
+}
+
+// Read all events from events log, including rotated one. Missing log is
+// treated as empty one.
+func Read(filename string) ([]core.Event, error) {
+	rotated, err := ReadTail(rotatedFilename(filename), 0)
+	if err != nil {
+		return nil, err
+	}
+
+	events, err := ReadTail(filename, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(rotated, events...), nil
+}
+
+// ReadTail reads events from last size bytes of events log, or whole log if
+// size is not positive. Rotated log is not read. Missing log is treated as
+// empty one.
+func ReadTail(filename string, size int64) ([]core.Event, error) {
+	f, errOpen := os.Open(filename)
+	if errOpen != nil {
+		if stdErrors.Is(errOpen, fs.ErrNotExist) {
+			return nil, nil
+		}
+
+		return nil, errors.Wrapf(errOpen, "open events log %q", filename)
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if size > 0 {
+		// NOTE: start one byte earlier, so that tail starting exactly at line
+		// start is not mistaken for partial line
+		offset, errSeek := f.Seek(-size-1, io.SeekEnd)
+		if errSeek != nil { // log is shorter than size
+			offset, errSeek = f.Seek(0, io.SeekStart)
+		}
+		if errSeek != nil {
+			return nil, errors.Wrapf(errSeek, "seek events log %q", filename)
+		}
+
+		reader := bufio.NewReader(f)
+		if offset > 0 {
+			// skip partial line, or preceding newline
+			if _, err := reader.ReadBytes('\n'); err != nil {
+				return nil, nil //nolint:nilerr // no complete lines in tail
+			}
+		}
+		r = reader
+	}
+
+	var events []core.Event
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if event, ok := parse(scanner.Bytes()); ok {
+			events = append(events, event)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrapf(err, "read events log %q", filename)
+	}
+
+	return events, nil
+}
+
+// Follow events log, only events appended after call are sent.
+// Channel is closed when ctx is done.
+func Follow(ctx context.Context, filename string) (<-chan core.Event, error) {
+	// NOTE: create file and remember its size, so that events written before
+	// tailer starts are not lost
+	f, errOpen := os.OpenFile(filename, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0o640)
+	if errOpen != nil {
+		return nil, errors.Wrapf(errOpen, "open events log %q", filename)
+	}
+	stat, errStat := f.Stat()
+	_ = f.Close()
+	if errStat != nil {
+		return nil, errors.Wrapf(errStat, "stat events log %q", filename)
+	}
+
+	tailer, err := tail.TailFile(filename, tail.Config{
+		Follow:        true,
+		CompleteLines: true,
+		ReOpen:        true,
+		Location: &tail.SeekInfo{
+			Whence: io.SeekStart,
+			Offset: stat.Size(),
+		},
+		Logger:      tail.DiscardingLogger,
+		MustExist:   true,
+		Poll:        false,
+		Pipe:        false,
+		MaxLineSize: 0,
+		RateLimiter: nil,
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "tail events log %q", filename)
+	}
+
+	res := make(chan core.Event)
+	go func() {
+		defer close(res)
+		defer tailer.Cleanup()
+		defer func() {
+			_ = tailer.Stop()
+		}()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case line, ok := <-tailer.Lines:
+				if !ok {
+					return
+				}
+
+				event, ok := parse([]byte(line.Text))
+				if !ok {
+					continue
+				}
+
+				select {
+				case <-ctx.Done():
+					return
+				case res <- event:
+				}
+			}
+		}
+	}()
+	return res, nil
+}
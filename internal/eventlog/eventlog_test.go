@@ -0,0 +1,168 @@
+package eventlog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rprtr258/fun"
+	"github.com/shoenig/test"
+
+	"github.com/rprtr258/pm/internal/core"
+)
+
+func newEvent(typ core.EventType, name string, exitCode int) core.Event {
+	event := core.NewEvent(typ, core.PMID("id-"+name), name)
+	event.Time = time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	event.ExitCode = fun.Valid(exitCode)
+	return event
+}
+
+func TestAppendRead(t *testing.T) {
+	t.Parallel()
+
+	filename := filepath.Join(t.TempDir(), "events.jsonl")
+
+	events, err := Read(filename)
+	test.NoError(t, err)
+	test.SliceEmpty(t, events)
+
+	want := []core.Event{
+		newEvent(core.EventStarted, "api", 0),
+		newEvent(core.EventExited, "api", 1),
+		newEvent(core.EventGaveUp, "worker", 2),
+	}
+	for _, event := range want {
+		test.NoError(t, Append(filename, event))
+	}
+
+	events, err = Read(filename)
+	test.NoError(t, err)
+	test.Eq(t, want, events)
+}
+
+func TestReadSkipsInvalidLines(t *testing.T) {
+	t.Parallel()
+
+	filename := filepath.Join(t.TempDir(), "events.jsonl")
+	event := newEvent(core.EventStarted, "api", 0)
+	line, err := json.Marshal(event)
+	test.NoError(t, err)
+	test.NoError(t, os.WriteFile(filename, []byte("not json\n"+string(line)+"\n{\"type\":\n"), 0o640))
+
+	events, err := Read(filename)
+	test.NoError(t, err)
+	test.Eq(t, []core.Event{event}, events)
+}
+
+func TestReadTail(t *testing.T) {
+	t.Parallel()
+
+	filename := filepath.Join(t.TempDir(), "events.jsonl")
+	all := []core.Event{}
+	for i := range 10 {
+		event := newEvent(core.EventExited, "api", i)
+		all = append(all, event)
+		test.NoError(t, Append(filename, event))
+	}
+
+	line, err := json.Marshal(all[0])
+	test.NoError(t, err)
+	lineSize := int64(len(line) + 1)
+
+	for name, tc := range map[string]struct {
+		size int64
+		want []core.Event
+	}{
+		"whole log":        {0, all},
+		"bigger than log":  {100 * lineSize, all},
+		"exact lines":      {3 * lineSize, all[7:]},
+		"partial line":     {3*lineSize + lineSize/2, all[7:]},
+		"less than a line": {lineSize / 2, nil},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			events, err := ReadTail(filename, tc.size)
+			test.NoError(t, err)
+			test.Eq(t, tc.want, events)
+		})
+	}
+}
+
+func TestRotate(t *testing.T) {
+	t.Parallel()
+
+	filename := filepath.Join(t.TempDir(), "events.jsonl")
+
+	// missing log is not rotated
+	test.NoError(t, Rotate(filename))
+	test.FileNotExists(t, rotatedFilename(filename))
+
+	// fill log up to MaxSize
+	line, err := json.Marshal(newEvent(core.EventStarted, "old", 0))
+	test.NoError(t, err)
+	line = append(line, '\n')
+	n := MaxSize/len(line) + 1
+	test.NoError(t, os.WriteFile(filename, bytes.Repeat(line, n), 0o640))
+
+	// appending to big log rotates it
+	last := newEvent(core.EventExited, "old", 1)
+	test.NoError(t, Append(filename, last))
+	test.FileExists(t, rotatedFilename(filename))
+	test.FileNotExists(t, filename)
+
+	next := newEvent(core.EventStarted, "new", 0)
+	test.NoError(t, Append(filename, next))
+
+	events, err := ReadTail(filename, 0)
+	test.NoError(t, err)
+	test.Eq(t, []core.Event{next}, events)
+
+	// rotated log is read too
+	events, err = Read(filename)
+	test.NoError(t, err)
+	test.EqOp(t, n+2, len(events))
+	test.Eq(t, []core.Event{last, next}, events[n:])
+
+	// small log is not rotated
+	rotated, err := os.ReadFile(rotatedFilename(filename))
+	test.NoError(t, err)
+	test.NoError(t, Rotate(filename))
+	test.FileExists(t, filename)
+	rotatedAfter, err := os.ReadFile(rotatedFilename(filename))
+	test.NoError(t, err)
+	test.EqOp(t, len(rotated), len(rotatedAfter))
+}
+
+func TestFollow(t *testing.T) {
+	t.Parallel()
+
+	filename := filepath.Join(t.TempDir(), "events.jsonl")
+	test.NoError(t, Append(filename, newEvent(core.EventStarted, "before", 0)))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ch, err := Follow(ctx, filename)
+	test.NoError(t, err)
+
+	// only events appended after follow started are sent
+	event := newEvent(core.EventExited, "after", 1)
+	test.NoError(t, Append(filename, event))
+
+	select {
+	case got := <-ch:
+		test.Eq(t, event, got)
+	case <-time.After(5 * time.Second):
+		t.Fatal("event is not followed")
+	}
+
+	cancel()
+	for range ch { //nolint:revive // drain until closed
+	}
+}
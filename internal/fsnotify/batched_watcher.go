@@ -51,12 +51,14 @@ type BatchedRecursiveWatcher struct {
 // operation. This can mean that batch time windows can be less than
 // batchWindow when a git operation starts before a time window expires. It can
 // also mean that a batch captures events over a time period greater than
-// batchWindow, when a git operation exceeds this duration.
+// batchWindow, when a git operation exceeds this duration. If ignore is
+// non-nil, paths it matches are neither watched nor batched.
 func NewBatchedRecursiveWatcher(
 	dir, gittoplevel string,
 	batchWindow time.Duration,
+	ignore func(path string) bool,
 ) (*BatchedRecursiveWatcher, error) {
-	w, err := newRecursiveWatcher(dir, gittoplevel)
+	w, err := newRecursiveWatcher(dir, gittoplevel, ignore)
 	if err != nil {
 		return nil, err
 	}
@@ -7,6 +7,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
 	"testing"
@@ -85,7 +86,17 @@ func setup(e *testscript.Env) (err error) {
 	var h special
 	var herr error
 	batchedFn := filepath.Join(e.Cd, ".batched")
-	if f, err := os.Open(batchedFn); err == nil {
+	pollingFn := filepath.Join(e.Cd, ".polling")
+	if b, err := os.ReadFile(pollingFn); err == nil {
+		// If there is a .polling file in e.Cd, we want a PollingWatcher
+		// scanning with interval parsed from its contents
+		d, err := time.ParseDuration(strings.TrimSpace(string(b)))
+		if err != nil {
+			return fmt.Errorf("failed to parse time.Duration from contents of %s: %w", pollingFn, err)
+		}
+
+		h, herr = pollingWatcher(s, d)
+	} else if f, err := os.Open(batchedFn); err == nil {
 		b, err := io.ReadAll(f)
 		if err != nil {
 			return fmt.Errorf("failed to read %s: %w", batchedFn, err)
@@ -96,7 +107,17 @@ func setup(e *testscript.Env) (err error) {
 			return fmt.Errorf("failed to parse time.Duration from contents of %s: %w", batchedFn, err)
 		}
 
-		h, herr = batchedWatcher(s, d)
+		// If there is an .ignore file in e.Cd, its lines are names of files
+		// and directories to ignore
+		var ignore func(string) bool
+		if b, err := os.ReadFile(filepath.Join(e.Cd, ".ignore")); err == nil {
+			names := strings.Fields(string(b))
+			ignore = func(path string) bool {
+				return slices.Contains(names, filepath.Base(path))
+			}
+		}
+
+		h, herr = batchedWatcher(s, d, ignore)
 	} else {
 		h, herr = watcher(s)
 	}
@@ -168,8 +189,21 @@ func watcher(s *setupCtx) (special, error) {
 	return s.handler, nil
 }
 
-func batchedWatcher(s *setupCtx, d time.Duration) (special, error) {
-	w, err := fsnotify.NewBatchedRecursiveWatcher(s.rootdir, s.gittoplevel, d)
+func batchedWatcher(s *setupCtx, d time.Duration, ignore func(string) bool) (special, error) {
+	w, err := fsnotify.NewBatchedRecursiveWatcher(s.rootdir, s.gittoplevel, d, ignore)
+	if err != nil {
+		return special{}, fmt.Errorf("failed to create a Watcher: %w", err)
+	}
+	s.Defer(func() {
+		w.Close()
+	})
+	bwh := newBatchedWatcherHandler(s, w.Events, w.Errors, handleSliceEvent)
+	go bwh.run()
+	return bwh.Special(), nil
+}
+
+func pollingWatcher(s *setupCtx, d time.Duration) (special, error) {
+	w, err := fsnotify.NewPollingWatcher(s.rootdir, d, nil)
 	if err != nil {
 		return special{}, fmt.Errorf("failed to create a Watcher: %w", err)
 	}
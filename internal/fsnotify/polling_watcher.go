@@ -0,0 +1,210 @@
+package fsnotify
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/rs/zerolog/log"
+)
+
+// fileState is what polling watcher compares to detect changes
+type fileState struct {
+	modTime time.Time
+	size    int64
+	mode    fs.FileMode
+}
+
+// PollingWatcher is a recursive watcher that periodically scans directory
+// tree and compares modification times, sizes and modes of files. Unlike
+// RecursiveWatcher it works on network filesystems and does not need inotify
+// watches. Create a new PollingWatcher via NewPollingWatcher.
+type PollingWatcher struct {
+	// rootDir is the root directory we are recursively watching.
+	rootDir string
+
+	// interval is time between scans.
+	interval time.Duration
+
+	// ignore is set if some paths must not be watched. Directories it matches
+	// are not descended into and files it matches are not reported.
+	ignore func(path string) bool
+
+	// files is the state of files seen on last scan, by path.
+	files map[string]fileState
+
+	// Events is the channel over which batches of Events are sent, single
+	// batch contains all changes found during one scan.
+	Events chan []fsnotify.Event
+
+	// Errors is the channel over which any Errors are reported
+	Errors chan error
+
+	// done is closed to stop scanning.
+	done chan struct{}
+
+	// doneClose indicates that scanning is stopped.
+	doneClose chan struct{}
+}
+
+// NewPollingWatcher creates a new polling watcher rooted at directory
+// rootDir, which scans it every interval. If ignore is non-nil, paths it
+// matches are neither scanned nor reported.
+func NewPollingWatcher(
+	rootDir string,
+	interval time.Duration,
+	ignore func(path string) bool,
+) (*PollingWatcher, error) {
+	res := &PollingWatcher{
+		rootDir:   rootDir,
+		interval:  interval,
+		ignore:    ignore,
+		files:     nil,
+		Events:    make(chan []fsnotify.Event),
+		Errors:    make(chan error),
+		done:      make(chan struct{}),
+		doneClose: make(chan struct{}),
+	}
+
+	files, err := res.scan()
+	if err != nil {
+		return nil, err
+	}
+	res.files = files
+
+	go res.runEventLoop()
+
+	return res, nil
+}
+
+// Close stops scanning and closes the Events channel.
+func (w *PollingWatcher) Close() error {
+	close(w.done)
+	<-w.doneClose
+	return nil
+}
+
+// scan walks directory tree collecting state of files and directories.
+func (w *PollingWatcher) scan() (map[string]fileState, error) {
+	files := map[string]fileState{}
+	err := filepath.WalkDir(w.rootDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == w.rootDir {
+				return err
+			}
+			// file is removed while walking or is not accessible, skip it
+			return nil
+		}
+
+		if path != w.rootDir {
+			if d.IsDir() && d.Name() == ".git" {
+				return fs.SkipDir
+			}
+			if w.ignore != nil && w.ignore(path) {
+				if d.IsDir() {
+					return fs.SkipDir
+				}
+				return nil
+			}
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			// file is removed while walking
+			return nil //nolint:nilerr // skip file
+		}
+
+		files[path] = fileState{
+			modTime: info.ModTime(),
+			size:    info.Size(),
+			mode:    info.Mode(),
+		}
+		return nil
+	})
+	return files, err
+}
+
+// diff reports changes between two scans, sorted by path.
+func diff(before, after map[string]fileState) []fsnotify.Event {
+	events := []fsnotify.Event{}
+	for path, state := range after {
+		prev, ok := before[path]
+		switch {
+		case !ok:
+			events = append(events, fsnotify.Event{Name: path, Op: fsnotify.Create})
+		case state.mode.IsDir():
+			// directory modification time changes when its entries are
+			// created or removed, which are reported themselves, and
+			// those entries might be ignored
+			if prev.mode != state.mode {
+				events = append(events, fsnotify.Event{Name: path, Op: fsnotify.Chmod})
+			}
+		case !prev.modTime.Equal(state.modTime) || prev.size != state.size:
+			events = append(events, fsnotify.Event{Name: path, Op: fsnotify.Write})
+		case prev.mode != state.mode:
+			events = append(events, fsnotify.Event{Name: path, Op: fsnotify.Chmod})
+		}
+	}
+	for path := range before {
+		if _, ok := after[path]; !ok {
+			events = append(events, fsnotify.Event{Name: path, Op: fsnotify.Remove})
+		}
+	}
+	slices.SortFunc(events, func(lhs, rhs fsnotify.Event) int {
+		return strings.Compare(lhs.Name, rhs.Name)
+	})
+	return events
+}
+
+// runEventLoop is the main event loop of a PollingWatcher. It scans
+// directory tree every interval and sends found changes.
+func (w *PollingWatcher) runEventLoop() {
+	defer func() {
+		close(w.doneClose)
+		// Pass on the close
+		close(w.Events)
+	}()
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-ticker.C:
+		}
+
+		files, err := w.scan()
+		if err != nil {
+			if os.IsNotExist(err) {
+				// root directory might be recreated later, e.g. by git checkout
+				log.Debug().Err(err).Str("root", w.rootDir).Msg("scan failed")
+				continue
+			}
+
+			select {
+			case <-w.done:
+				return
+			case w.Errors <- err:
+			}
+			continue
+		}
+
+		events := diff(w.files, files)
+		w.files = files
+		if len(events) == 0 {
+			continue
+		}
+
+		select {
+		case <-w.done:
+			return
+		case w.Events <- events:
+		}
+	}
+}
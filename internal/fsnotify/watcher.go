@@ -39,6 +39,10 @@ type RecursiveWatcher struct {
 	// rootDir.
 	gitLockFile string
 
+	// ignore is set if some paths must not be watched. Directories it matches
+	// are not descended into and events on matched paths are not relayed.
+	ignore func(path string) bool
+
 	// w is the underlying fsnotify watcher used for watching.
 	w *fsnotify.Watcher
 
@@ -60,7 +64,7 @@ type RecursiveWatcher struct {
 
 // NewRecursiveWatcher creates a new recursive watcher rooted at directory rootDir.
 func NewRecursiveWatcher(rootDir string) (*RecursiveWatcher, error) {
-	return newRecursiveWatcher(rootDir, "")
+	return newRecursiveWatcher(rootDir, "", nil)
 }
 
 // newRecursiveWatcher creates a new recursive watcher rooted at directory
@@ -71,8 +75,9 @@ func NewRecursiveWatcher(rootDir string) (*RecursiveWatcher, error) {
 // when the git operation completes. In glob terms, if gittoplevel where non
 // empty, the Events channel would contain events for
 // $gittoplevel/.git/index.lock and dir/**/* (including directories). If
-// gittoplevel is supplied, dir must be a subdirectory of gittoplevel.
-func newRecursiveWatcher(rootDir, gittoplevel string) (*RecursiveWatcher, error) {
+// gittoplevel is supplied, dir must be a subdirectory of gittoplevel. If
+// ignore is non-nil, paths it matches are neither watched nor reported.
+func newRecursiveWatcher(rootDir, gittoplevel string, ignore func(string) bool) (*RecursiveWatcher, error) {
 	if rootDir != gittoplevel && !strings.HasPrefix(rootDir, gittoplevel+string(os.PathSeparator)) {
 		return nil, fmt.Errorf("%s is not a subdirectory of %s", rootDir, gittoplevel)
 	}
@@ -92,6 +97,7 @@ func newRecursiveWatcher(rootDir, gittoplevel string) (*RecursiveWatcher, error)
 		rootDir:     rootDir,
 		gitDir:      gitDir,
 		gitLockFile: gitLockfile,
+		ignore:      ignore,
 		w:           w,
 		Events:      make(chan fsnotify.Event),
 		Errors:      make(chan error),
@@ -161,6 +167,10 @@ func (w *RecursiveWatcher) runEventLoop() {
 				continue
 			}
 
+			if w.isIgnored(ev.Name) {
+				continue
+			}
+
 			log.Debug().
 				Str("path", ev.Name).
 				Stringer("op", ev.Op).
@@ -246,6 +256,9 @@ func (w *RecursiveWatcher) addDir(dir string, ignoreErrors bool) error {
 		if w.gitDir != "" && w.gitDir == path || filepath.Base(path) == ".git" {
 			return fs.SkipDir
 		}
+		if path != w.rootDir && w.isIgnored(path) {
+			return fs.SkipDir
+		}
 		// Only need to create a watcher if we don't have one
 		if _, ok := w.watchers[path]; ok {
 			return nil
@@ -262,3 +275,9 @@ func (w *RecursiveWatcher) addDir(dir string, ignoreErrors bool) error {
 		return nil
 	})
 }
+
+// isIgnored reports whether path must not be watched. git lock file is never
+// ignored, since it is used to detect git operations.
+func (w *RecursiveWatcher) isIgnored(path string) bool {
+	return w.ignore != nil && path != w.gitLockFile && w.ignore(path)
+}
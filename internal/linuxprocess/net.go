@@ -0,0 +1,77 @@
+package linuxprocess
+
+import (
+	"bufio"
+	stdErrors "errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/rprtr258/pm/internal/errors"
+)
+
+const (
+	_tcpEstablished = "01" // TCP_ESTABLISHED state in /proc/net/tcp
+	_unixConnected  = "03" // SS_CONNECTED state in /proc/net/unix
+)
+
+// scanProcNet calls f with fields of each line of /proc/net file, skipping header
+func scanProcNet(filename string, f func(fields []string)) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return errors.Wrapf(err, "open %q", filename)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Scan() // skip header
+	for scanner.Scan() {
+		f(strings.Fields(scanner.Text()))
+	}
+	return errors.Wrapf(scanner.Err(), "read %q", filename)
+}
+
+// Connections counts established connections to socket listening on address.
+// Connections waiting in accept queue are counted as well.
+func Connections(network, address string) (int, error) {
+	count := 0
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+		_, portStr, err := net.SplitHostPort(address)
+		if err != nil {
+			return 0, errors.Wrapf(err, "parse address %q", address)
+		}
+
+		port, err := strconv.ParseUint(portStr, 10, 16)
+		if err != nil {
+			return 0, errors.Wrapf(err, "parse port %q", portStr)
+		}
+
+		// local address is like "0100007F:1F90"
+		suffix := fmt.Sprintf(":%04X", port)
+		for _, filename := range []string{"/proc/net/tcp", "/proc/net/tcp6"} {
+			if err := scanProcNet(filename, func(fields []string) {
+				if len(fields) > 3 && strings.HasSuffix(fields[1], suffix) && fields[3] == _tcpEstablished {
+					count++
+				}
+			}); err != nil && !stdErrors.Is(err, fs.ErrNotExist) {
+				return 0, err
+			}
+		}
+	case "unix":
+		// accepted sockets have the same path as listening one
+		if err := scanProcNet("/proc/net/unix", func(fields []string) {
+			if len(fields) > 7 && fields[7] == address && fields[5] == _unixConnected {
+				count++
+			}
+		}); err != nil {
+			return 0, err
+		}
+	default:
+		return 0, errors.Newf("unsupported network %q", network)
+	}
+	return count, nil
+}
@@ -0,0 +1,52 @@
+package linuxprocess
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// cgroupDir returns cgroup v2 directory of current process
+func cgroupDir() (string, bool) {
+	data, err := os.ReadFile("/proc/self/cgroup")
+	if err != nil {
+		return "", false
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		// cgroup v2 line looks like "0::/user.slice/..."
+		if path, ok := strings.CutPrefix(line, "0::"); ok {
+			return filepath.Join("/sys/fs/cgroup", path), true
+		}
+	}
+
+	return "", false
+}
+
+// OOMKills returns number of processes killed by OOM killer in cgroup of
+// current process. Children are in the same cgroup, so growth of counter
+// while child is running means child might be OOM killed.
+func OOMKills() (uint64, bool) {
+	dir, ok := cgroupDir()
+	if !ok {
+		return 0, false
+	}
+
+	f, err := os.Open(filepath.Join(dir, "memory.events"))
+	if err != nil {
+		return 0, false
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if value, ok := strings.CutPrefix(scanner.Text(), "oom_kill "); ok {
+			count, err := strconv.ParseUint(value, 10, 64)
+			return count, err == nil
+		}
+	}
+
+	return 0, false
+}
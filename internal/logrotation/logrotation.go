@@ -91,9 +91,10 @@ type Writer struct {
 	// Default is not to perform compression.
 	compress bool
 
-	size int64
-	file *os.File
-	mu   sync.Mutex
+	size    int64
+	file    *os.File
+	backups []string // files log file was moved to on rotations, in order
+	mu      sync.Mutex
 
 	millCh    chan bool
 	startMill sync.Once
@@ -166,6 +167,7 @@ func New(cfg Config) *Writer {
 		// not set
 		size:      0,
 		file:      nil,
+		backups:   nil,
 		mu:        sync.Mutex{},
 		millCh:    nil,
 		startMill: sync.Once{},
@@ -203,6 +205,49 @@ func (l *Writer) Write(b []byte) (int, error) {
 	return n, err
 }
 
+// Offset is size of current log file, which is offset at which next write
+// starts, unless it causes rotation.
+func (l *Writer) Offset() int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.offset()
+}
+
+// Position of next write, unless it causes rotation: generation of current
+// log file, which is number of rotations done by writer, and offset in it
+func (l *Writer) Position() (int, int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return len(l.backups), l.offset()
+}
+
+// Backup is file log file of given generation was moved to on rotation,
+// empty if it is current log file. Backup might be compressed or removed
+// since.
+func (l *Writer) Backup(generation int) string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if generation < 0 || generation >= len(l.backups) {
+		return ""
+	}
+	return l.backups[generation]
+}
+
+func (l *Writer) offset() int64 {
+	if l.file != nil {
+		return l.size
+	}
+
+	info, err := l.fs.Stat(l.filename)
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
+
 // Close implements io.Closer, and closes current logfile.
 func (l *Writer) Close() error {
 	l.mu.Lock()
@@ -298,6 +343,7 @@ func (l *Writer) openNew() error {
 		if err := os.Rename(name, newname); err != nil {
 			return errors.Wrap(err, "rename log file")
 		}
+		l.backups = append(l.backups, newname)
 
 		if err := chown(l.fs, name, stat); err != nil {
 			return err
@@ -143,6 +143,72 @@ func TestAutoRotate(t *testing.T) {
 	assertFileCount(t, dir, 2)
 }
 
+func TestOffset(t *testing.T) {
+	t.Parallel()
+
+	dir := useTempDir(t)
+	clock := useClock()
+	filename := fileLog(dir)
+
+	data := "foo!"
+	test.NoError(t, os.WriteFile(filename, []byte(data), 0o644))
+
+	w := New(Config{
+		Filename: filename,
+		MaxSize:  10,
+		clock:    clock.Now,
+	})
+	defer w.Close()
+
+	// existing file is not opened yet
+	test.EqOp(t, int64(len(data)), w.Offset())
+
+	b := "boo!"
+	assertWrite(t, w, b)
+	test.EqOp(t, int64(len(data+b)), w.Offset())
+
+	clock.advance()
+
+	// write causes rotation, offset is in new file
+	b2 := "foooooo!"
+	assertWrite(t, w, b2)
+	test.EqOp(t, int64(len(b2)), w.Offset())
+}
+
+func TestPosition(t *testing.T) {
+	t.Parallel()
+
+	dir := useTempDir(t)
+	clock := useClock()
+	filename := fileLog(dir)
+
+	w := New(Config{
+		Filename: filename,
+		MaxSize:  10,
+		clock:    clock.Now,
+	})
+	defer w.Close()
+
+	b := "boo!"
+	assertWrite(t, w, b)
+	generation, offset := w.Position()
+	test.EqOp(t, 0, generation)
+	test.EqOp(t, int64(len(b)), offset)
+	test.EqOp(t, "", w.Backup(0))
+
+	clock.advance()
+
+	// write causes rotation, current file has next generation
+	b2 := "foooooo!"
+	assertWrite(t, w, b2)
+	generation, offset = w.Position()
+	test.EqOp(t, 1, generation)
+	test.EqOp(t, int64(len(b2)), offset)
+	test.EqOp(t, fileBackup(dir, clock.Now()), w.Backup(0))
+	test.EqOp(t, "", w.Backup(1))
+	assertFileContent(t, w.Backup(0), b)
+}
+
 func TestFirstWriteRotate(t *testing.T) {
 	t.Parallel()
 
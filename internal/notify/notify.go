@@ -0,0 +1,241 @@
+// Package notify sends notifications about process failures to webhooks,
+// commands and unix datagram sockets.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/rprtr258/pm/internal/core"
+	"github.com/rprtr258/pm/internal/errors"
+)
+
+const (
+	DefaultRateLimit = time.Minute
+	_sendTimeout     = 10 * time.Second
+)
+
+// payload is slack-compatible webhook payload with event attached
+type payload struct {
+	Text  string     `json:"text"`
+	Event core.Event `json:"event"`
+}
+
+// Message describes event in human readable form
+func Message(event core.Event) string {
+	switch event.Type { //nolint:exhaustive // other events are described generically
+	case core.EventExited:
+		if event.Signal != "" {
+			return fmt.Sprintf("pm: process %q was killed by signal %q", event.ProcName, event.Signal)
+		}
+		if exitCode, ok := event.ExitCode.Unpack(); ok {
+			return fmt.Sprintf("pm: process %q exited with code %d", event.ProcName, exitCode)
+		}
+		return fmt.Sprintf("pm: process %q exited", event.ProcName)
+	case core.EventOOM:
+		return fmt.Sprintf("pm: process %q was killed by OOM killer", event.ProcName)
+	case core.EventGaveUp:
+		return fmt.Sprintf("pm: process %q keeps crashing, giving up restarting it", event.ProcName)
+	default:
+		return fmt.Sprintf("pm: process %q %s", event.ProcName, event.Type)
+	}
+}
+
+// Notifier sends notifications for single process, at most one per rate limit
+// interval. Notifications during interval are dropped and counted, summary
+// about them is sent when interval ends. Events after which process might not
+// fail anymore, like giving up restarts, are sent regardless of rate limit.
+type Notifier struct {
+	cfg core.Notify
+
+	mu             sync.Mutex
+	lastSentAt     time.Time
+	suppressed     int
+	lastSuppressed core.Event  // summary of suppressed notifications is about it
+	flushTimer     *time.Timer // sends summary when rate limit interval ends
+
+	// inflight is number of notifications being sent, sent is signalled when
+	// it decreases. Unlike WaitGroup, sends might start while Wait is waiting,
+	// e.g. summary sent by flushTimer.
+	inflight int
+	sent     *sync.Cond
+}
+
+func New(cfg core.Notify) *Notifier {
+	if cfg.RateLimit == 0 {
+		cfg.RateLimit = DefaultRateLimit
+	}
+
+	n := &Notifier{ //nolint:exhaustruct // zero values are fine
+		cfg: cfg,
+	}
+	n.sent = sync.NewCond(&n.mu)
+	return n
+}
+
+// isRateLimited reports whether notification about event might be dropped by
+// rate limit
+func isRateLimited(event core.Event) bool {
+	switch event.Type { //nolint:exhaustive // other events are rate limited
+	case core.EventGaveUp, core.EventOOM, core.EventBuildFailed:
+		return false
+	default:
+		return true
+	}
+}
+
+// takeSuppressed count of suppressed notifications, resetting it. Must be
+// called with mu locked.
+func (n *Notifier) takeSuppressed() int {
+	if n.flushTimer != nil {
+		n.flushTimer.Stop()
+		n.flushTimer = nil
+	}
+
+	suppressed := n.suppressed
+	n.suppressed = 0
+	return suppressed
+}
+
+// Notify about event in background. Call Wait to ensure notifications are sent.
+func (n *Notifier) Notify(event core.Event) {
+	n.mu.Lock()
+	if sinceLast := time.Since(n.lastSentAt); isRateLimited(event) && sinceLast < n.cfg.RateLimit {
+		n.suppressed++
+		n.lastSuppressed = event
+		if n.flushTimer == nil {
+			n.flushTimer = time.AfterFunc(n.cfg.RateLimit-sinceLast, n.flush)
+		}
+		n.mu.Unlock()
+		log.Debug().Str("type", string(event.Type)).Msg("notification suppressed by rate limit")
+		return
+	}
+	suppressed := n.takeSuppressed()
+	n.lastSentAt = time.Now()
+	n.inflight++
+	n.mu.Unlock()
+
+	text := Message(event)
+	if suppressed > 0 {
+		text += fmt.Sprintf(" (%d more notifications suppressed)", suppressed)
+	}
+	go n.sendEvent(event, text)
+}
+
+// flush summary of suppressed notifications, if any
+func (n *Notifier) flush() {
+	n.mu.Lock()
+	event, suppressed := n.lastSuppressed, n.takeSuppressed()
+	if suppressed == 0 {
+		n.mu.Unlock()
+		return
+	}
+	n.lastSentAt = time.Now()
+	n.inflight++
+	n.mu.Unlock()
+
+	go n.sendEvent(event, fmt.Sprintf("%s (last of %d suppressed notifications)", Message(event), suppressed))
+}
+
+// sendEvent notification, inflight must be incremented by caller
+func (n *Notifier) sendEvent(event core.Event, text string) {
+	defer func() {
+		n.mu.Lock()
+		n.inflight--
+		n.sent.Broadcast()
+		n.mu.Unlock()
+	}()
+
+	if err := n.send(payload{
+		Text:  text,
+		Event: event,
+	}); err != nil {
+		log.Error().Err(err).Str("type", string(event.Type)).Msg("send notification")
+	}
+}
+
+// Wait for all notifications to be sent, summary of suppressed notifications
+// is sent immediately
+func (n *Notifier) Wait() {
+	n.flush()
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for n.inflight > 0 {
+		n.sent.Wait()
+	}
+}
+
+func (n *Notifier) send(p payload) error {
+	body, errMarshal := json.Marshal(p)
+	if errMarshal != nil {
+		return errors.Wrapf(errMarshal, "marshal payload")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), _sendTimeout)
+	defer cancel()
+
+	var errs []error
+	if url, ok := n.cfg.Webhook.Unpack(); ok {
+		errs = append(errs, errors.Wrapf(sendWebhook(ctx, url, body), "webhook"))
+	}
+	if len(n.cfg.Command) > 0 {
+		errs = append(errs, errors.Wrapf(runCommand(ctx, n.cfg.Command, p.Text), "command"))
+	}
+	if socket, ok := n.cfg.Socket.Unpack(); ok {
+		errs = append(errs, errors.Wrapf(writeSocket(ctx, socket, body), "socket"))
+	}
+	return errors.Combine(errs...)
+}
+
+func sendWebhook(ctx context.Context, url string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrapf(err, "create request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return errors.Wrapf(err, "post %q", url)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return errors.Newf("post %q: unexpected status %s", url, resp.Status)
+	}
+
+	return nil
+}
+
+func runCommand(ctx context.Context, command []string, text string) error {
+	cmd := exec.CommandContext(ctx, command[0], append(command[1:], text)...) //nolint:gosec // command is from user config
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return errors.Wrapf(err, "run %q, output: %s", cmd.String(), output)
+	}
+
+	return nil
+}
+
+func writeSocket(ctx context.Context, socket string, body []byte) error {
+	conn, err := (&net.Dialer{}).DialContext(ctx, "unixgram", socket) //nolint:exhaustruct // defaults
+	if err != nil {
+		return errors.Wrapf(err, "dial %q", socket)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write(body); err != nil {
+		return errors.Wrapf(err, "write %q", socket)
+	}
+
+	return nil
+}
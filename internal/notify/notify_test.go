@@ -0,0 +1,167 @@
+package notify
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rprtr258/fun"
+	"github.com/shoenig/test"
+
+	"github.com/rprtr258/pm/internal/core"
+)
+
+// webhook collecting texts of notifications
+type webhook struct {
+	mu    sync.Mutex
+	texts []string
+}
+
+func newWebhook(t *testing.T) (*webhook, string) {
+	t.Helper()
+
+	w := &webhook{
+		mu:    sync.Mutex{},
+		texts: nil,
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		var p payload
+		test.NoError(t, json.NewDecoder(r.Body).Decode(&p))
+
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		w.texts = append(w.texts, p.Text)
+	}))
+	t.Cleanup(srv.Close)
+	return w, srv.URL
+}
+
+// received texts, sorted as notifications are sent concurrently
+func (w *webhook) received() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	texts := slices.Clone(w.texts)
+	slices.Sort(texts)
+	return texts
+}
+
+func newNotifier(url string, rateLimit time.Duration) *Notifier {
+	return New(core.Notify{
+		Webhook:   fun.Valid(url),
+		Command:   nil,
+		Socket:    fun.Invalid[string](),
+		RateLimit: rateLimit,
+	})
+}
+
+func exitedEvent(code int) core.Event {
+	event := core.NewEvent(core.EventExited, "id", "api")
+	event.ExitCode = fun.Valid(code)
+	return event
+}
+
+func TestMessage(t *testing.T) {
+	t.Parallel()
+
+	killed := core.NewEvent(core.EventExited, "id", "api")
+	killed.Signal = "killed"
+
+	for name, tc := range map[string]struct {
+		event core.Event
+		want  string
+	}{
+		"exit code": {exitedEvent(2), `pm: process "api" exited with code 2`},
+		"signal":    {killed, `pm: process "api" was killed by signal "killed"`},
+		"exited":    {core.NewEvent(core.EventExited, "id", "api"), `pm: process "api" exited`},
+		"oom":       {core.NewEvent(core.EventOOM, "id", "api"), `pm: process "api" was killed by OOM killer`},
+		"gave up":   {core.NewEvent(core.EventGaveUp, "id", "api"), `pm: process "api" keeps crashing, giving up restarting it`},
+		"other":     {core.NewEvent(core.EventBuildFailed, "id", "api"), `pm: process "api" build_failed`},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			test.EqOp(t, tc.want, Message(tc.event))
+		})
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	t.Parallel()
+
+	hook, url := newWebhook(t)
+	n := newNotifier(url, time.Hour)
+
+	n.Notify(exitedEvent(1))
+	n.Notify(exitedEvent(2))
+	n.Notify(exitedEvent(3))
+	// not rate limited, process might not fail anymore after it, so it
+	// reports suppressed notifications
+	n.Notify(core.NewEvent(core.EventGaveUp, "id", "api"))
+	n.Wait()
+
+	test.Eq(t, []string{
+		`pm: process "api" exited with code 1`,
+		`pm: process "api" keeps crashing, giving up restarting it (2 more notifications suppressed)`,
+	}, hook.received())
+}
+
+func TestRateLimitSummaryOnNextNotification(t *testing.T) {
+	t.Parallel()
+
+	hook, url := newWebhook(t)
+	n := newNotifier(url, time.Hour)
+
+	n.Notify(exitedEvent(1))
+	n.Notify(exitedEvent(2))
+	// rate limit interval is over
+	n.mu.Lock()
+	n.lastSentAt = time.Time{}
+	n.mu.Unlock()
+	n.Notify(exitedEvent(3))
+	n.Wait()
+
+	test.Eq(t, []string{
+		`pm: process "api" exited with code 1`,
+		`pm: process "api" exited with code 3 (1 more notifications suppressed)`,
+	}, hook.received())
+}
+
+func TestRateLimitSummaryAfterInterval(t *testing.T) {
+	t.Parallel()
+
+	hook, url := newWebhook(t)
+	n := newNotifier(url, 50*time.Millisecond)
+
+	n.Notify(exitedEvent(1))
+	n.Notify(exitedEvent(2))
+	n.Notify(exitedEvent(3))
+	time.Sleep(200 * time.Millisecond)
+	n.Wait()
+
+	test.Eq(t, []string{
+		`pm: process "api" exited with code 1`,
+		`pm: process "api" exited with code 3 (last of 2 suppressed notifications)`,
+	}, hook.received())
+}
+
+func TestWaitDuringFlush(t *testing.T) {
+	t.Parallel()
+
+	for range 20 {
+		hook, url := newWebhook(t)
+		n := newNotifier(url, time.Millisecond)
+
+		n.Notify(exitedEvent(1))
+		n.Notify(exitedEvent(2))
+		// summary is sent either by timer or by Wait, but exactly once
+		time.Sleep(time.Millisecond)
+		n.Wait()
+
+		test.EqOp(t, 2, len(hook.received()))
+	}
+}